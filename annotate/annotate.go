@@ -0,0 +1,218 @@
+package annotate
+
+import (
+  "fmt"
+  "math"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  pgn "chess/pgn"
+)
+
+// Game review. Every position of a game is searched once, the loss of
+// a move is how much worse the position got for the player who made
+// it, and moves that lose enough are marked as inaccuracies, mistakes
+// or blunders with the engine's line added as a variation.
+
+type Options struct {
+  Depth int
+  Time time.Duration // per position, 0 for depth only
+  Threads int
+  Inaccuracy int // centipawn loss for each class
+  Mistake int
+  Blunder int
+}
+
+func DefaultOptions() Options {
+  return Options{Depth: 4, Threads: 1, Inaccuracy: 50, Mistake: 100, Blunder: 200}
+}
+
+// scores are capped here when working out losses, so dropping from a
+// won position to a slightly less won one is not a blunder
+const scoreCap = 1000
+
+type Class int
+
+const (
+  Good Class = iota
+  Inaccuracy
+  Mistake
+  Blunder
+)
+
+var classNames = [...]string{"Good", "Inaccuracy", "Mistake", "Blunder"}
+
+var classNAGs = [...]int{0, 6, 2, 4} // ?!, ? and ??
+
+func (c Class) String() string {
+  return classNames[c]
+}
+
+// Summary is one player's review.
+type Summary struct {
+  Moves int
+  Accuracy float64 // 0 to 100
+  AverageLoss float64 // average centipawn loss
+  Classes [4]int // moves in each Class
+}
+
+func (s Summary) String() string {
+  return fmt.Sprintf("accuracy %.1f%%  acpl %.0f  inaccuracies %d  mistakes %d  blunders %d",
+    s.Accuracy, s.AverageLoss, s.Classes[Inaccuracy], s.Classes[Mistake], s.Classes[Blunder])
+}
+
+type position struct {
+  score int // white's point of view
+  pv []chess.Move
+  over bool
+}
+
+func Annotate(game *pgn.Game, opts Options) (*pgn.Game, [2]Summary, error) {
+  // returns an annotated copy of game and a summary for white and
+  // black
+  var summaries [2]Summary
+  boards, err := game.Boards()
+  if err != nil {
+    return nil, summaries, err
+  }
+  ab := engine.AlphaBetaInputProvider{
+    SearchDepth: opts.Depth,
+    Threads: opts.Threads,
+    Limits: engine.SearchLimits{Time: opts.Time},
+    TT: engine.NewTranspositionTable(engine.DefaultHashMB),
+  }
+  positions := make([]position, len(boards))
+  for i, board := range boards {
+    if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+      score := 0
+      if board.IsCheck(board.Turn) {
+        score = relative(-engine.MateScore, board.Turn)
+      }
+      positions[i] = position{score: score, over: true}
+      continue
+    }
+    result := ab.Search(board)
+    var pv []chess.Move
+    if len(result.Lines) > 0 {
+      pv = result.Lines[0].PV
+    }
+    positions[i] = position{score: result.Score, pv: pv}
+  }
+
+  annotated := *game
+  annotated.Tags = append([]pgn.Tag(nil), game.Tags...)
+  annotated.Moves = make([]pgn.Move, len(game.Moves))
+  var totalLoss, totalAccuracy [2]float64
+  for i, move := range game.Moves {
+    board := boards[i]
+    mover := board.Turn
+    before := clamp(relative(positions[i].score, mover))
+    after := clamp(relative(positions[i+1].score, mover))
+    loss := max(before-after, 0)
+    class := classify(loss, opts)
+
+    s := &summaries[mover]
+    s.Moves++
+    s.Classes[class]++
+    totalLoss[mover] += float64(loss)
+    totalAccuracy[mover] += moveAccuracy(before, after)
+
+    m := move
+    m.NAGs = append([]int(nil), move.NAGs...)
+    m.Variations = append([][]pgn.Move(nil), move.Variations...)
+    comment := ""
+    if !positions[i+1].over {
+      comment = fmt.Sprintf("[%%eval %s]", formatEval(positions[i+1]))
+    }
+    best := positions[i].pv
+    if class != Good {
+      m.NAGs = append(m.NAGs, classNAGs[class])
+      if len(best) > 0 && best[0] != move.Move {
+        comment = joinComment(comment, fmt.Sprintf("%s. %s was best.", class, board.SAN(best[0])))
+        m.Variations = append(m.Variations, variation(board, best))
+      } else {
+        comment = joinComment(comment, class.String()+".")
+      }
+    }
+    m.Comment = joinComment(comment, move.Comment)
+    annotated.Moves[i] = m
+  }
+  for c := range summaries {
+    if summaries[c].Moves > 0 {
+      summaries[c].AverageLoss = totalLoss[c] / float64(summaries[c].Moves)
+      summaries[c].Accuracy = totalAccuracy[c] / float64(summaries[c].Moves)
+    }
+  }
+  return &annotated, summaries, nil
+}
+
+func relative(score int, color chess.Color) int {
+  if color == chess.Black {
+    return -score
+  }
+  return score
+}
+
+func clamp(score int) int {
+  return min(max(score, -scoreCap), scoreCap)
+}
+
+func classify(loss int, opts Options) Class {
+  switch {
+  case loss >= opts.Blunder:
+    return Blunder
+  case loss >= opts.Mistake:
+    return Mistake
+  case loss >= opts.Inaccuracy:
+    return Inaccuracy
+  }
+  return Good
+}
+
+func winChance(score int) float64 {
+  // expected score in percent for a centipawn advantage
+  return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(score)))-1)
+}
+
+func moveAccuracy(before, after int) float64 {
+  // 100 for a move that keeps the winning chances, falling off
+  // quickly as they drop
+  drop := max(winChance(before)-winChance(after), 0)
+  return min(max(103.1668*math.Exp(-0.04354*drop)-3.1669, 0), 100)
+}
+
+func formatEval(p position) string {
+  // pawns from white's point of view, or #n for a forced mate in n
+  // moves
+  if engine.IsMate(p.score) {
+    moves := (engine.MatePlies(p.score) + 1) / 2
+    if p.score < 0 {
+      return fmt.Sprintf("#-%d", moves)
+    }
+    return fmt.Sprintf("#%d", moves)
+  }
+  return fmt.Sprintf("%.2f", float64(p.score)/100)
+}
+
+func variation(board *chess.Board, line []chess.Move) []pgn.Move {
+  var moves []pgn.Move
+  for _, move := range line {
+    if !board.IsLegal(move) {
+      break
+    }
+    moves = append(moves, pgn.Move{Move: move, SAN: board.SAN(move)})
+    board = board.Clone()
+    board.PlayMove(move)
+  }
+  return moves
+}
+
+func joinComment(a, b string) string {
+  switch {
+  case a == "":
+    return b
+  case b == "":
+    return a
+  }
+  return a + " " + b
+}
@@ -203,7 +203,7 @@ func GetPawnMoves(sq Square, fullBB Bitboard, color Color, otherColorBB Bitboard
 		// same for black
 		moves = (1 << (sq - 8)) & ^fullBB
 		moves |= ((moves & Rank6) >> 8) & ^fullBB
-		moves |= (((1 << (sq - 7)) & ^FileH) & otherColorBB) | (((1 << (sq - 9)) & ^FileA) & otherColorBB)
+		moves |= (((1 << (sq - 7)) & ^FileA) & otherColorBB) | (((1 << (sq - 9)) & ^FileH) & otherColorBB)
     if enPassantSquare != nil {
       if (sq.GetRank() == Rank4){
 			  epTarget := *enPassantSquare
@@ -218,68 +218,44 @@ func GetPawnMoves(sq Square, fullBB Bitboard, color Color, otherColorBB Bitboard
 }
 
 func GetBishopMoves(sq Square, fullBB Bitboard, colorBB Bitboard) Bitboard {
-	// Creates a bitboard of every legal move that a rook
+	// Creates a bitboard of every legal move that a bishop
 	// of the coresponding color and square could make.
-	rank := sq / 8
-	file := sq % 8
+	// rank and file are ints so the loops can run below zero
+	// without wrapping around.
+	rank := int(sq / 8)
+	file := int(sq % 8)
 	var moves Bitboard = 0
 
 	// up right
-	f := file + 1
-	for r := rank + 1; r < 8; r++ {
+	for r, f := rank+1, file+1; r < 8 && f < 8; r, f = r+1, f+1 {
 		target := r*8 + f
 		moves |= 1 << uint(target)
 		if (fullBB & (1 << uint(target))) != 0 {
 			break
 		}
-		if f >= 7 {
-			break
-		}
-		f++
 	}
 	// down right
-	if rank != 0 {
-		f = file + 1
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f >= 7 {
-				break
-			}
-			f++
+	for r, f := rank-1, file+1; r >= 0 && f < 8; r, f = r-1, f+1 {
+		target := r*8 + f
+		moves |= 1 << uint(target)
+		if (fullBB & (1 << uint(target))) != 0 {
+			break
 		}
 	}
 	// down left
-	if rank != 0 && file != 0 {
-		f = file - 1
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f == 0 {
-				break
-			}
-			f--
+	for r, f := rank-1, file-1; r >= 0 && f >= 0; r, f = r-1, f-1 {
+		target := r*8 + f
+		moves |= 1 << uint(target)
+		if (fullBB & (1 << uint(target))) != 0 {
+			break
 		}
 	}
 	// up left
-	if file != 0 {
-		f = file - 1
-		for r := rank + 1; r < 8; r++ {
-			target := r*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
-			if f == 0 {
-				break
-			}
-			f--
+	for r, f := rank+1, file-1; r < 8 && f >= 0; r, f = r+1, f-1 {
+		target := r*8 + f
+		moves |= 1 << uint(target)
+		if (fullBB & (1 << uint(target))) != 0 {
+			break
 		}
 	}
 	return moves &^ colorBB
@@ -288,8 +264,8 @@ func GetBishopMoves(sq Square, fullBB Bitboard, colorBB Bitboard) Bitboard {
 func GetRookMoves(sq Square, fullBB Bitboard, colorBB Bitboard) Bitboard {
 	// Creates a bitboard of every legal move that a rook
 	// of the coresponding color and square could make.
-	rank := sq / 8
-	file := sq % 8
+	rank := int(sq / 8)
+	file := int(sq % 8)
 	var moves Bitboard = 0
 
 	// up
@@ -309,23 +285,19 @@ func GetRookMoves(sq Square, fullBB Bitboard, colorBB Bitboard) Bitboard {
 		}
 	}
 	// down
-	if rank != 0 {
-		for r := rank - 1; r >= 0; r-- {
-			target := r*8 + file
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
+	for r := rank - 1; r >= 0; r-- {
+		target := r*8 + file
+		moves |= 1 << uint(target)
+		if (fullBB & (1 << uint(target))) != 0 {
+			break
 		}
 	}
 	// left
-	if file != 0 {
-		for f := file - 1; f >= 0; f-- {
-			target := rank*8 + f
-			moves |= 1 << uint(target)
-			if (fullBB & (1 << uint(target))) != 0 {
-				break
-			}
+	for f := file - 1; f >= 0; f-- {
+		target := rank*8 + f
+		moves |= 1 << uint(target)
+		if (fullBB & (1 << uint(target))) != 0 {
+			break
 		}
 	}
 	return moves &^ colorBB
@@ -335,7 +307,9 @@ func GetQueenMoves(sq Square, fullBB Bitboard, colorBB Bitboard) Bitboard {
 	return GetRookMoves(sq, fullBB, colorBB) | GetBishopMoves(sq, fullBB, colorBB)
 }
 
-func GetKingMoves(sq Square, fullBB Bitboard, color Color, RKR [3]bool, opBB, cBB Bitboard) Bitboard {
+func GetKingMoves(sq Square, cBB Bitboard) Bitboard {
+	// the squares next to the king, castling is left to
+	// Board.GetCastles
 	king := Bitboard(1) << sq
 	moves := (king << 8) |
 		(king >> 8) |
@@ -345,39 +319,5 @@ func GetKingMoves(sq Square, fullBB Bitboard, color Color, RKR [3]bool, opBB, cB
 		((king << 7) & ^FileH) |
 		((king >> 7) & ^FileA) |
 		((king >> 9) & ^FileH)
-	return (moves | GetCastles(color, fullBB, RKR, opBB)) &^ cBB
-}
-
-func GetCastles(color Color, fullBB Bitboard, RKR [3]bool, opBB Bitboard) Bitboard {
-	var moves Bitboard
-	var rank Bitboard
-	if color == White {
-		rank = Rank1
-	} else {
-		rank = Rank8
-	}
-	kingside := []Bitboard{FileF, FileG}
-	queenside := []Bitboard{FileD, FileC, FileB}
-	if !RKR[1] {
-		if !RKR[0] {
-			for i := range queenside {
-				if (queenside[i]&rank&fullBB) != 0 || (queenside[i]&rank&opBB != 0) {
-					break
-				} else {
-					moves |= FileC & rank
-				}
-			}
-		}
-		if !RKR[2] {
-			for i := range kingside {
-				if (kingside[i]&rank&fullBB) != 0 || (kingside[i]&rank&opBB != 0) {
-					break
-				} else {
-					moves |= FileG & rank
-				}
-			}
-		}
-	}
-	return moves
+	return moves &^ cBB
 }
-
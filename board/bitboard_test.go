@@ -0,0 +1,55 @@
+package chess
+
+import (
+  "testing"
+)
+
+func rayMoves(sq Square, fullBB Bitboard, directions [][2]int) Bitboard {
+  // walks every direction one square at a time, the slow reference
+  // for the slider move generators
+  var moves Bitboard
+  for _, d := range directions {
+    rank, file := int(sq/8)+d[0], int(sq%8)+d[1]
+    for rank >= 0 && rank < 8 && file >= 0 && file < 8 {
+      target := Square(rank*8 + file)
+      moves |= 1 << target
+      if fullBB.GetBit(target) {
+        break
+      }
+      rank, file = rank+d[0], file+d[1]
+    }
+  }
+  return moves
+}
+
+func TestSlidersStayOnBoard(t *testing.T) {
+  diagonal := [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+  straight := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+  // an empty board and one with blockers on both edge files
+  occupancies := []Bitboard{0, FileA | FileH | Rank4}
+  for _, occupied := range occupancies {
+    for sq := Square(0); sq < 64; sq++ {
+      full := occupied &^ (1 << sq)
+      if got, want := GetBishopMoves(sq, full, 0), rayMoves(sq, full, diagonal); got != want {
+        t.Errorf("bishop on %d with %x: got %x, want %x", sq, uint64(full), uint64(got), uint64(want))
+      }
+      if got, want := GetRookMoves(sq, full, 0), rayMoves(sq, full, straight); got != want {
+        t.Errorf("rook on %d with %x: got %x, want %x", sq, uint64(full), uint64(got), uint64(want))
+      }
+    }
+  }
+}
+
+func TestBlackPawnCapturesStayOnBoard(t *testing.T) {
+  // the pawn on h5 must not take the knight on a5 across the edge
+  board, err := NewBoardFromFEN("4k3/8/8/N6p/8/8/8/4K3 b - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  h5, a5 := NotationToIndex("h5"), NotationToIndex("a5")
+  for _, move := range board.GetAllLegalMoves(Black) {
+    if move.Start == h5 && move.End == a5 {
+      t.Errorf("h5 pawn captures on a5")
+    }
+  }
+}
@@ -20,6 +20,23 @@ type Board struct {
 
 	RKRmoved [2][3]bool
 
+	// the home squares of the rooks that castle, queen side first,
+	// which are only off the a and h files in Chess960
+	CastlingRooks [2][2]Square
+
+	Chess960 bool
+
+	// the rules of a chess variant, nil for normal chess
+	Variant Variant
+
+	// checks given by each side, counted by variants that need them
+	Checks [2]uint8
+
+	// pieces in hand to drop, and the squares of promoted pieces,
+	// which go back to being pawns when captured (Crazyhouse)
+	Pockets [2][7]uint8
+	Promoted Bitboard
+
 	EnPassantSquare *Square
 
 	MoveCounter uint8
@@ -28,7 +45,7 @@ type Board struct {
 
   allKnightMoves [64]Bitboard
   
-  TotalMoves uint8
+  TotalMoves int // half moves, counted from the first move of the game
 }
 
 type Move struct {
@@ -37,12 +54,15 @@ type Move struct {
   End Square
 
   Promotion Piece
+
+  // a piece put on End from the pocket, Start is unused
+  Drop Piece
 }
 
 func (b *Board) GetZobristHash() uint64 {
   var hash uint64
   for c := White; c <= Black; c ++ {
-    for p := Pawns; p< Kings; p++ {
+    for p := Pawns; p <= Kings; p++ {
       bb := b.PieceBB[c][p]
       for bb != 0 {
         s := Square(bits.TrailingZeros64(uint64(bb)))
@@ -71,6 +91,16 @@ func (b *Board) GetZobristHash() uint64 {
     castling |= (1 << 0)
   }
   hash ^= zobristKeys.Castling[castling]
+  for c := White; c <= Black; c++ {
+    if b.Checks[c] > 0 {
+      hash ^= zobristKeys.Checks[c][min(b.Checks[c], 3)]
+    }
+    for p := Pawns; p <= Queens; p++ {
+      if n := b.Pockets[c][p]; n > 0 {
+        hash ^= zobristKeys.Pockets[c][p][min(n, 31)]
+      }
+    }
+  }
   if b.EnPassantSquare != nil {
     hash ^= zobristKeys.EnPassant[uint8(*b.EnPassantSquare)%8] 
   }
@@ -84,6 +114,7 @@ func NewBoard() *Board {
   
 	b := &Board{
 		Turn: White,
+		CastlingRooks: defaultCastlingRooks,
 	}
 	b.PieceBB[White][Pawns] = Rank2
 	b.PieceBB[White][Knights] = (1 << NotationToIndex("b1")) | (1 << NotationToIndex("g1"))
@@ -102,6 +133,8 @@ func NewBoard() *Board {
 	b.EnPassantSquare = nil
   b.History = make(map[uint64]int)
   b.allKnightMoves = GenAllKnightMoves()
+  // the start position counts towards a repetition too
+  b.History[b.GetZobristHash()] = 1
 
 	return b
 }
@@ -122,6 +155,13 @@ func (b *Board) IsAttacked(color Color, sq Square) bool {
 }
 
 func (b *Board) IsCheck(color Color) bool {
+  if b.Variant != nil {
+    return b.Variant.InCheck(b, color)
+  }
+  return b.KingAttacked(color)
+}
+
+func (b *Board) KingAttacked(color Color) bool {
 	otherColor := color.Other()
   if (b.PieceBB[color][Kings] & b.AllAttacks(otherColor)) != 0 {
 		return true
@@ -155,6 +195,24 @@ func (b *Board) Is50Moves() bool {
 }
 
 func (b *Board) GetAllLegalMoves(color Color) []Move {
+  if b.Variant != nil {
+    return b.Variant.LegalMoves(b, color)
+  }
+  return b.generateMoves(color, true)
+}
+
+func (b *Board) StandardLegalMoves(color Color) []Move {
+  // the legal moves under the normal rules, whatever the variant
+  return b.generateMoves(color, true)
+}
+
+func (b *Board) PseudoLegalMoves(color Color) []Move {
+  // every move the pieces can make, including ones that leave the
+  // king in check, for variants with their own idea of legality
+  return b.generateMoves(color, false)
+}
+
+func (b *Board) generateMoves(color Color, legal bool) []Move {
   var  legalMoves[]Move
 
   for p := Pawns; p <= Kings; p++ {
@@ -175,7 +233,9 @@ func (b *Board) GetAllLegalMoves(color Color) []Move {
       case Queens:
         tempMoves = GetQueenMoves(start, b.FullBB, b.ColorBB[color])
       case Kings:
-        tempMoves = GetKingMoves(start, b.FullBB, color, b.RKRmoved[color], b.AllAttacks(color.Other()), b.ColorBB[color])
+        // pawn attacks on empty squares count too, for castling
+        attacked := b.AllAttacks(color.Other()) | pawnAttackSpan(b.PieceBB[color.Other()][Pawns], color.Other())
+        tempMoves = GetKingMoves(start, b.ColorBB[color]) | b.GetCastles(color, attacked)
       }
       // remove illegal moves
       for tempMoves != 0 {
@@ -184,14 +244,14 @@ func (b *Board) GetAllLegalMoves(color Color) []Move {
 
         if p == Pawns && (end.GetRank() == Rank8 || end.GetRank() == Rank1) {
           for _, promotion := range []Piece{Queens, Rooks, Bishops, Knights} {
-            test := Move{start, end, promotion}
-            if b.IsSimMoveLegal(test, color) {
+            test := Move{start, end, promotion, Empty}
+            if !legal || b.IsSimMoveLegal(test, color) {
               legalMoves = append(legalMoves, test)
             }
           }
         } else {
-          test := Move{start, end, Empty}
-          if b.IsSimMoveLegal(test, color) {
+          test := Move{start, end, Empty, Empty}
+          if !legal || b.IsSimMoveLegal(test, color) {
               legalMoves = append(legalMoves, test)
             }
         }
@@ -203,6 +263,9 @@ func (b *Board) GetAllLegalMoves(color Color) []Move {
 
 func (b *Board) IsSimMoveLegal(move Move, color Color) bool {
   simBoard := b.Clone()
+  // MovePiece moves for the side to move, which need not be color
+  // when counting the other side's moves
+  simBoard.Turn = color
   _ = simBoard.MovePiece(simBoard.GetPieceAt(move.Start, color), move)
 
   if simBoard.IsCheck(color) {
@@ -218,7 +281,14 @@ func (b *Board) Clone() *Board {
     MoveCounter: b.MoveCounter,
     EnPassantSquare: b.EnPassantSquare,
     TotalMoves: b.TotalMoves,
+    CastlingRooks: b.CastlingRooks,
+    Chess960: b.Chess960,
+    Variant: b.Variant,
+    Checks: b.Checks,
+    Pockets: b.Pockets,
+    Promoted: b.Promoted,
     History: make(map[uint64]int, len(b.History)),
+    allKnightMoves: b.allKnightMoves,
   }
   copy(cloned.PieceBB[0][:], b.PieceBB[0][:])
   copy(cloned.PieceBB[1][:], b.PieceBB[1][:])
@@ -232,6 +302,21 @@ func (b *Board) Clone() *Board {
   return cloned
 }
 
+func (b *Board) PlayMove(move Move) {
+  // plays a move for the side to move and hands the turn over,
+  // keeping the clocks and repetition history up to date. The
+  // halfmove clock starts again on a capture or a pawn move.
+  piece := b.GetPieceAt(move.Start, b.Turn)
+  if b.MovePiece(piece, move) || (piece == Pawns && move.Drop == Empty) {
+    b.MoveCounter = 0
+  } else if b.MoveCounter < 255 {
+    b.MoveCounter++
+  }
+  b.TotalMoves++
+  b.Turn = b.Turn.Other()
+  b.History[b.GetZobristHash()]++
+}
+
 func (b *Board) MovePiece(piece Piece, move Move) bool {
 	// moves piece at start to end and promotes if specified
 	// returns true if there is a piece captured
@@ -246,40 +331,67 @@ func (b *Board) MovePiece(piece Piece, move Move) bool {
 	endRank := end.GetRank()
 
 	var capture bool = false
+	captured := Empty
 
+	if move.Drop != Empty {
+		b.drop(color, move)
+		return false
+	}
 	if piece == Empty {
 		return false
 	}
+	if side, ok := b.CastlingSide(move); ok && piece == Kings {
+		b.castle(color, side)
+		if b.Variant != nil {
+			b.Variant.AfterMove(b, move, piece, Empty)
+		}
+		return false
+	}
 	// checks through the other colored bb to remove captured piece if relevent
 	for p := Pawns; p <= Kings; p++ {
 		if b.PieceBB[otherColor][p]&(1<<end) != 0 {
 			b.PieceBB[otherColor][p].ZeroBit(end)
 			capture = true
+			captured = p
 		}
 	}
-	// checks if castling
+	// en passant takes the pawn behind the square moved to
+	if piece == Pawns && b.EnPassantSquare != nil && end == *b.EnPassantSquare && startFile != endFile {
+		if color == White {
+			b.PieceBB[otherColor][Pawns].ZeroBit(end - 8)
+		} else {
+			b.PieceBB[otherColor][Pawns].ZeroBit(end + 8)
+		}
+		capture = true
+		captured = Pawns
+	}
+	// a promoted piece is taken as the pawn it was, and the mark
+	// follows the piece that moves
+	if capture && b.Promoted.GetBit(end) {
+		captured = Pawns
+	}
+	b.Promoted.ZeroBit(end)
+	if b.Promoted.GetBit(start) || promotion != Empty {
+		b.Promoted.ZeroBit(start)
+		b.Promoted.SetBit(end)
+	}
+	// a rook taken on its starting square can no longer castle
+	if capture {
+		rookHomes := b.CastlingRooks[otherColor]
+		if end == rookHomes[QueenSide] {
+			b.RKRmoved[otherColor][0] = true
+		} else if end == rookHomes[KingSide] {
+			b.RKRmoved[otherColor][2] = true
+		}
+	}
+	// castling rights go once the king or a castling rook moves
 	b.EnPassantSquare = nil
 	if piece == Kings {
-		if startFile == FileE && endFile == FileC {
-			if color == White {
-				b.MovePiece(Rooks, Move{1, 4, Empty})
-			} else {
-				b.MovePiece(Rooks, Move{56, 59, Empty})
-			}
-			b.RKRmoved[color][2] = true
-		} else if startFile == FileE && endFile == FileG {
-			if color == White {
-				b.MovePiece(Rooks, Move{7, 5, Empty})
-			} else {
-				b.MovePiece(Rooks, Move{63, 61, Empty})
-			}
-			b.RKRmoved[color][0] = true
-		}
 		b.RKRmoved[color][1] = true
 	} else if piece == Rooks {
-		if startFile == FileA {
+		if start == b.CastlingRooks[color][QueenSide] {
 			b.RKRmoved[color][0] = true
-		} else if startFile == FileH {
+		} else if start == b.CastlingRooks[color][KingSide] {
 			b.RKRmoved[color][2] = true
 		}
     // or en passant square created
@@ -305,6 +417,9 @@ func (b *Board) MovePiece(piece Piece, move Move) bool {
 	b.PieceBB[color][piece].ZeroBit(start)
 	// pushes through the changed piecebb to affect all other bbs
 	b.CombineBB()
+	if b.Variant != nil {
+		b.Variant.AfterMove(b, move, piece, captured)
+	}
 	return capture
 }
 
@@ -396,7 +511,7 @@ func (b *Board) GetPawnAttacks(color Color) Bitboard {
 	var moves Bitboard
 	pawns := b.PieceBB[color][Pawns]
 	if color == White {
-		moves = (((pawns << 7) & ^FileA) & b.ColorBB[Black]) | (((pawns << 9) & ^FileH) & b.ColorBB[Black])
+		moves = (((pawns << 7) & ^FileH) & b.ColorBB[Black]) | (((pawns << 9) & ^FileA) & b.ColorBB[Black])
     if b.EnPassantSquare != nil {
 			epTarget := *b.EnPassantSquare
 			if epTarget.GetRank() == Rank6 {
@@ -483,8 +598,11 @@ func (b *Board) GetKingMoves(color Color, attacks bool) Bitboard {
 	king := b.PieceBB[color][Kings]
 	var moves Bitboard
 	loc := Square(bits.TrailingZeros64(uint64(king)))
-	// treats opBB as empty here
-	moves |= GetKingMoves(loc, b.FullBB, color, b.RKRmoved[color], opBB, b.ColorBB[color])
+	moves |= GetKingMoves(loc, b.ColorBB[color])
+	// castling is no attack
+	if !attacks {
+		moves |= b.GetCastles(color, opBB)
+	}
 	return moves
 }
 
@@ -530,22 +648,10 @@ func NewBoardFromFEN(fen string) (*Board, error) {
 		}
 	}
 
-	// Castling
+	// Castling, see parseCastling for Chess960
 	if len(parts) > 2 {
-		castlingRights := parts[2]
-		b.RKRmoved = [2][3]bool{} 
-		
-		if castlingRights != "-" {
-			if !strings.ContainsRune(castlingRights, 'K') { b.RKRmoved[White][2] = true } // White King side rook (h1) moved
-			if !strings.ContainsRune(castlingRights, 'Q') { b.RKRmoved[White][0] = true } // White Queen side rook (a1) moved
-			if !strings.ContainsRune(castlingRights, 'k') { b.RKRmoved[Black][2] = true } // Black King side rook (h8) moved
-			if !strings.ContainsRune(castlingRights, 'q') { b.RKRmoved[Black][0] = true } // Black Queen side rook (a8) moved
-		}
-		if !strings.ContainsRune(castlingRights, 'K') && !strings.ContainsRune(castlingRights, 'Q') {
-			b.RKRmoved[White][1] = true
-		}
-		if !strings.ContainsRune(castlingRights, 'k') && !strings.ContainsRune(castlingRights, 'q') {
-			b.RKRmoved[Black][1] = true
+		if err := b.parseCastling(parts[2]); err != nil {
+			return nil, err
 		}
 	}
 
@@ -564,7 +670,7 @@ func NewBoardFromFEN(fen string) (*Board, error) {
 	// 50 moes
 	if len(parts) > 4 {
 		halfMoveClock, err := strconv.Atoi(parts[4])
-		if err != nil {
+		if err != nil || halfMoveClock < 0 || halfMoveClock > 255 {
 			return nil, fmt.Errorf("invalid FEN string")
 		}
 		b.MoveCounter = uint8(halfMoveClock)
@@ -572,10 +678,13 @@ func NewBoardFromFEN(fen string) (*Board, error) {
 
   if len(parts) > 5 {
 		clock, err := strconv.Atoi(parts[5])
-		if err != nil {
+		if err != nil || clock < 0 {
 			return nil, fmt.Errorf("invalid FEN string")
 		}
-		b.TotalMoves = uint8(clock)
+		b.TotalMoves = max(clock-1, 0) * 2
+		if b.Turn == Black {
+			b.TotalMoves++
+		}
 	}
 	b.CombineBB()
 	b.History[b.GetZobristHash()] = 1
@@ -583,6 +692,53 @@ func NewBoardFromFEN(fen string) (*Board, error) {
 	return b, nil
 }
 
+func (b *Board) FEN() string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			sq := Square(rank*8 + file)
+			c := White
+			p := b.GetPieceAt(sq, White)
+			if p == Empty {
+				c = Black
+				p = b.GetPieceAt(sq, Black)
+			}
+			if p == Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteRune(getSymbol(c, p))
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if b.Turn == White {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	sb.WriteString(b.castlingField(false))
+
+	if b.EnPassantSquare != nil {
+		sb.WriteString(" " + IndexToNotation(*b.EnPassantSquare))
+	} else {
+		sb.WriteString(" -")
+	}
+	sb.WriteString(fmt.Sprintf(" %d %d", b.MoveCounter, int(b.TotalMoves)/2+1))
+	return sb.String()
+}
+
 func (b *Board) ClearBoard() {
 	for c := White; c <= Black; c++ {
 		for p := Pawns; p <= Kings; p++ {
@@ -597,6 +753,11 @@ func (b *Board) ClearBoard() {
 	b.TotalMoves = 0
 	b.History = make(map[uint64]int)
 	b.RKRmoved = [2][3]bool{}
+	b.CastlingRooks = defaultCastlingRooks
+	b.Chess960 = false
+	b.Checks = [2]uint8{}
+	b.Pockets = [2][7]uint8{}
+	b.Promoted = 0
 }
 
 func charToPiece(char rune) (Piece, Color, error) {
@@ -622,7 +783,7 @@ func charToPiece(char rune) (Piece, Color, error) {
 func IndexToNotation(sq Square) string {
   var indexToNotationMap = map[Square]string{
 	0: "a1", 1: "b1", 2: "c1", 3: "d1", 4: "e1", 5: "f1", 6: "g1", 7: "h1",
-	8: "a2", 9: "b2", 10: "c2", 11: "d2", 12: "e2", 13: "d2", 14: "g2", 15: "h2",
+	8: "a2", 9: "b2", 10: "c2", 11: "d2", 12: "e2", 13: "f2", 14: "g2", 15: "h2",
 	16: "a3", 17: "b3", 18: "c3", 19: "d3", 20: "e3", 21: "f3", 22: "g3", 23: "h3",
 	24: "a4", 25: "b4", 26: "c4", 27: "d4", 28: "e4", 29: "f4", 30: "g4", 31: "h4",
 	32: "a5", 33: "b5", 34: "c5", 35: "d5", 36: "e5", 37: "f5", 38: "g5", 39: "h5",
@@ -646,6 +807,39 @@ func NotationToIndex (str string) Square {
   return nti[str]
 }
 
+func (m Move) String() string {
+  // long algebraic notation as used by UCI, e.g. e2e4 or e7e8q, and
+  // N@f3 for a drop
+  if m.Drop != Empty {
+    return strings.ToUpper(PieceToChar(m.Drop, White)) + "@" + IndexToNotation(m.End)
+  }
+  return IndexToNotation(m.Start) + IndexToNotation(m.End) + PieceToChar(m.Promotion, White)
+}
+
+func ParseMove(str string) (Move, error) {
+  // reads a move in long algebraic notation, e.g. e2e4 or e7e8q
+  if len(str) == 4 && str[1] == '@' {
+    return parseDrop(str)
+  }
+  if len(str) != 4 && len(str) != 5 {
+    return Move{}, fmt.Errorf("invalid move: %q", str)
+  }
+  for _, sq := range []string{str[0:2], str[2:4]} {
+    if sq[0] < 'a' || sq[0] > 'h' || sq[1] < '1' || sq[1] > '8' {
+      return Move{}, fmt.Errorf("invalid square in move: %q", str)
+    }
+  }
+  move := Move{NotationToIndex(str[0:2]), NotationToIndex(str[2:4]), Empty, Empty}
+  if len(str) == 5 {
+    promotion, _, err := charToPiece(rune(str[4]))
+    if err != nil || promotion == Pawns || promotion == Kings {
+      return Move{}, fmt.Errorf("invalid promotion in move: %q", str)
+    }
+    move.Promotion = promotion
+  }
+  return move, nil
+}
+
 func PieceToChar(p Piece, c Color) string {
 	char := ""
 	switch p {
@@ -0,0 +1,94 @@
+package chess
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestCloneKeepsKnightMoves(t *testing.T) {
+  board := NewBoard()
+  cloned := board.Clone()
+  if got, want := len(cloned.GetAllLegalMoves(White)), len(board.GetAllLegalMoves(White)); got != want || want != 20 {
+    t.Errorf("clone has %d moves from the start, the original %d, want 20", got, want)
+  }
+}
+
+func TestNotationRoundTrip(t *testing.T) {
+  for sq := Square(0); sq < 64; sq++ {
+    name := IndexToNotation(sq)
+    want := string(rune('a'+sq%8)) + string(rune('1'+sq/8))
+    if name != want || NotationToIndex(name) != sq {
+      t.Errorf("square %d: named %q, want %q", sq, name, want)
+    }
+  }
+}
+
+func TestFENRoundTrip(t *testing.T) {
+  fens := []string{
+    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+    "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+    "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2",
+    "8/8/4k3/8/8/4K3/8/8 b - - 37 61",
+    "r3k3/8/8/8/8/8/8/4K2R b Kq - 3 12",
+    "8/8/4k3/8/8/4K3/8/8 w - - 12 300",
+    "8/8/4k3/8/8/4K3/8/8 b - - 99 1000",
+  }
+  for _, fen := range fens {
+    board, err := NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatalf("%s: %v", fen, err)
+    }
+    if got := board.FEN(); got != fen {
+      t.Errorf("FEN() = %q, want %q", got, fen)
+    }
+  }
+}
+
+func TestPawnAttacksStayOnBoard(t *testing.T) {
+  // the pawn on a2 does not attack the king on h2
+  board, err := NewBoardFromFEN("8/8/8/8/8/8/P6k/4K3 b - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if board.IsCheck(Black) {
+    t.Errorf("black is in check from a pawn on the other edge")
+  }
+}
+
+func TestEnPassantRemovesPawn(t *testing.T) {
+  board, err := NewBoardFromFEN("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  board.PlayMove(Move{Start: NotationToIndex("e5"), End: NotationToIndex("d6")})
+  if board.GetPieceAt(NotationToIndex("d5"), Black) != Empty {
+    t.Errorf("the pawn taken en passant is still on d5")
+  }
+}
+
+func TestFENCounters(t *testing.T) {
+  // the halfmove clock after each move, and the fullmove number
+  board := NewBoard()
+  for _, tt := range []struct {
+    move string
+    counters string
+  }{
+    {"e2e4", "0 1"},
+    {"e7e5", "0 2"},
+    {"g1f3", "1 2"},
+    {"b8c6", "2 3"},
+    {"f1b5", "3 3"},
+    {"a7a6", "0 4"},
+    {"b5c6", "0 4"},
+    {"g8f6", "1 5"},
+  } {
+    move, err := ParseMove(tt.move)
+    if err != nil {
+      t.Fatal(err)
+    }
+    board.PlayMove(move)
+    if fen := board.FEN(); !strings.HasSuffix(fen, " "+tt.counters) {
+      t.Errorf("after %s: %s, want counters %s", tt.move, fen, tt.counters)
+    }
+  }
+}
@@ -0,0 +1,284 @@
+package chess
+
+import (
+  "fmt"
+  "math/bits"
+  "strings"
+)
+
+// Chess960 (Fischer Random). The back rank pieces start on shuffled
+// files, with the bishops on opposite colours and the king somewhere
+// between the rooks. Castling puts king and rook on the same squares
+// as in normal chess, and on a Chess960 board a castling move is the
+// king taking its own rook, e.g. e1h1, as UCI_Chess960 expects. Normal
+// boards keep the usual e1g1.
+
+const (
+  QueenSide = 0
+  KingSide = 1
+)
+
+// standard castling rooks, by colour and side
+var defaultCastlingRooks = [2][2]Square{{0, 7}, {56, 63}}
+
+// the files of the two knights among the five squares left once the
+// bishops and queen are placed, by Scharnagl's numbering
+var knightPlacements = [10][2]int{
+  {0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+  {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+func NewChess960Board(id int) (*Board, error) {
+  // start position number id from 0 to 959, where 518 is the normal
+  // start position
+  if id < 0 || id >= 960 {
+    return nil, fmt.Errorf("chess960 position must be between 0 and 959, not %d", id)
+  }
+  var rank [8]Piece
+  n := id
+  rank[(n%4)*2+1] = Bishops
+  n /= 4
+  rank[(n%4)*2] = Bishops
+  n /= 4
+  place := func(piece Piece, index int) {
+    // puts piece on the index-th empty file
+    for file := range rank {
+      if rank[file] != Empty {
+        continue
+      }
+      if index == 0 {
+        rank[file] = piece
+        return
+      }
+      index--
+    }
+  }
+  place(Queens, n%6)
+  n /= 6
+  knights := knightPlacements[n]
+  place(Knights, knights[1])
+  place(Knights, knights[0])
+  place(Rooks, 0)
+  place(Kings, 0)
+  place(Rooks, 0)
+
+  b := NewBoard()
+  b.ClearBoard()
+  b.Chess960 = true
+  b.PieceBB[White][Pawns] = Rank2
+  b.PieceBB[Black][Pawns] = Rank7
+  rooks := 0
+  for file, piece := range rank {
+    b.PieceBB[White][piece].SetBit(Square(file))
+    b.PieceBB[Black][piece].SetBit(Square(56 + file))
+    if piece == Rooks {
+      b.CastlingRooks[White][rooks] = Square(file)
+      b.CastlingRooks[Black][rooks] = Square(56 + file)
+      rooks++
+    }
+  }
+  b.CombineBB()
+  b.History[b.GetZobristHash()] = 1
+  return b, nil
+}
+
+func (b *Board) CastlingSide(move Move) (int, bool) {
+  // the side a king move castles to, if it is a castling move
+  color := b.colorAt(move.Start)
+  if !b.PieceBB[color][Kings].GetBit(move.Start) {
+    return 0, false
+  }
+  if b.PieceBB[color][Rooks].GetBit(move.End) {
+    for side, rook := range b.CastlingRooks[color] {
+      if rook == move.End {
+        return side, true
+      }
+    }
+    return 0, false
+  }
+  if !b.Chess960 && move.Start.GetRank() == move.End.GetRank() {
+    switch int(move.End) - int(move.Start) {
+    case 2:
+      return KingSide, true
+    case -2:
+      return QueenSide, true
+    }
+  }
+  return 0, false
+}
+
+func castlingSquares(king Square, side int) (Square, Square) {
+  // where king and rook end up, on the king's rank
+  rank := king &^ 7
+  if side == KingSide {
+    return rank + 6, rank + 5
+  }
+  return rank + 2, rank + 3
+}
+
+func rankSpan(a, b Square) Bitboard {
+  // the squares from a to b on one rank, both included
+  if a > b {
+    a, b = b, a
+  }
+  return (Bitboard(1)<<(b+1) - 1) &^ (Bitboard(1)<<a - 1)
+}
+
+func (b *Board) GetCastles(color Color, attacked Bitboard) Bitboard {
+  // The squares the king can move to to castle, the rook's square on
+  // a Chess960 board. The king may not castle out of, through or into
+  // check and apart from the two castling pieces every square either
+  // of them crosses has to be empty.
+  var moves Bitboard
+  king := b.PieceBB[color][Kings]
+  if b.RKRmoved[color][1] || king == 0 || king&attacked != 0 {
+    return moves
+  }
+  kingSq := Square(bits.TrailingZeros64(uint64(king)))
+  for side, rookSq := range b.CastlingRooks[color] {
+    if b.RKRmoved[color][side*2] || !b.PieceBB[color][Rooks].GetBit(rookSq) {
+      continue
+    }
+    kingTo, rookTo := castlingSquares(kingSq, side)
+    others := b.FullBB &^ (king | 1<<rookSq)
+    if (rankSpan(kingSq, kingTo)|rankSpan(rookSq, rookTo))&others != 0 {
+      continue
+    }
+    if rankSpan(kingSq, kingTo)&attacked != 0 {
+      continue
+    }
+    if b.Chess960 {
+      moves.SetBit(rookSq)
+    } else {
+      moves.SetBit(kingTo)
+    }
+  }
+  return moves
+}
+
+func (b *Board) castle(color Color, side int) {
+  // moves king and rook to their castled squares
+  kingSq := Square(bits.TrailingZeros64(uint64(b.PieceBB[color][Kings])))
+  rookSq := b.CastlingRooks[color][side]
+  kingTo, rookTo := castlingSquares(kingSq, side)
+  b.PieceBB[color][Kings].ZeroBit(kingSq)
+  b.PieceBB[color][Rooks].ZeroBit(rookSq)
+  b.PieceBB[color][Kings].SetBit(kingTo)
+  b.PieceBB[color][Rooks].SetBit(rookTo)
+  b.RKRmoved[color][1] = true
+  b.RKRmoved[color][side*2] = true
+  b.EnPassantSquare = nil
+  b.CombineBB()
+}
+
+func (b *Board) parseCastling(field string) error {
+  // Reads the castling field of a FEN. Besides KQkq this takes the
+  // rook files used by Shredder-FEN (HAha) and X-FEN (KQkq, with a
+  // file only for a rook that is not the outermost one). Files, or a
+  // king or rook off their usual squares, make the board Chess960.
+  b.RKRmoved = [2][3]bool{{true, true, true}, {true, true, true}}
+  b.CastlingRooks = defaultCastlingRooks
+  if field == "-" {
+    return nil
+  }
+  for _, char := range field {
+    color := White
+    backRank := Rank1
+    if char >= 'a' && char <= 'z' {
+      color = Black
+      backRank = Rank8
+    }
+    king := b.PieceBB[color][Kings] & backRank
+    if king == 0 {
+      return fmt.Errorf("invalid castling rights %q: no king on the back rank", field)
+    }
+    kingSq := Square(bits.TrailingZeros64(uint64(king)))
+    rooks := b.PieceBB[color][Rooks] & backRank
+    var rookSq Square
+    switch upper := strings.ToUpper(string(char))[0]; upper {
+    case 'K':
+      // the outermost rook on the king's right
+      rooks &= ^(Bitboard(1)<<(kingSq+1) - 1)
+      if rooks == 0 {
+        return fmt.Errorf("invalid castling rights %q: no rook", field)
+      }
+      rookSq = Square(63 - bits.LeadingZeros64(uint64(rooks)))
+    case 'Q':
+      rooks &= Bitboard(1)<<kingSq - 1
+      if rooks == 0 {
+        return fmt.Errorf("invalid castling rights %q: no rook", field)
+      }
+      rookSq = Square(bits.TrailingZeros64(uint64(rooks)))
+    default:
+      if upper < 'A' || upper > 'H' {
+        return fmt.Errorf("invalid castling rights %q", field)
+      }
+      rookSq = kingSq&^7 + Square(upper-'A')
+      if !rooks.GetBit(rookSq) {
+        return fmt.Errorf("invalid castling rights %q: no rook on %s", field, IndexToNotation(rookSq))
+      }
+      b.Chess960 = true
+    }
+    side := QueenSide
+    if rookSq > kingSq {
+      side = KingSide
+    }
+    b.CastlingRooks[color][side] = rookSq
+    b.RKRmoved[color][1] = false
+    b.RKRmoved[color][side*2] = false
+    if kingSq%8 != 4 || rookSq != defaultCastlingRooks[color][side] {
+      b.Chess960 = true
+    }
+  }
+  return nil
+}
+
+func (b *Board) castlingField(shredder bool) string {
+  // KQkq, with X-FEN files for rooks that KQkq would not pick out,
+  // or the rook files of every right for Shredder-FEN
+  field := ""
+  for _, color := range []Color{White, Black} {
+    if b.RKRmoved[color][1] {
+      continue
+    }
+    for _, side := range []int{KingSide, QueenSide} {
+      if b.RKRmoved[color][side*2] {
+        continue
+      }
+      rookSq := b.CastlingRooks[color][side]
+      char := "KQ"[1-side : 2-side]
+      if shredder || b.hasOuterRook(color, side) {
+        char = string(rune('A' + rookSq%8))
+      }
+      if color == Black {
+        char = strings.ToLower(char)
+      }
+      field += char
+    }
+  }
+  if field == "" {
+    return "-"
+  }
+  return field
+}
+
+func (b *Board) hasOuterRook(color Color, side int) bool {
+  // whether another rook stands further out than the castling rook
+  // on the back rank, so K or Q would name the wrong one
+  rookSq := b.CastlingRooks[color][side]
+  backRank := rookSq &^ 7
+  var outer Bitboard
+  if side == KingSide {
+    outer = rankSpan(rookSq, backRank+7) &^ (1 << rookSq)
+  } else {
+    outer = rankSpan(backRank, rookSq) &^ (1 << rookSq)
+  }
+  return b.PieceBB[color][Rooks]&outer != 0
+}
+
+func (b *Board) ShredderFEN() string {
+  // the FEN with the castling rights as rook files, e.g. HAha
+  fields := strings.Split(b.FEN(), " ")
+  fields[2] = b.castlingField(true)
+  return strings.Join(fields, " ")
+}
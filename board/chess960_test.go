@@ -0,0 +1,143 @@
+package chess
+
+import "testing"
+
+func TestChess960Perft(t *testing.T) {
+  // the first positions of the published Chess960 perft results,
+  // nodes[i] is the count at depth i+1
+  tests := []struct {
+    fen string
+    nodes []uint64
+  }{
+    {"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []uint64{21, 528, 12189, 326672}},
+    {"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []uint64{21, 807, 18002, 667366}},
+    {"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", []uint64{20, 479, 10471, 273318}},
+    {"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", []uint64{22, 593, 13440, 382958}},
+    {"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", []uint64{28, 1120, 31058, 1171749}},
+    {"qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w HEhe - 1 9", []uint64{29, 899, 26578, 824055}},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.fen, err)
+    }
+    if !board.Chess960 {
+      t.Errorf("%s: not read as a Chess960 board", tt.fen)
+    }
+    for i, want := range tt.nodes {
+      if got := Perft(board, i+1); got != want {
+        t.Errorf("%s depth %d: got %d nodes, want %d", tt.fen, i+1, got, want)
+      }
+    }
+  }
+}
+
+func TestChess960StartPositions(t *testing.T) {
+  tests := []struct {
+    id int
+    fen string
+  }{
+    {518, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+    {0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"},
+    {959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w KQkq - 0 1"},
+  }
+  for _, tt := range tests {
+    board, err := NewChess960Board(tt.id)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if got := board.FEN(); got != tt.fen {
+      t.Errorf("position %d: got %s, want %s", tt.id, got, tt.fen)
+    }
+  }
+
+  // 518 plays exactly like the normal start, apart from writing
+  // castling as the king taking its rook
+  board, _ := NewChess960Board(518)
+  standard := NewBoard()
+  if board.GetZobristHash() != standard.GetZobristHash() || board.CastlingRooks != standard.CastlingRooks {
+    t.Errorf("position 518 differs from the start position")
+  }
+  if got, want := Perft(board, 4), Perft(standard, 4); got != want {
+    t.Errorf("position 518: %d nodes at depth 4, want %d", got, want)
+  }
+
+  for _, id := range []int{-1, 960} {
+    if _, err := NewChess960Board(id); err == nil {
+      t.Errorf("position %d accepted", id)
+    }
+  }
+}
+
+func TestChess960CastlingFields(t *testing.T) {
+  tests := []struct {
+    xfen string
+    shredder string
+  }{
+    {"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9"},
+    {"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w KQ - 1 9", "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9"},
+    {"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w kq - 0 9", "qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9"},
+    // the castling rook is not the outermost one, so X-FEN names its file
+    {"rr2k3/8/8/8/8/8/8/RR2K2R w KBb - 0 1", "rr2k3/8/8/8/8/8/8/RR2K2R w HBb - 0 1"},
+    {"4k3/8/8/8/8/8/8/R1RK3R w KC - 0 1", "4k3/8/8/8/8/8/8/R1RK3R w HC - 0 1"},
+  }
+  for _, tt := range tests {
+    for _, fen := range []string{tt.xfen, tt.shredder} {
+      board, err := NewBoardFromFEN(fen)
+      if err != nil {
+        t.Fatalf("%s: %v", fen, err)
+      }
+      if got := board.FEN(); got != tt.xfen {
+        t.Errorf("%s: FEN() = %s, want %s", fen, got, tt.xfen)
+      }
+      if got := board.ShredderFEN(); got != tt.shredder {
+        t.Errorf("%s: ShredderFEN() = %s, want %s", fen, got, tt.shredder)
+      }
+    }
+  }
+}
+
+func TestChess960Castling(t *testing.T) {
+  // castling is written as the king taking its rook, and works when
+  // the king or rook already stands where it ends up
+  tests := []struct {
+    name string
+    fen string
+    move string
+    after string // "" when the move is illegal
+  }{
+    {"king already on g1", "4k3/8/8/8/8/8/8/6KR w H - 0 1", "g1h1", "4k3/8/8/8/8/8/8/5RK1 b - - 1 1"},
+    {"rook already on f1", "4k3/8/8/8/8/8/8/4KR2 w F - 0 1", "e1f1", "4k3/8/8/8/8/8/8/5RK1 b - - 1 1"},
+    {"king already on c1", "4k3/8/8/8/8/8/8/R1K5 w A - 0 1", "c1a1", "4k3/8/8/8/8/8/8/2KR4 b - - 1 1"},
+    {"rook right of the king", "4k3/8/8/8/8/8/8/1K1R4 w D - 0 1", "b1d1", "4k3/8/8/8/8/8/8/5RK1 b - - 1 1"},
+    {"king and rook swap", "4k3/8/8/8/8/8/8/2RK4 w C - 0 1", "d1c1", "4k3/8/8/8/8/8/8/2KR4 b - - 1 1"},
+    {"rook left of the king", "4k3/8/8/8/8/8/8/5RK1 w F - 0 1", "g1f1", "4k3/8/8/8/8/8/8/2KR4 b - - 1 1"},
+    {"black, king already on g8", "6kr/8/8/8/8/8/8/4K3 b h - 0 1", "g8h8", "5rk1/8/8/8/8/8/8/4K3 w - - 1 2"},
+    {"piece on the rook's path", "4k3/8/8/8/8/8/8/RN1K4 w A - 0 1", "d1a1", ""},
+    {"piece on the king's path", "4k3/8/8/8/8/8/8/1K3B1R w H - 0 1", "b1h1", ""},
+    {"king's target attacked", "4k1r1/8/8/8/8/8/8/1K5R w H - 0 1", "b1h1", ""},
+    {"king passes an attacked square", "4kr2/8/8/8/8/8/8/1K5R w H - 0 1", "b1h1", ""},
+    {"rook attacked", "4k2r/8/8/8/8/8/8/1K5R w H - 0 1", "b1h1", "4k2r/8/8/8/8/8/8/5RK1 b - - 1 1"},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.name, err)
+    }
+    move, _ := ParseMove(tt.move)
+    if legal := board.IsLegal(move); legal != (tt.after != "") {
+      t.Errorf("%s: %s legal %v, want %v", tt.name, tt.move, legal, !legal)
+      continue
+    }
+    if tt.after == "" {
+      continue
+    }
+    if side, ok := board.CastlingSide(move); !ok || (side == KingSide) != (move.End%8 > move.Start%8) {
+      t.Errorf("%s: %s not read as castling", tt.name, tt.move)
+    }
+    board.PlayMove(move)
+    if got := board.FEN(); got != tt.after {
+      t.Errorf("%s: after %s got %s, want %s", tt.name, tt.move, got, tt.after)
+    }
+  }
+}
@@ -0,0 +1,61 @@
+package chess
+
+import (
+  "fmt"
+  "math/bits"
+)
+
+// Drops put a piece from the side's pocket on an empty square, as in
+// Crazyhouse. They are Moves with Drop set to the piece and End to
+// the square, written N@f3.
+
+func (b *Board) drop(color Color, move Move) {
+  b.PieceBB[color][move.Drop].SetBit(move.End)
+  b.Pockets[color][move.Drop]--
+  b.Promoted.ZeroBit(move.End)
+  b.EnPassantSquare = nil
+  b.CombineBB()
+  if b.Variant != nil {
+    b.Variant.AfterMove(b, move, move.Drop, Empty)
+  }
+}
+
+func (b *Board) DropMoves(color Color) []Move {
+  // every legal drop from color's pocket. Pawns can not go on the
+  // first or last rank, and when in check only drops that block it
+  // are legal.
+  var moves []Move
+  inCheck := b.KingAttacked(color)
+  for p := Pawns; p <= Queens; p++ {
+    if b.Pockets[color][p] == 0 {
+      continue
+    }
+    targets := ^b.FullBB
+    if p == Pawns {
+      targets &^= Rank1 | Rank8
+    }
+    for targets != 0 {
+      sq := Square(bits.TrailingZeros64(uint64(targets)))
+      targets &= targets - 1
+      move := Move{End: sq, Drop: p}
+      if inCheck {
+        sim := b.Clone()
+        sim.drop(color, move)
+        if sim.KingAttacked(color) {
+          continue
+        }
+      }
+      moves = append(moves, move)
+    }
+  }
+  return moves
+}
+
+func parseDrop(str string) (Move, error) {
+  // N@f3, or P@e4 for a pawn
+  piece, _, err := charToPiece(rune(str[0]))
+  if err != nil || piece == Kings || str[2] < 'a' || str[2] > 'h' || str[3] < '1' || str[3] > '8' {
+    return Move{}, fmt.Errorf("invalid drop: %q", str)
+  }
+  return Move{End: NotationToIndex(str[2:4]), Drop: piece}, nil
+}
@@ -4,157 +4,331 @@ import (
   "math/bits"
   "math"
 )
-func  Evaluate(board *Board) int {
-  pawnValue := 100
-  knightValue := 300
-  bishopValue := 300
-  rookValue := 500
-  queenValue := 900
-  score := 0
-  totalPieces := bits.OnesCount64(uint64(board.FullBB))
-	lateGame := totalPieces < 10
+
+// A pair of weights for the same term, one for the middlegame and one
+// for the endgame. Evaluate blends the two by the game phase.
+type Tapered struct {
+  MG int
+  EG int
+}
+
+// Every weight Evaluate uses. Piece-square tables are written from
+// white's side with a1 first, black reads them mirrored.
+type EvalParams struct {
+  Material [7]Tapered
+  PSTMG [7][64]int
+  PSTEG [7][64]int
+  BishopPair Tapered
+  Mobility Tapered // per legal move
+  Attack Tapered // per attacked square
+
+  // pawn structure, per pawn
+  DoubledPawn Tapered
+  IsolatedPawn Tapered
+  BackwardPawn Tapered
+  ConnectedPawn Tapered
+  PassedPawn [8]Tapered // by rank from the pawn's side
+  // endgame only, per square of king distance and rank advanced
+  PassedOwnKing int
+  PassedEnemyKing int
+
+  // king safety
+  KingShield [2]Tapered // own pawn one and two ranks in front of the king
+  KingStorm [4]Tapered // enemy pawn one to four ranks in front of the king
+  KingOpenFile Tapered // on or next to the king's file
+  KingHalfOpenFile Tapered
+  KingAttackWeights [7]int // middlegame, per king zone square attacked
+  KingAttackerScale [6]int // percent, by number of attacking piece kinds
+}
+
+// Checkmate scores MateScore for the winner. It is the largest int32
+// and its negation fits as well, unlike math.MinInt32.
+const MateScore = math.MaxInt32
+
+// phase weight of each piece, a full set of pieces adds up to MaxPhase
+var phaseWeights = [7]int{0, 0, 1, 1, 2, 4, 0}
+
+const MaxPhase = 24
+
+// the weights Evaluate uses, swap in another set to change the evaluation
+var ActiveEvalParams = &DefaultEvalParams
+
+func GamePhase(board *Board) int {
+  // MaxPhase with every piece on the board down to 0 with only
+  // kings and pawns left
+  phase := 0
+  for c := White; c <= Black; c++ {
+    for p := Knights; p <= Queens; p++ {
+      phase += bits.OnesCount64(uint64(board.PieceBB[c][p])) * phaseWeights[p]
+    }
+  }
+  return min(phase, MaxPhase)
+}
+
+func Taper(score Tapered, phase int) int {
+  return (score.MG*phase + score.EG*(MaxPhase-phase)) / MaxPhase
+}
+
+func Evaluate(board *Board) int {
+  return EvaluateWith(board, ActiveEvalParams)
+}
+
+func EvaluateWith(board *Board, params *EvalParams) int {
+  if result, over := board.Outcome(); over {
+    switch {
+    case result.Draw:
+      return 0
+    case result.Winner == White:
+      return MateScore
+    }
+    return -MateScore
+  }
   if (board.Is50Moves() || board.IsThreefold() || board.IsStalemate()){
 
     return 0
   }
   if board.IsCheckmate() {
     if board.Turn == Black {
-      return math.MaxInt32
+      return MateScore
     }
-    return math.MinInt32
+    return -MateScore
   }
 
-  for c := White; c <= Black; c++ {
-        multiplier := 1
-        if c == Black {
-            multiplier = -1
-        }
-    bishops := bits.OnesCount64(uint64(board.PieceBB[c][Bishops]))
-    score += bits.OnesCount64(uint64(board.PieceBB[c][Pawns])) * pawnValue * multiplier
-    score += bits.OnesCount64(uint64(board.PieceBB[c][Knights])) * knightValue * multiplier
-    score += bishops * bishopValue * multiplier
-    if bishops == 2 {
-      score += 75 * multiplier
-    }
-    score += bits.OnesCount64(uint64(board.PieceBB[c][Rooks])) * rookValue * multiplier
-    score += bits.OnesCount64(uint64(board.PieceBB[c][Queens])) * queenValue * multiplier
-    //PST
-		for p := Pawns; p <= Kings; p++ {
-			pieceBB := board.PieceBB[c][p]
-			for pieceBB != 0 {
-				sq := Square(bits.TrailingZeros64(uint64(pieceBB)))
-				pieceBB.ZeroBit(sq) // Clear the bit to process the next piece
-				pstValue := PieceSquareTable(p, sq, c, lateGame)
-				score += pstValue * multiplier
-			}
-		}
-    // value of attacks and moves
-    numLegalMoves := len(board.GetAllLegalMoves(c))
-		mobilityWeight := 2
-		score += numLegalMoves * mobilityWeight * multiplier
-    numAttackedSquares := bits.OnesCount64(uint64(board.AllAttacks(c)))
-		attackWeight := 1
-		score += numAttackedSquares * attackWeight * multiplier
-    }
-  if board.Turn == Black {
-    if score > 10000 {
-    }
-    return -score
+  score := params.score(NewEvalFeatures(board), true)
+  if board.Variant != nil {
+    score = board.Variant.Evaluate(board, score)
   }
   return score
 }
-func PieceSquareTable(piece Piece, square Square, color Color, late bool) int {
-
-var kingPSTMiddle = [64]int{
-    20,  30,  10,   0,   0,  10,  30,  20, 
-    20,  20,   0,   0,   0,   0,  20,  20,
-    -10, -20, -20, -20, -20, -20, -20, -10,
-    -20, -30, -30, -40, -40, -30, -30, -20,
-    -30, -40, -40, -50, -50, -40, -40, -30,
-    -30, -40, -40, -50, -50, -40, -40, -30,
-    -30, -40, -40, -50, -50, -40, -40, -30,
-    -30, -40, -40, -50, -50, -40, -40, -30,
-}
-var kingPSTLate = [64]int{
-    -10, -10, -10, -10, -10, -10, -10, -10,
-    -10,   0,   5,   5,   5,   5,   0, -10,
-    -10,   5,  10,  10,  10,  10,   5, -10,
-    -10,   5,  15,  15,  15,  15,   5, -10,
-    -10,   5,  15,  15,  15,  15,   5, -10,
-    -10,   5,  10,  10,  10,  10,   5, -10,
-    -10,   0,   5,   5,   5,   5,   0, -10,
-    -10, -10, -10, -10, -10, -10, -10, -10,
-}
-var bishopPST = [64]int{
-    20, 10,  0,  0,  0,  0, 10, 20,
-    10, 20, 10,  0,  0, 10, 20, 10,
-     0, 10, 20, 10, 10, 20, 10,  0,
-    0,  0, 10, 20, 20, 10,  0,  0,
-     0,  0, 10, 20, 20, 10,  0,  0,
-     0, 10, 20, 10, 10, 20, 10,  0, 
-    10, 20, 10,  0,  0, 10, 20, 10, 
-    20, 10,  0,  0,  0,  0, 10, 20,
-}
-var pawnPST = [64]int{
-    0,  0,  0,  0,  0,  0,  0,  0,
-    0,  0,  0,  0,  0,  0,  0,  0,
-    0,  0,  0,  0,  0, -5,  0,  0,
-    0,  0,  5, 10, 10,  0,  0,  0,
-    10, 10, 15, 20, 20, 10, 10, 10,
-    20, 20, 25, 30, 30, 20, 20, 20,
-    30, 30, 35, 40, 40, 30, 30, 30,
-    0,  0,  0,  0,  0,  0,  0,  0,
-}
-	var knightPST = [64]int{
-		-50, -40, -30, -30, -30, -30, -40, -50,
-		-40, -20, 0, 0, 0, 0, -20, -40,
-		-30, 0, 10, 15, 15, 10, 0, -30,
-		-30, 5, 15, 20, 20, 15, 5, -30,
-		-30, 0, 15, 20, 20, 15, 0, -30,
-		-30, 5, 10, 15, 15, 10, 5, -30,
-		-40, -20, 0, 5, 5, 0, -20, -40,
-		-50, -40, -30, -30, -30, -30, -40, -50,
-	}
-var rookPST = [64]int{
-    0,  0,  0,  10,  10,  0,  0,  0,
-    0, 0, 0, 0, 10, 10, 0,  0,
-    -5,  0,  0,  10,  10,  0,  0, -5,
-    -5,  0,  0,  10,  10,  0,  0, -5,
-    -5,  0,  0,  10,  10,  0,  0, -5,
-    -5,  0,  0,  10,  10,  0,  0, -5, 
-    10, 20, 20, 20, 20, 20, 20, 10, 
-    10, 20, 20, 30, 30, 20, 20, 10,
+
+// EvalFeatures holds the parts of an evaluation that need move
+// generation and do not depend on the weights, so a position can be
+// rescored for another set of weights cheaply. Used by the tuner.
+type EvalFeatures struct {
+  Board *Board
+  Mobility [2]int // legal moves
+  Attacks [2]int // attacked squares
 }
-var queenPST = [64]int{ 
-    -20,-10,-10, -5, -5,-10,-10,-20,
-    -10,  0,  0,  0,  0,  0,  0,-10,
-    -10,  0,  5,  5,  5,  5,  0,-10,
-     -5,  0,  5,  5,  5,  5,  0, -5,
-      0,  0,  5,  5,  5,  5,  0, -5,
-    -10,  5,  5,  5,  5,  5,  0,-10,
-    -10,  0,  5,  0,  0,  0,  0,-10,
-    -20,-10,-10, -5, -5,-10,-10,-20,
+
+func NewEvalFeatures(board *Board) *EvalFeatures {
+  f := &EvalFeatures{Board: board}
+  for c := White; c <= Black; c++ {
+    f.Mobility[c] = len(board.GetAllLegalMoves(c))
+    f.Attacks[c] = bits.OnesCount64(uint64(board.AllAttacks(c)))
+  }
+  return f
 }
-if color == Black {
-  square = 63 - square
+
+func (params *EvalParams) Score(f *EvalFeatures) int {
+  // EvaluateWith without the checks for the end of the game. The
+  // pawn table is skipped since callers may change params in place.
+  return params.score(f, false)
 }
-switch piece {
-  case Pawns:
-    return pawnPST[square]
-  case Knights:
-    return knightPST[square]
-  case Bishops:
-    return bishopPST[square]
-  case Rooks:
-    return rookPST[square]
-  case Queens:
-    return queenPST[square]
-  case Kings:
-    if late {
-      return kingPSTLate[square]
-    } else{
-      return kingPSTMiddle[square]
+
+func (params *EvalParams) score(f *EvalFeatures, usePawnTable bool) int {
+  board := f.Board
+  var score Tapered
+  for c := White; c <= Black; c++ {
+    multiplier := 1
+    if c == Black {
+      multiplier = -1
+    }
+    for p := Pawns; p <= Kings; p++ {
+      pieceBB := board.PieceBB[c][p]
+      count := bits.OnesCount64(uint64(pieceBB))
+      score.MG += count * params.Material[p].MG * multiplier
+      score.EG += count * params.Material[p].EG * multiplier
+      //PST
+      for pieceBB != 0 {
+        sq := Square(bits.TrailingZeros64(uint64(pieceBB)))
+        pieceBB.ZeroBit(sq) // Clear the bit to process the next piece
+        pst := params.PieceSquareTable(p, sq, c)
+        score.MG += pst.MG * multiplier
+        score.EG += pst.EG * multiplier
+      }
     }
+    if bits.OnesCount64(uint64(board.PieceBB[c][Bishops])) == 2 {
+      score.MG += params.BishopPair.MG * multiplier
+      score.EG += params.BishopPair.EG * multiplier
+    }
+    // value of attacks and moves
+    score.MG += f.Mobility[c] * params.Mobility.MG * multiplier
+    score.EG += f.Mobility[c] * params.Mobility.EG * multiplier
+    score.MG += f.Attacks[c] * params.Attack.MG * multiplier
+    score.EG += f.Attacks[c] * params.Attack.EG * multiplier
+  }
+  var pawns Tapered
+  if usePawnTable {
+    pawns = params.PawnStructure(board)
+  } else {
+    pawns = params.pawnStructureUncached(board)
   }
-  return 0
+  score.MG += pawns.MG
+  score.EG += pawns.EG
+  king := params.KingSafety(board)
+  score.MG += king.MG
+  score.EG += king.EG
+  // scores are always from white's point of view, the search
+  // maximises for white and minimises for black
+  return Taper(score, GamePhase(board))
+}
+
+func (params *EvalParams) PieceSquareTable(piece Piece, square Square, color Color) Tapered {
+  if color == Black {
+    // mirror the rank, the tables are written from white's side
+    square ^= 56
+  }
+  return Tapered{params.PSTMG[piece][square], params.PSTEG[piece][square]}
+}
+
+var DefaultEvalParams = EvalParams{
+  Material: [7]Tapered{
+    Pawns: {100, 120},
+    Knights: {300, 280},
+    Bishops: {300, 310},
+    Rooks: {500, 530},
+    Queens: {900, 920},
+  },
+  BishopPair: Tapered{75, 90},
+  Mobility: Tapered{2, 3},
+  Attack: Tapered{1, 1},
+  DoubledPawn: Tapered{-10, -20},
+  IsolatedPawn: Tapered{-10, -15},
+  BackwardPawn: Tapered{-8, -10},
+  ConnectedPawn: Tapered{5, 8},
+  PassedPawn: [8]Tapered{
+    {0, 0}, {5, 10}, {5, 15}, {10, 25}, {20, 45}, {35, 75}, {60, 120}, {0, 0},
+  },
+  PassedOwnKing: 2,
+  PassedEnemyKing: 4,
+  KingShield: [2]Tapered{{15, 0}, {8, 0}},
+  KingStorm: [4]Tapered{{-5, 0}, {-20, 0}, {-12, 0}, {-5, 0}},
+  KingOpenFile: Tapered{-25, 0},
+  KingHalfOpenFile: Tapered{-12, 0},
+  KingAttackWeights: [7]int{Pawns: 2, Knights: 6, Bishops: 6, Rooks: 8, Queens: 12},
+  KingAttackerScale: [6]int{0, 0, 50, 75, 88, 94},
+  PSTMG: [7][64]int{
+    Pawns: {
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0, -5,  0,  0,
+        0,  0,  5, 10, 10,  0,  0,  0,
+       10, 10, 15, 20, 20, 10, 10, 10,
+       20, 20, 25, 30, 30, 20, 20, 20,
+       30, 30, 35, 40, 40, 30, 30, 30,
+        0,  0,  0,  0,  0,  0,  0,  0,
+    },
+    Knights: {
+      -50, -40, -30, -30, -30, -30, -40, -50,
+      -40, -20,   0,   0,   0,   0, -20, -40,
+      -30,   0,  10,  15,  15,  10,   0, -30,
+      -30,   5,  15,  20,  20,  15,   5, -30,
+      -30,   0,  15,  20,  20,  15,   0, -30,
+      -30,   5,  10,  15,  15,  10,   5, -30,
+      -40, -20,   0,   5,   5,   0, -20, -40,
+      -50, -40, -30, -30, -30, -30, -40, -50,
+    },
+    Bishops: {
+       20, 10,  0,  0,  0,  0, 10, 20,
+       10, 20, 10,  0,  0, 10, 20, 10,
+        0, 10, 20, 10, 10, 20, 10,  0,
+        0,  0, 10, 20, 20, 10,  0,  0,
+        0,  0, 10, 20, 20, 10,  0,  0,
+        0, 10, 20, 10, 10, 20, 10,  0,
+       10, 20, 10,  0,  0, 10, 20, 10,
+       20, 10,  0,  0,  0,  0, 10, 20,
+    },
+    Rooks: {
+        0,  0,  0, 10, 10,  0,  0,  0,
+        0,  0,  0,  0, 10, 10,  0,  0,
+       -5,  0,  0, 10, 10,  0,  0, -5,
+       -5,  0,  0, 10, 10,  0,  0, -5,
+       -5,  0,  0, 10, 10,  0,  0, -5,
+       -5,  0,  0, 10, 10,  0,  0, -5,
+       10, 20, 20, 20, 20, 20, 20, 10,
+       10, 20, 20, 30, 30, 20, 20, 10,
+    },
+    Queens: {
+      -20, -10, -10,  -5,  -5, -10, -10, -20,
+      -10,   0,   0,   0,   0,   0,   0, -10,
+      -10,   0,   5,   5,   5,   5,   0, -10,
+       -5,   0,   5,   5,   5,   5,   0,  -5,
+        0,   0,   5,   5,   5,   5,   0,  -5,
+      -10,   5,   5,   5,   5,   5,   0, -10,
+      -10,   0,   5,   0,   0,   0,   0, -10,
+      -20, -10, -10,  -5,  -5, -10, -10, -20,
+    },
+    Kings: {
+       20,  30,  10,   0,   0,  10,  30,  20,
+       20,  20,   0,   0,   0,   0,  20,  20,
+      -10, -20, -20, -20, -20, -20, -20, -10,
+      -20, -30, -30, -40, -40, -30, -30, -20,
+      -30, -40, -40, -50, -50, -40, -40, -30,
+      -30, -40, -40, -50, -50, -40, -40, -30,
+      -30, -40, -40, -50, -50, -40, -40, -30,
+      -30, -40, -40, -50, -50, -40, -40, -30,
+    },
+  },
+  PSTEG: [7][64]int{
+    Pawns: {
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        5,  5,  5,  5,  5,  5,  5,  5,
+       15, 15, 15, 15, 15, 15, 15, 15,
+       30, 30, 30, 30, 30, 30, 30, 30,
+       50, 50, 50, 50, 50, 50, 50, 50,
+       80, 80, 80, 80, 80, 80, 80, 80,
+        0,  0,  0,  0,  0,  0,  0,  0,
+    },
+    Knights: {
+      -40, -30, -20, -20, -20, -20, -30, -40,
+      -30, -10,   0,   0,   0,   0, -10, -30,
+      -20,   0,  10,  10,  10,  10,   0, -20,
+      -20,   0,  10,  15,  15,  10,   0, -20,
+      -20,   0,  10,  15,  15,  10,   0, -20,
+      -20,   0,  10,  10,  10,  10,   0, -20,
+      -30, -10,   0,   0,   0,   0, -10, -30,
+      -40, -30, -20, -20, -20, -20, -30, -40,
+    },
+    Bishops: {
+      -10,  -5,  -5,  -5,  -5,  -5,  -5, -10,
+       -5,   0,   0,   0,   0,   0,   0,  -5,
+       -5,   0,   5,   5,   5,   5,   0,  -5,
+       -5,   0,   5,  10,  10,   5,   0,  -5,
+       -5,   0,   5,  10,  10,   5,   0,  -5,
+       -5,   0,   5,   5,   5,   5,   0,  -5,
+       -5,   0,   0,   0,   0,   0,   0,  -5,
+      -10,  -5,  -5,  -5,  -5,  -5,  -5, -10,
+    },
+    Rooks: {
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+        0,  0,  0,  0,  0,  0,  0,  0,
+       10, 10, 10, 10, 10, 10, 10, 10,
+        5,  5,  5,  5,  5,  5,  5,  5,
+    },
+    Queens: {
+      -20, -10, -10,  -5,  -5, -10, -10, -20,
+      -10,   0,   0,   0,   0,   0,   0, -10,
+      -10,   0,   5,  10,  10,   5,   0, -10,
+       -5,   0,  10,  15,  15,  10,   0,  -5,
+       -5,   0,  10,  15,  15,  10,   0,  -5,
+      -10,   0,   5,  10,  10,   5,   0, -10,
+      -10,   0,   0,   0,   0,   0,   0, -10,
+      -20, -10, -10,  -5,  -5, -10, -10, -20,
+    },
+    Kings: {
+      -10, -10, -10, -10, -10, -10, -10, -10,
+      -10,   0,   5,   5,   5,   5,   0, -10,
+      -10,   5,  10,  10,  10,  10,   5, -10,
+      -10,   5,  15,  15,  15,  15,   5, -10,
+      -10,   5,  15,  15,  15,  15,   5, -10,
+      -10,   5,  10,  10,  10,  10,   5, -10,
+      -10,   0,   5,   5,   5,   5,   0, -10,
+      -10, -10, -10, -10, -10, -10, -10, -10,
+    },
+  },
 }
@@ -0,0 +1,114 @@
+package chess
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestEvaluateIsFromWhitesSide(t *testing.T) {
+  // white is a queen up whoever is to move
+  for _, fen := range []string{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", "4k3/8/8/8/8/8/8/3QK3 b - - 0 1"} {
+    board, err := NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if score := Evaluate(board); score <= 0 {
+      t.Errorf("%s: got %d, want a score above 0", fen, score)
+    }
+  }
+}
+
+func TestEvaluateMate(t *testing.T) {
+  // a mated side scores -MateScore, which negates back to MateScore
+  tests := []struct {
+    fen string
+    want int
+  }{
+    {"R5k1/5ppp/8/8/8/8/5PPP/6K1 b - - 0 1", MateScore},
+    {"6k1/8/8/8/8/8/5PPP/r5K1 w - - 0 1", -MateScore},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    score := Evaluate(board)
+    if score != tt.want {
+      t.Errorf("%s: got %d, want %d", tt.fen, score, tt.want)
+    }
+    // math.MinInt32 would stay negative
+    if s := int32(score); (-s > 0) == (s > 0) {
+      t.Errorf("%s: %d does not negate as an int32", tt.fen, score)
+    }
+  }
+}
+
+// mirrorFEN flips a position top to bottom and swaps the colours, so
+// white's position in one is black's in the other
+func mirrorFEN(fen string) string {
+  fields := strings.Fields(fen)
+  ranks := strings.Split(fields[0], "/")
+  for i, j := 0, len(ranks)-1; i < j; i, j = i+1, j-1 {
+    ranks[i], ranks[j] = ranks[j], ranks[i]
+  }
+  fields[0] = swapCase(strings.Join(ranks, "/"))
+  if fields[1] == "w" {
+    fields[1] = "b"
+  } else {
+    fields[1] = "w"
+  }
+  if fields[2] != "-" {
+    castling := swapCase(fields[2])
+    upper, lower := "", ""
+    for _, r := range castling {
+      if r >= 'A' && r <= 'Z' {
+        upper += string(r)
+      } else {
+        lower += string(r)
+      }
+    }
+    fields[2] = upper + lower
+  }
+  if ep := fields[3]; ep != "-" {
+    fields[3] = ep[:1] + string('1'+'8'-ep[1])
+  }
+  return strings.Join(fields, " ")
+}
+
+func swapCase(s string) string {
+  return strings.Map(func(r rune) rune {
+    switch {
+    case r >= 'a' && r <= 'z':
+      return r - 'a' + 'A'
+    case r >= 'A' && r <= 'Z':
+      return r - 'A' + 'a'
+    }
+    return r
+  }, s)
+}
+
+func TestEvaluateSymmetry(t *testing.T) {
+  fens := []string{
+    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+    "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+    "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+    "r1bq1rk1/pp2nppp/2n1p3/3pP3/1b1P4/2NB1N2/PP3PPP/R1BQK2R w KQ - 4 9",
+    "6k1/5ppp/8/8/8/4n1q1/5PPP/6K1 w - - 0 1",
+    "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+    "8/5k2/8/3P4/8/8/1K6/8 b - - 0 1",
+    "2kr3r/ppp2ppp/8/8/1P6/P1N5/2PB1PPP/R3R1K1 b - - 2 16",
+  }
+  for _, fen := range fens {
+    board, err := NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    mirror, err := NewBoardFromFEN(mirrorFEN(fen))
+    if err != nil {
+      t.Fatal(err)
+    }
+    if score, mirrored := Evaluate(board), Evaluate(mirror); score != -mirrored {
+      t.Errorf("%s: got %d, but %d for %s", fen, score, mirrored, mirrorFEN(fen))
+    }
+  }
+}
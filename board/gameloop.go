@@ -21,15 +21,46 @@ type GameResult struct {
   Reason string
 }
 
-const ZobristKeysFilePath = "zobrist_keys.gob"
-
 var ErrResign  = errors.New("Player resigned")
 
+// ErrDraw is returned by an InputProvider to end the game in a draw,
+// for a draw offer that was accepted or an adjudicated game
+var ErrDraw = errors.New("Draw agreed")
+
+// ErrTimeout is returned by an InputProvider whose side has run out
+// of time on its clock
+var ErrTimeout = errors.New("Out of time")
+
+// ErrUndo is returned by an InputProvider to take back its last move,
+// along with the opponent's reply, so the same side is to move again
+var ErrUndo = errors.New("Move taken back")
+
+// ErrDrawOffer is returned by an InputProvider to offer a draw. The
+// game ends if the opponent is a DrawResponder that accepts, and the
+// same side is asked for a move again otherwise.
+var ErrDrawOffer = errors.New("Draw offered")
+
+// Load is returned as the error from GetMove to carry on with another
+// game, such as one the player loaded from a file. Boards holds every
+// position of that game from its start, the last one is played on.
+type Load struct {
+  Boards []*Board
+}
+
+func (l *Load) Error() string {
+  return "Game loaded"
+}
+
 func CoreGameplayLoop(board *Board, config GameConfig, input1 InputProvider, input2 InputProvider, output1 OutputHandler, output2 OutputHandler) (GameResult, error) {
-  _ = LoadZobristKeys()
+  defer stopPondering(input1, input2)
+  // the positions before every move played, for taking moves back
+  var played []*Board
   for {
     output1.DisplayBoard(board)
     output2.DisplayBoard(board)
+    if result, over := board.Outcome(); over {
+      return result, nil
+    }
     if board.IsCheckmate() {
       return GameResult{false, board.Turn.Other(), "Checkmate"}, nil
     }
@@ -48,14 +79,30 @@ func CoreGameplayLoop(board *Board, config GameConfig, input1 InputProvider, inp
     }
     var move Move
     var err error
-    if board.Turn == White {
-      move, err = input1.GetMove(board)
-    } else {
-      move, err = input2.GetMove(board)
+    mover, opponent := input1, input2
+    if board.Turn == Black {
+      mover, opponent = input2, input1
     }
+    move, err = mover.GetMove(board)
     if err != nil {
-      if errors.Is(err, ErrResign) {
+      var load *Load
+      switch {
+      case errors.Is(err, ErrResign):
         return GameResult{false, board.Turn.Other(), "Resignation"}, nil
+      case errors.Is(err, ErrDraw):
+        return GameResult{Draw : true, Reason : "Agreement"}, nil
+      case errors.Is(err, ErrTimeout):
+        return GameResult{false, board.Turn.Other(), "Timeout"}, nil
+      case errors.Is(err, ErrDrawOffer):
+        if r, ok := opponent.(DrawResponder); ok && r.AcceptDraw(board.Clone()) {
+          return GameResult{Draw : true, Reason : "Agreement"}, nil
+        }
+      case errors.Is(err, ErrUndo):
+        played = takeBack(board, played)
+      case errors.As(err, &load) && len(load.Boards) > 0:
+        last := len(load.Boards) - 1
+        *board = *load.Boards[last].Clone()
+        played = append([]*Board(nil), load.Boards[:last]...)
       }
       continue
     }
@@ -63,21 +110,37 @@ func CoreGameplayLoop(board *Board, config GameConfig, input1 InputProvider, inp
       continue
     }
     piece := board.GetPieceAt(move.Start, board.Turn)
-    if piece == Empty {
+    if piece == Empty && move.Drop == Empty {
       continue
     }
-    if board.MovePiece(piece, move) {
-      board.MoveCounter = 0
-    }
-
-    board.MoveCounter++
-    board.TotalMoves++
-    board.History[board.GetZobristHash()]++
+    played = append(played, board.Clone())
+    board.PlayMove(move)
     if board.IsThreefold() {
       return GameResult{Draw : true, Reason : "Threefold repetition"}, nil
     }
-    board.Turn = board.Turn.Other()
+    if p, ok := mover.(Ponderer); ok {
+      p.Ponder(board.Clone())
+    }
+  }
+}
+
+func takeBack(board *Board, played []*Board) []*Board {
+  // goes back to the last position with the same side to move,
+  // leaving the board as it is if there is none
+  for i := len(played) - 1; i >= 0; i-- {
+    if played[i].Turn == board.Turn {
+      *board = *played[i]
+      return played[:i]
+    }
+  }
+  return played
+}
 
+func stopPondering(inputs ...InputProvider) {
+  for _, input := range inputs {
+    if p, ok := input.(Ponderer); ok {
+      p.StopPonder()
+    }
   }
 }
 
@@ -85,6 +148,21 @@ type InputProvider interface {
   GetMove(board *Board) (Move, error)
 }
 
+// Ponderer is implemented by input providers that keep thinking on
+// the opponent's time. Ponder is handed a copy of the position right
+// after the provider's own move, and the provider works out on its
+// next GetMove whether the opponent played the move it expected.
+type Ponderer interface {
+  Ponder(board *Board)
+  StopPonder()
+}
+
+// DrawResponder is implemented by input providers that answer their
+// opponent's draw offers, a provider that is not one declines them.
+type DrawResponder interface {
+  AcceptDraw(board *Board) bool
+}
+
 type OutputHandler interface {
   DisplayBoard(board *Board)
   DisplayCheck()
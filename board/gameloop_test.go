@@ -0,0 +1,62 @@
+package chess
+
+import (
+  "testing"
+)
+
+// scriptedInput plays its moves in order and resigns when they run out
+type scriptedInput struct {
+  moves []string
+}
+
+func (s *scriptedInput) GetMove(board *Board) (Move, error) {
+  if len(s.moves) == 0 {
+    return Move{}, ErrResign
+  }
+  move, err := ParseMove(s.moves[0])
+  s.moves = s.moves[1:]
+  return move, err
+}
+
+type nullOutput struct{}
+
+func (nullOutput) DisplayBoard(board *Board) {}
+func (nullOutput) DisplayCheck() {}
+
+func TestLoopMatchesPlayMove(t *testing.T) {
+  white := &scriptedInput{[]string{"e2e4", "g1f3", "f1c4"}}
+  black := &scriptedInput{[]string{"e7e5", "b8c6", "g8f6"}}
+  board := NewBoard()
+  CoreGameplayLoop(board, GameConfig{}, white, black, nullOutput{}, nullOutput{})
+
+  want := NewBoard()
+  for _, str := range []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6"} {
+    move, _ := ParseMove(str)
+    want.PlayMove(move)
+  }
+  if board.FEN() != want.FEN() {
+    t.Errorf("loop reached %s, PlayMove %s", board.FEN(), want.FEN())
+  }
+  if len(board.History) != len(want.History) {
+    t.Errorf("loop history has %d positions, PlayMove %d", len(board.History), len(want.History))
+  }
+  for hash, n := range want.History {
+    if board.History[hash] != n {
+      t.Errorf("position %x seen %d times by the loop, %d by PlayMove", hash, board.History[hash], n)
+    }
+  }
+}
+
+func TestLoopThreefold(t *testing.T) {
+  // the start position comes round a third time after eight moves
+  white := &scriptedInput{[]string{"g1f3", "f3g1", "g1f3", "f3g1"}}
+  black := &scriptedInput{[]string{"g8f6", "f6g8", "g8f6", "f6g8"}}
+  board := NewBoard()
+  result, err := CoreGameplayLoop(board, GameConfig{}, white, black, nullOutput{}, nullOutput{})
+  if err != nil || result.Reason != "Threefold repetition" || !result.Draw {
+    t.Errorf("got %+v, %v, want a threefold repetition", result, err)
+  }
+  if board.TotalMoves != 8 {
+    t.Errorf("drawn after %d half moves, want 8", board.TotalMoves)
+  }
+}
@@ -0,0 +1,97 @@
+package chess
+
+import (
+  "math/bits"
+)
+
+func kingZone(sq Square, color Color) Bitboard {
+  // The king's square, every square next to it and the three squares
+  // two ranks in front of it. Moving the block a whole rank forward
+  // cannot wrap around a file edge, and ranks pushed past the end of
+  // the board fall off, so no mask is needed.
+  zone := kingAttacks(sq) | (Bitboard(1) << sq)
+  if color == White {
+    zone |= zone << 8
+  } else {
+    zone |= zone >> 8
+  }
+  return zone
+}
+
+func (params *EvalParams) KingSafety(b *Board) Tapered {
+  // king safety from white's point of view
+  var score Tapered
+  for c := White; c <= Black; c++ {
+    s := params.kingSafety(b, c)
+    if c == Black {
+      s.MG, s.EG = -s.MG, -s.EG
+    }
+    score.MG += s.MG
+    score.EG += s.EG
+  }
+  return score
+}
+
+func (params *EvalParams) kingSafety(b *Board, color Color) Tapered {
+  var score Tapered
+  add := func(t Tapered, n int) {
+    score.MG += t.MG * n
+    score.EG += t.EG * n
+  }
+  if b.PieceBB[color][Kings] == 0 {
+    return score
+  }
+  king := Square(bits.TrailingZeros64(uint64(b.PieceBB[color][Kings])))
+  kingRank := relativeRank(king, color)
+  own := b.PieceBB[color][Pawns]
+  enemy := b.PieceBB[color.Other()][Pawns]
+
+  // pawn shield, pawn storm and open files on the king's file and
+  // the two next to it
+  kingFile := int(king % 8)
+  for file := max(kingFile-1, 0); file <= min(kingFile+1, 7); file++ {
+    ownOnFile := own & fileMasks[file]
+    enemyOnFile := enemy & fileMasks[file]
+    if ownOnFile == 0 && enemyOnFile == 0 {
+      add(params.KingOpenFile, 1)
+    } else if ownOnFile == 0 {
+      add(params.KingHalfOpenFile, 1)
+    }
+    for ownOnFile != 0 {
+      sq := Square(bits.TrailingZeros64(uint64(ownOnFile)))
+      ownOnFile &= ownOnFile - 1
+      if d := relativeRank(sq, color) - kingRank; d >= 1 && d <= len(params.KingShield) {
+        add(params.KingShield[d-1], 1)
+      }
+    }
+    for enemyOnFile != 0 {
+      sq := Square(bits.TrailingZeros64(uint64(enemyOnFile)))
+      enemyOnFile &= enemyOnFile - 1
+      if d := relativeRank(sq, color) - kingRank; d >= 1 && d <= len(params.KingStorm) {
+        add(params.KingStorm[d-1], 1)
+      }
+    }
+  }
+
+  // attacks on the king zone, weighted by the attacking piece and
+  // scaled up by how many kinds of piece take part
+  other := color.Other()
+  zone := kingZone(king, color)
+  attacks := [7]Bitboard{
+    Pawns: pawnAttackSpan(enemy, other),
+    Knights: b.GetKnightMoves(other),
+    Bishops: b.GetBishopMoves(other),
+    Rooks: b.GetRookMoves(other),
+    Queens: b.GetQueenMoves(other),
+  }
+  units := 0
+  attackers := 0
+  for p := Pawns; p <= Queens; p++ {
+    if n := bits.OnesCount64(uint64(attacks[p] & zone)); n > 0 {
+      units += n * params.KingAttackWeights[p]
+      attackers++
+    }
+  }
+  score.MG -= units * params.KingAttackerScale[attackers] / 100
+  return score
+}
@@ -0,0 +1,63 @@
+package chess
+
+import "testing"
+
+func squares(names ...string) Bitboard {
+  var bb Bitboard
+  for _, name := range names {
+    bb.SetBit(NotationToIndex(name))
+  }
+  return bb
+}
+
+func TestKingZone(t *testing.T) {
+  tests := []struct {
+    king string
+    color Color
+    zone Bitboard
+  }{
+    {"g1", White, squares("f1", "g1", "h1", "f2", "g2", "h2", "f3", "g3", "h3")},
+    {"e4", White, squares("d3", "e3", "f3", "d4", "e4", "f4", "d5", "e5", "f5", "d6", "e6", "f6")},
+    {"a1", White, squares("a1", "b1", "a2", "b2", "a3", "b3")},
+    {"h8", White, squares("g8", "h8", "g7", "h7")},
+    {"g8", Black, squares("f8", "g8", "h8", "f7", "g7", "h7", "f6", "g6", "h6")},
+    {"h1", Black, squares("g1", "h1", "g2", "h2")},
+    {"a8", Black, squares("a8", "b8", "a7", "b7", "a6", "b6")},
+  }
+  for _, tt := range tests {
+    if got := kingZone(NotationToIndex(tt.king), tt.color); got != tt.zone {
+      t.Errorf("king on %s: zone %064b, want %064b", tt.king, got, tt.zone)
+    }
+  }
+}
+
+func kingSafetyOf(t *testing.T, fen string) Tapered {
+  t.Helper()
+  board, err := NewBoardFromFEN(fen)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return DefaultEvalParams.kingSafety(board, White)
+}
+
+func TestKingSafety(t *testing.T) {
+  // each pair has white's king better off in the first position, in
+  // the middlegame; a lone attacking piece kind counts for nothing
+  tests := []struct {
+    name string
+    safer, weaker string
+  }{
+    {"pawn shield", "6k1/5ppp/8/8/8/8/5PPP/6K1 w - - 0 1", "6k1/5ppp/8/8/8/5PPP/8/6K1 w - - 0 1"},
+    {"missing shield pawn", "6k1/5ppp/8/8/8/8/5PPP/6K1 w - - 0 1", "6k1/5ppp/8/8/8/8/5P1P/6K1 w - - 0 1"},
+    {"open file", "6k1/5ppp/8/8/8/8/5P1P/6K1 w - - 0 1", "6k1/5p1p/8/8/8/8/5P1P/6K1 w - - 0 1"},
+    {"pawn storm", "6k1/5ppp/8/8/8/8/5PPP/6K1 w - - 0 1", "6k1/5p1p/8/8/8/6p1/5PPP/6K1 w - - 0 1"},
+    {"queen near the king", "6k1/5ppp/8/8/8/q3n3/5PPP/6K1 w - - 0 1", "6k1/5ppp/8/8/8/4n1q1/5PPP/6K1 w - - 0 1"},
+    {"one kind of attacker", "6k1/5ppp/8/8/8/6q1/5PPP/6K1 w - - 0 1", "6k1/5ppp/8/8/8/4n1q1/5PPP/6K1 w - - 0 1"},
+  }
+  for _, tt := range tests {
+    safer, weaker := kingSafetyOf(t, tt.safer), kingSafetyOf(t, tt.weaker)
+    if safer.MG <= weaker.MG {
+      t.Errorf("%s: %d for %s, not above %d for %s", tt.name, safer.MG, tt.safer, weaker.MG, tt.weaker)
+    }
+  }
+}
@@ -0,0 +1,97 @@
+package chess
+
+import (
+  "strings"
+  "testing"
+)
+
+func countNodes(b *Board, depth int) int {
+  // plain perft: the number of positions depth half moves ahead
+  if depth == 0 {
+    return 1
+  }
+  nodes := 0
+  for _, move := range b.GetAllLegalMoves(b.Turn) {
+    child := b.Clone()
+    child.PlayMove(move)
+    nodes += countNodes(child, depth-1)
+  }
+  return nodes
+}
+
+func TestPerftReference(t *testing.T) {
+  // published counts from the chessprogramming wiki perft results
+  tests := []struct {
+    fen   string
+    depth int
+    nodes int
+  }{
+    {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3, 8902},
+    {"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862},
+    {"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, 43238},
+    {"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, 9467},
+    {"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.fen, err)
+    }
+    if got := countNodes(board, tt.depth); got != tt.nodes {
+      t.Errorf("%s depth %d: got %d nodes, want %d", tt.fen, tt.depth, got, tt.nodes)
+    }
+  }
+}
+
+func TestCastling(t *testing.T) {
+  tests := []struct {
+    name  string
+    fen   string
+    move  string
+    legal bool
+  }{
+    {"kingside", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", "e1g1", true},
+    {"queenside", "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", "e1c1", true},
+    {"queenside with b1 attacked", "1r2k3/8/8/8/8/8/8/R3K3 w Q - 0 1", "e1c1", true},
+    {"queenside with b1 occupied", "4k3/8/8/8/8/8/8/RN2K3 w Q - 0 1", "e1c1", false},
+    {"through a pawn attack", "4k3/8/8/8/8/8/6p1/4K2R w K - 0 1", "e1g1", false},
+    {"into check", "4k1r1/8/8/8/8/8/8/4K2R w K - 0 1", "e1g1", false},
+    {"out of check", "4k3/4r3/8/8/8/8/8/R3K3 w Q - 0 1", "e1c1", false},
+    {"after the rook was taken", "r3k3/8/1N6/8/8/8/8/4K3 w q - 0 1", "b6a8 e8c8", false},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.name, err)
+    }
+    var moves []Move
+    for _, str := range strings.Fields(tt.move) {
+      move, err := ParseMove(str)
+      if err != nil {
+        t.Fatalf("%s: %v", tt.name, err)
+      }
+      moves = append(moves, move)
+    }
+    for _, move := range moves[:len(moves)-1] {
+      board.PlayMove(move)
+    }
+    last, found := moves[len(moves)-1], false
+    for _, move := range board.GetAllLegalMoves(board.Turn) {
+      found = found || move == last
+    }
+    if found != tt.legal {
+      t.Errorf("%s: %s legal = %v, want %v", tt.name, last, found, tt.legal)
+    }
+  }
+}
+
+func TestQueensideCastleMovesRook(t *testing.T) {
+  board, err := NewBoardFromFEN("4k3/8/8/8/8/8/8/R3K3 w Q - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  board.PlayMove(Move{Start: NotationToIndex("e1"), End: NotationToIndex("c1")})
+  if board.GetPieceAt(NotationToIndex("d1"), White) != Rooks || board.GetPieceAt(NotationToIndex("a1"), White) != Empty {
+    t.Errorf("after O-O-O the rook is not on d1: %s", board.FEN())
+  }
+}
@@ -0,0 +1,55 @@
+package chess
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "reflect"
+)
+
+func (params *EvalParams) Weights() []*int {
+  // every weight in params in field order, for tuning
+  var weights []*int
+  collectWeights(reflect.ValueOf(params).Elem(), &weights)
+  return weights
+}
+
+func collectWeights(v reflect.Value, weights *[]*int) {
+  switch v.Kind() {
+  case reflect.Int:
+    *weights = append(*weights, v.Addr().Interface().(*int))
+  case reflect.Array:
+    for i := 0; i < v.Len(); i++ {
+      collectWeights(v.Index(i), weights)
+    }
+  case reflect.Struct:
+    for i := 0; i < v.NumField(); i++ {
+      collectWeights(v.Field(i), weights)
+    }
+  }
+}
+
+func SaveEvalParams(path string, params *EvalParams) error {
+  // written as json so tuned weights can be read and edited by hand
+  data, err := json.MarshalIndent(params, "", "  ")
+  if err != nil {
+    return fmt.Errorf("failed to encode eval params: %w", err)
+  }
+  if err := os.WriteFile(path, data, 0644); err != nil {
+    return fmt.Errorf("failed to write eval params file: %w", err)
+  }
+  return nil
+}
+
+func LoadEvalParams(path string) (*EvalParams, error) {
+  // weights missing from the file keep their default values
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return nil, fmt.Errorf("failed to read eval params file: %w", err)
+  }
+  params := DefaultEvalParams
+  if err := json.Unmarshal(data, &params); err != nil {
+    return nil, fmt.Errorf("failed to decode eval params: %w", err)
+  }
+  return &params, nil
+}
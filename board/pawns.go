@@ -0,0 +1,213 @@
+package chess
+
+import (
+  "math/bits"
+  "sync/atomic"
+)
+
+// Pawn structure only depends on where the pawns are, so the result
+// for a pawn skeleton is cached under a hash of the pawns alone and
+// reused across every position that shares it. Passed pawn bonuses
+// that depend on the kings are added on top of the cached part.
+
+const pawnTableSize = 1 << 14
+
+type pawnEntry struct {
+  key uint64
+  params *EvalParams
+  score Tapered // white's point of view
+  passed [2]Bitboard
+}
+
+// entries are swapped whole through atomic pointers so the table can
+// be shared by search goroutines without locking
+var pawnTable [pawnTableSize]atomic.Pointer[pawnEntry]
+
+var fileMasks = [8]Bitboard{FileA, FileB, FileC, FileD, FileE, FileF, FileG, FileH}
+
+func (b *Board) PawnHash() uint64 {
+  var hash uint64
+  for c := White; c <= Black; c++ {
+    bb := b.PieceBB[c][Pawns]
+    for bb != 0 {
+      s := Square(bits.TrailingZeros64(uint64(bb)))
+      hash ^= zobristKeys.Pieces[c][Pawns][s]
+      bb &= bb - 1
+    }
+  }
+  return hash
+}
+
+func adjacentFiles(file int) Bitboard {
+  var mask Bitboard
+  if file > 0 {
+    mask |= fileMasks[file-1]
+  }
+  if file < 7 {
+    mask |= fileMasks[file+1]
+  }
+  return mask
+}
+
+func forwardRanks(sq Square, color Color) Bitboard {
+  // every square on the ranks in front of sq from color's side
+  rank := int(sq / 8)
+  if color == White {
+    if rank == 7 {
+      return 0
+    }
+    return ^Bitboard(0) << (8 * (rank + 1))
+  }
+  if rank == 0 {
+    return 0
+  }
+  return ^Bitboard(0) >> (8 * (8 - rank))
+}
+
+func relativeRank(sq Square, color Color) int {
+  if color == White {
+    return int(sq / 8)
+  }
+  return 7 - int(sq/8)
+}
+
+func squareDistance(a, b Square) int {
+  rankDist := int(a/8) - int(b/8)
+  fileDist := int(a%8) - int(b%8)
+  if rankDist < 0 {
+    rankDist = -rankDist
+  }
+  if fileDist < 0 {
+    fileDist = -fileDist
+  }
+  return max(rankDist, fileDist)
+}
+
+func pawnAttackSpan(pawns Bitboard, color Color) Bitboard {
+  // squares attacked by the given pawns
+  if color == White {
+    return ((pawns << 7) & ^FileH) | ((pawns << 9) & ^FileA)
+  }
+  return ((pawns >> 7) & ^FileA) | ((pawns >> 9) & ^FileH)
+}
+
+func (params *EvalParams) pawnStructure(b *Board) *pawnEntry {
+  key := b.PawnHash()
+  slot := &pawnTable[key%pawnTableSize]
+  if e := slot.Load(); e != nil && e.key == key && e.params == params {
+    return e
+  }
+  e := params.newPawnEntry(b, key)
+  slot.Store(e)
+  return e
+}
+
+func (params *EvalParams) newPawnEntry(b *Board, key uint64) *pawnEntry {
+  e := &pawnEntry{key: key, params: params}
+  for c := White; c <= Black; c++ {
+    multiplier := 1
+    if c == Black {
+      multiplier = -1
+    }
+    s := params.evalPawns(b, c, &e.passed[c])
+    e.score.MG += s.MG * multiplier
+    e.score.EG += s.EG * multiplier
+  }
+  return e
+}
+
+func (params *EvalParams) evalPawns(b *Board, color Color, passed *Bitboard) Tapered {
+  // scores doubled, isolated, backward, connected and passed pawns
+  // for one side and records which pawns are passed
+  var score Tapered
+  add := func(t Tapered, n int) {
+    score.MG += t.MG * n
+    score.EG += t.EG * n
+  }
+  own := b.PieceBB[color][Pawns]
+  enemy := b.PieceBB[color.Other()][Pawns]
+  enemyAttacks := pawnAttackSpan(enemy, color.Other())
+  ownAttacks := pawnAttackSpan(own, color)
+
+  for file := 0; file < 8; file++ {
+    if n := bits.OnesCount64(uint64(own & fileMasks[file])); n > 1 {
+      add(params.DoubledPawn, n-1)
+    }
+  }
+
+  pawns := own
+  for pawns != 0 {
+    sq := Square(bits.TrailingZeros64(uint64(pawns)))
+    pawns &= pawns - 1
+    file := int(sq % 8)
+    neighbours := adjacentFiles(file)
+    ahead := forwardRanks(sq, color)
+    rank := relativeRank(sq, color)
+
+    if enemy&(fileMasks[file]|neighbours)&ahead == 0 && own&fileMasks[file]&ahead == 0 {
+      passed.SetBit(sq)
+      add(params.PassedPawn[rank], 1)
+    }
+
+    if own&neighbours == 0 {
+      add(params.IsolatedPawn, 1)
+    } else {
+      // backward when every neighbour is further up the board and
+      // the square in front is covered by an enemy pawn
+      behind := ^ahead &^ sq.GetRank()
+      stop := sq + 8
+      if color == Black {
+        stop = sq - 8
+      }
+      if own&neighbours&(behind|sq.GetRank()) == 0 && enemyAttacks.GetBit(stop) {
+        add(params.BackwardPawn, 1)
+      }
+    }
+
+    if ownAttacks.GetBit(sq) || own&neighbours&sq.GetRank() != 0 {
+      add(params.ConnectedPawn, 1)
+    }
+  }
+  return score
+}
+
+func (params *EvalParams) passedPawnKings(b *Board, color Color, passed Bitboard) int {
+  // endgame only: a passed pawn is worth more the closer its own
+  // king is to the square in front of it and the further the enemy
+  // king is, scaled by how far the pawn has got
+  ownKing := Square(bits.TrailingZeros64(uint64(b.PieceBB[color][Kings])))
+  enemyKing := Square(bits.TrailingZeros64(uint64(b.PieceBB[color.Other()][Kings])))
+  if b.PieceBB[color][Kings] == 0 || b.PieceBB[color.Other()][Kings] == 0 {
+    return 0
+  }
+  score := 0
+  for passed != 0 {
+    sq := Square(bits.TrailingZeros64(uint64(passed)))
+    passed &= passed - 1
+    rank := relativeRank(sq, color)
+    stop := sq + 8
+    if color == Black {
+      stop = sq - 8
+    }
+    weight := rank - 1
+    score += squareDistance(enemyKing, stop) * params.PassedEnemyKing * weight
+    score -= squareDistance(ownKing, stop) * params.PassedOwnKing * weight
+  }
+  return score
+}
+
+func (params *EvalParams) PawnStructure(b *Board) Tapered {
+  // pawn structure score from white's point of view
+  return params.pawnScore(b, params.pawnStructure(b))
+}
+
+func (params *EvalParams) pawnStructureUncached(b *Board) Tapered {
+  return params.pawnScore(b, params.newPawnEntry(b, 0))
+}
+
+func (params *EvalParams) pawnScore(b *Board, e *pawnEntry) Tapered {
+  score := e.score
+  score.EG += params.passedPawnKings(b, White, e.passed[White])
+  score.EG -= params.passedPawnKings(b, Black, e.passed[Black])
+  return score
+}
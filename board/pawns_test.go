@@ -0,0 +1,86 @@
+package chess
+
+import "testing"
+
+var pawnFENs = []string{
+  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+  "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+  "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+  "8/5k2/8/3P4/8/8/1K6/8 b - - 0 1",
+  "4k3/pp3p1p/2p5/8/3P4/1P6/P4PPP/4K3 w - - 0 1",
+}
+
+func TestPawnStructureCache(t *testing.T) {
+  for _, fen := range pawnFENs {
+    board, err := NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    want := DefaultEvalParams.pawnStructureUncached(board)
+    // the first call may fill the table and the second must hit it
+    for i := 0; i < 2; i++ {
+      if got := DefaultEvalParams.PawnStructure(board); got != want {
+        t.Errorf("%s, call %d: got %v, recomputed %v", fen, i+1, got, want)
+      }
+    }
+  }
+}
+
+func TestPawnStructureCacheKings(t *testing.T) {
+  // same pawns, so the same entry, but the king next to the passed
+  // pawn changes the part added on top of it
+  near, err := NewBoardFromFEN("8/8/3k4/3P4/8/8/8/K7 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  far, err := NewBoardFromFEN("k7/8/8/3P4/8/8/8/K7 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if near.PawnHash() != far.PawnHash() {
+    t.Fatal("the pawn hash depends on the kings")
+  }
+  for _, board := range []*Board{near, far, near} {
+    want := DefaultEvalParams.pawnStructureUncached(board)
+    if got := DefaultEvalParams.PawnStructure(board); got != want {
+      t.Errorf("%s: got %v, recomputed %v", board.FEN(), got, want)
+    }
+  }
+}
+
+func TestPawnStructureCacheParams(t *testing.T) {
+  // an entry made with other weights is not served back
+  board, err := NewBoardFromFEN(pawnFENs[2])
+  if err != nil {
+    t.Fatal(err)
+  }
+  params := DefaultEvalParams
+  params.PassedPawn[3].EG += 100
+  DefaultEvalParams.PawnStructure(board)
+  want := params.pawnStructureUncached(board)
+  if got := params.PawnStructure(board); got != want {
+    t.Errorf("got %v, recomputed %v", got, want)
+  }
+}
+
+func TestPawnHash(t *testing.T) {
+  // only pawns count towards the hash
+  a, err := NewBoardFromFEN("r3k3/pp6/8/8/8/8/PP6/4K2R w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  b, err := NewBoardFromFEN("4k3/pp6/8/2q5/8/8/PP6/1N2K3 b - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  c, err := NewBoardFromFEN("4k3/pp6/8/8/8/P7/1P6/4K3 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if a.PawnHash() != b.PawnHash() {
+    t.Error("boards with the same pawns hash differently")
+  }
+  if a.PawnHash() == c.PawnHash() {
+    t.Error("boards with different pawns hash the same")
+  }
+}
@@ -0,0 +1,42 @@
+package chess
+
+import (
+  "fmt"
+  "io"
+)
+
+// Perft counts the positions reached after depth half moves, the
+// usual check that move generation follows the rules, variants
+// included.
+
+func Perft(b *Board, depth int) uint64 {
+  if depth <= 0 {
+    return 1
+  }
+  moves := b.GetAllLegalMoves(b.Turn)
+  if depth == 1 {
+    return uint64(len(moves))
+  }
+  var nodes uint64
+  for _, move := range moves {
+    child := b.Clone()
+    child.PlayMove(move)
+    nodes += Perft(child, depth-1)
+  }
+  return nodes
+}
+
+func Divide(w io.Writer, b *Board, depth int) uint64 {
+  // Perft with the count below each root move written to w, for
+  // finding where two move generators disagree
+  var nodes uint64
+  for _, move := range b.GetAllLegalMoves(b.Turn) {
+    child := b.Clone()
+    child.PlayMove(move)
+    n := Perft(child, depth-1)
+    fmt.Fprintf(w, "%s: %d\n", move, n)
+    nodes += n
+  }
+  fmt.Fprintf(w, "\nNodes: %d\n", nodes)
+  return nodes
+}
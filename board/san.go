@@ -0,0 +1,163 @@
+package chess
+
+import (
+  "fmt"
+  "strings"
+)
+
+// Standard algebraic notation, as used in PGN files: Nf3, exd5, O-O,
+// e8=Q+, Raxd1#.
+
+func (b *Board) SAN(move Move) string {
+  // move has to be legal in b
+  piece := b.GetPieceAt(move.Start, b.Turn)
+  side, castles := b.CastlingSide(move)
+  var sb strings.Builder
+  switch {
+  case move.Drop != Empty:
+    // pawn drops are just @e4
+    if move.Drop != Pawns {
+      sb.WriteString(strings.ToUpper(PieceToChar(move.Drop, White)))
+    }
+    sb.WriteString("@" + IndexToNotation(move.End))
+  case castles && side == KingSide:
+    sb.WriteString("O-O")
+  case castles:
+    sb.WriteString("O-O-O")
+  case piece == Pawns:
+    if b.IsCapture(move) {
+      sb.WriteString(IndexToNotation(move.Start)[:1] + "x")
+    }
+    sb.WriteString(IndexToNotation(move.End))
+    if move.Promotion != Empty {
+      sb.WriteString("=" + strings.ToUpper(PieceToChar(move.Promotion, White)))
+    }
+  default:
+    sb.WriteString(strings.ToUpper(PieceToChar(piece, White)))
+    sb.WriteString(b.disambiguate(piece, move))
+    if b.IsCapture(move) {
+      sb.WriteString("x")
+    }
+    sb.WriteString(IndexToNotation(move.End))
+  }
+
+  after := b.Clone()
+  after.PlayMove(move)
+  if after.IsCheckmate() {
+    sb.WriteString("#")
+  } else if after.IsCheck(after.Turn) {
+    sb.WriteString("+")
+  }
+  return sb.String()
+}
+
+func (b *Board) disambiguate(piece Piece, move Move) string {
+  // the file, rank or square of the moving piece when another piece
+  // of the same kind could also move to the same square
+  from := IndexToNotation(move.Start)
+  ambiguous, sameFile, sameRank := false, false, false
+  for _, other := range b.GetAllLegalMoves(b.Turn) {
+    if other.End != move.End || other.Start == move.Start || b.GetPieceAt(other.Start, b.Turn) != piece {
+      continue
+    }
+    ambiguous = true
+    sameFile = sameFile || other.Start.GetFile() == move.Start.GetFile()
+    sameRank = sameRank || other.Start.GetRank() == move.Start.GetRank()
+  }
+  switch {
+  case !ambiguous:
+    return ""
+  case !sameFile:
+    return from[:1]
+  case !sameRank:
+    return from[1:]
+  }
+  return from
+}
+
+func (b *Board) ParseSAN(san string) (Move, error) {
+  // Reads a move in SAN for the side to move. It is lenient about
+  // check marks, annotations, "0-0" castling, a missing "=" before a
+  // promotion and extra disambiguation. Long algebraic like e2e4,
+  // g1f3 and e7e8q is understood as well, a move that starts with a
+  // full square and no piece letter moves whatever stands there.
+  text := strings.TrimRight(san, "+#!?")
+  legal := b.GetAllLegalMoves(b.Turn)
+  switch text {
+  case "O-O", "0-0", "O-O-O", "0-0-0":
+    want := KingSide
+    if len(text) == 5 {
+      want = QueenSide
+    }
+    for _, move := range legal {
+      if side, ok := b.CastlingSide(move); ok && side == want {
+        return move, nil
+      }
+    }
+    return Move{}, fmt.Errorf("illegal castling: %q", san)
+  }
+
+  if at := strings.IndexByte(text, '@'); at >= 0 {
+    // a drop, N@f3 or @e4 and P@e4 for pawns
+    if at == 0 {
+      text = "P" + text
+    }
+    if len(text) != 4 {
+      return Move{}, fmt.Errorf("invalid drop: %q", san)
+    }
+    drop, err := parseDrop(text)
+    if err != nil {
+      return Move{}, err
+    }
+    for _, move := range legal {
+      if move == drop {
+        return move, nil
+      }
+    }
+    return Move{}, fmt.Errorf("illegal drop: %q", san)
+  }
+
+  piece := Pawns
+  lettered := text != "" && strings.ContainsRune("NBRQK", rune(text[0]))
+  if lettered {
+    piece, _, _ = charToPiece(rune(text[0]))
+    text = text[1:]
+  }
+  promotion := Empty
+  if n := len(text); n > 0 && strings.ContainsRune("QRBNqrbn", rune(text[n-1])) && piece == Pawns {
+    promotion, _, _ = charToPiece(rune(text[n-1]))
+    text = strings.TrimSuffix(text[:n-1], "=")
+  }
+  text = strings.ReplaceAll(strings.ReplaceAll(text, "x", ""), "-", "")
+  if len(text) < 2 || len(text) > 4 {
+    return Move{}, fmt.Errorf("invalid move: %q", san)
+  }
+  dest := text[len(text)-2:]
+  hint := text[:len(text)-2]
+  if dest[0] < 'a' || dest[0] > 'h' || dest[1] < '1' || dest[1] > '8' {
+    return Move{}, fmt.Errorf("invalid move: %q", san)
+  }
+  end := NotationToIndex(dest)
+  if !lettered && len(hint) == 2 && hint[0] >= 'a' && hint[0] <= 'h' && hint[1] >= '1' && hint[1] <= '8' {
+    piece = b.GetPieceAt(NotationToIndex(hint), b.Turn)
+  }
+
+  var found []Move
+  for _, move := range legal {
+    if move.Drop != Empty || move.End != end || move.Promotion != promotion || b.GetPieceAt(move.Start, b.Turn) != piece {
+      continue
+    }
+    from := IndexToNotation(move.Start)
+    if hint != "" && !strings.Contains(from, hint) && hint != from {
+      continue
+    }
+    found = append(found, move)
+  }
+  switch len(found) {
+  case 0:
+    return Move{}, fmt.Errorf("illegal move: %q", san)
+  case 1:
+    return found[0], nil
+  }
+  return Move{}, fmt.Errorf("ambiguous move: %q", san)
+}
@@ -0,0 +1,96 @@
+package chess
+
+import "testing"
+
+func TestSANRoundTrip(t *testing.T) {
+  // every legal move survives SAN and long algebraic and back
+  fens := []string{
+    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+    "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+    "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1",
+    "n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+    "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+    "1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1",
+  }
+  for _, fen := range fens {
+    board, err := NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    for _, move := range board.GetAllLegalMoves(board.Turn) {
+      san := board.SAN(move)
+      if got, err := board.ParseSAN(san); err != nil || got != move {
+        t.Errorf("%s: %s written %q, read back as %s (%v)", fen, move, san, got, err)
+      }
+      if got, err := board.ParseSAN(move.String()); err != nil || got != move {
+        t.Errorf("%s: %s read back as %s (%v)", fen, move, got, err)
+      }
+    }
+  }
+}
+
+func TestSAN(t *testing.T) {
+  tests := []struct {
+    fen string
+    move string // long algebraic
+    san string
+  }{
+    {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", "Nf3"},
+    {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e4"},
+    {"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+    {"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e1c1", "O-O-O"},
+    {"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "d5e6", "dxe6"},
+    {"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e5f7", "Nxf7"},
+    {"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+    {"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", "g2h1q", "gxh1=Q"},
+    {"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", "g2g1n", "g1=N+"},
+    {"1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1", "a1a3", "R1a3"},
+    {"1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1", "f5e3", "Nf5e3"},
+    {"1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1", "d2b3", "Nb3"},
+    {"1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1", "d5e3", "Nde3"},
+    {"1k6/8/8/3N1N2/R7/8/3N4/R3KN2 w - - 0 1", "a4a8", "Ra8+"},
+    {"7k/5Q2/6K1/8/8/8/8/8 w - - 0 1", "f7g7", "Qg7#"},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    move, _ := ParseMove(tt.move)
+    if got := board.SAN(move); got != tt.san {
+      t.Errorf("%s: %s written %q, want %q", tt.fen, tt.move, got, tt.san)
+    }
+  }
+}
+
+func TestParseSAN(t *testing.T) {
+  // the lenient forms ParseSAN accepts besides SAN
+  board := NewBoard()
+  for text, want := range map[string]string{
+    "Nf3": "g1f3",
+    "Ng1f3": "g1f3",
+    "Ng1-f3": "g1f3",
+    "g1f3": "g1f3",
+    "b1c3": "b1c3",
+    "Nc3!?": "b1c3",
+    "e2-e4": "e2e4",
+    "e4": "e2e4",
+  } {
+    move, err := board.ParseSAN(text)
+    if err != nil || move.String() != want {
+      t.Errorf("%q read as %s (%v), want %s", text, move, err, want)
+    }
+  }
+  castles, _ := NewBoardFromFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+  for text, want := range map[string]string{"0-0": "e1g1", "O-O-O+": "e1c1", "e1g1": "e1g1"} {
+    move, err := castles.ParseSAN(text)
+    if err != nil || move.String() != want {
+      t.Errorf("%q read as %s (%v), want %s", text, move, err, want)
+    }
+  }
+  for _, text := range []string{"Nf4", "e5", "g1g3", "e3e4", "Ke2", "", "z9", "Qd1d2d3"} {
+    if move, err := board.ParseSAN(text); err == nil {
+      t.Errorf("%q read as %s, want an error", text, move)
+    }
+  }
+}
@@ -0,0 +1,140 @@
+package chess
+
+import (
+  "math/bits"
+)
+
+// piece values used when resolving exchanges, indexed by Piece
+var SEEValues = [7]int{0, 100, 300, 300, 500, 900, 20000}
+
+var knightAttacks = GenAllKnightMoves()
+
+func (b *Board) IsCapture(move Move) bool {
+  // returns true if the move takes a piece, including en passant
+  if move.Drop != Empty {
+    return false
+  }
+  color := b.colorAt(move.Start)
+  if b.ColorBB[color.Other()].GetBit(move.End) {
+    return true
+  }
+  return b.isEnPassant(move, color)
+}
+
+func (b *Board) isEnPassant(move Move, color Color) bool {
+  if b.EnPassantSquare == nil || *b.EnPassantSquare != move.End {
+    return false
+  }
+  return b.PieceBB[color][Pawns].GetBit(move.Start)
+}
+
+func (b *Board) colorAt(sq Square) Color {
+  if b.ColorBB[Black].GetBit(sq) {
+    return Black
+  }
+  return White
+}
+
+func pawnAttackers(sq Square, color Color) Bitboard {
+  // returns the squares a pawn of the given color would have
+  // to stand on to attack sq
+  target := Bitboard(1) << sq
+  if color == White {
+    return ((target >> 7) & ^FileA) | ((target >> 9) & ^FileH)
+  }
+  return ((target << 7) & ^FileH) | ((target << 9) & ^FileA)
+}
+
+func kingAttacks(sq Square) Bitboard {
+  king := Bitboard(1) << sq
+  return (king << 8) |
+    (king >> 8) |
+    ((king << 1) & ^FileA) |
+    ((king >> 1) & ^FileH) |
+    ((king << 9) & ^FileA) |
+    ((king << 7) & ^FileH) |
+    ((king >> 7) & ^FileA) |
+    ((king >> 9) & ^FileH)
+}
+
+func (b *Board) AttackersTo(sq Square, occupied Bitboard) Bitboard {
+  // Creates a bitboard of every piece of either color that attacks sq
+  // given the occupancy passed in. Sliders are recomputed against
+  // occupied so removing a piece from it uncovers x-ray attackers.
+  diagonal := b.PieceBB[White][Bishops] | b.PieceBB[Black][Bishops] |
+    b.PieceBB[White][Queens] | b.PieceBB[Black][Queens]
+  straight := b.PieceBB[White][Rooks] | b.PieceBB[Black][Rooks] |
+    b.PieceBB[White][Queens] | b.PieceBB[Black][Queens]
+
+  var attackers Bitboard
+  attackers |= pawnAttackers(sq, White) & b.PieceBB[White][Pawns]
+  attackers |= pawnAttackers(sq, Black) & b.PieceBB[Black][Pawns]
+  attackers |= knightAttacks[sq] & (b.PieceBB[White][Knights] | b.PieceBB[Black][Knights])
+  attackers |= kingAttacks(sq) & (b.PieceBB[White][Kings] | b.PieceBB[Black][Kings])
+  attackers |= GetBishopMoves(sq, occupied, 0) & diagonal
+  attackers |= GetRookMoves(sq, occupied, 0) & straight
+  return attackers & occupied
+}
+
+func (b *Board) leastValuableAttacker(attackers Bitboard, color Color) (Square, Piece) {
+  for p := Pawns; p <= Kings; p++ {
+    bb := attackers & b.PieceBB[color][p]
+    if bb != 0 {
+      return Square(bits.TrailingZeros64(uint64(bb))), p
+    }
+  }
+  return 0, Empty
+}
+
+func (b *Board) SEE(move Move) int {
+  // Static exchange evaluation. Plays out every capture on move.End,
+  // each side always recapturing with its least valuable piece, and
+  // returns the material balance for the side making move.
+  // Either side may stop capturing when continuing would lose material.
+  color := b.colorAt(move.Start)
+  attacker := b.GetPieceAt(move.Start, color)
+  if attacker == Empty {
+    return 0
+  }
+  occupied := b.FullBB
+  var gain [32]int
+
+  captured := b.GetPieceAt(move.End, color.Other())
+  if b.isEnPassant(move, color) {
+    captured = Pawns
+    if color == White {
+      occupied.ZeroBit(move.End - 8)
+    } else {
+      occupied.ZeroBit(move.End + 8)
+    }
+  }
+  gain[0] = SEEValues[captured]
+  if move.Promotion != Empty {
+    gain[0] += SEEValues[move.Promotion] - SEEValues[Pawns]
+    attacker = move.Promotion
+  }
+
+  occupied.ZeroBit(move.Start)
+  side := color.Other()
+  d := 0
+  for d < len(gain)-1 {
+    attackers := b.AttackersTo(move.End, occupied)
+    sq, p := b.leastValuableAttacker(attackers, side)
+    if p == Empty {
+      break
+    }
+    d++
+    // score if the piece sitting on the square is taken
+    gain[d] = SEEValues[attacker] - gain[d-1]
+    if max(-gain[d-1], gain[d]) < 0 {
+      break
+    }
+    occupied.ZeroBit(sq)
+    attacker = p
+    side = side.Other()
+  }
+  for ; d > 0; d-- {
+    gain[d-1] = -max(-gain[d-1], gain[d])
+  }
+  return gain[0]
+}
@@ -0,0 +1,45 @@
+package chess
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+  tests := []struct {
+    name string
+    fen string
+    move string
+    see int
+  }{
+    {"undefended knight", "4k3/8/8/3n4/4P3/8/8/4K3 w - - 0 1", "e4d5", 300},
+    {"defended knight", "4k3/8/2p5/3n4/4P3/8/8/4K3 w - - 0 1", "e4d5", 200},
+    {"black takes", "4k3/8/8/3p4/4P3/8/8/4K3 b - - 0 1", "d5e4", 100},
+    {"even trade", "3rk3/8/8/3r4/8/8/8/3RK3 w - - 0 1", "d1d5", 0},
+    {"queen takes a defended pawn", "4k3/8/2p5/3p4/8/8/8/3QK3 w - - 0 1", "d1d5", -800},
+    {"pawn takes a pawn defended by a knight", "4k3/8/8/3p4/2P5/8/3N4/4K3 b - - 0 1", "d5c4", 0},
+    {"quiet move", "4k3/8/8/8/8/8/8/3RK3 w - - 0 1", "d1d5", 0},
+    {"doubled rooks", "3rk3/8/8/3r4/8/8/3R4/3RK3 w - - 0 1", "d2d5", 500},
+    {"bishop with the queen behind", "4k3/8/5p2/4n3/8/2B5/1Q6/4K3 w - - 0 1", "c3e5", 100},
+    {"defended by doubled rooks", "3rk3/3r4/8/3p4/8/2N5/8/3RK3 w - - 0 1", "c3d5", -200},
+    {"queen behind the rook", "3rk3/8/8/3p4/8/8/3R4/3QK3 w - - 0 1", "d2d5", 100},
+    {"queen in front of the rook", "3rk3/8/8/3p4/8/8/3Q4/3RK3 w - - 0 1", "d2d5", -300},
+    {"defended by a bishop with the queen behind", "4k3/6q1/5b2/4p3/2N5/8/8/4R2K w - - 0 1", "c4e5", -200},
+    {"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", "e5d6", 100},
+    {"en passant recaptured", "4k3/2p5/8/3pP3/8/8/8/4K3 w - d6 0 2", "e5d6", 0},
+    {"capture and promote", "1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", 1100},
+    {"capture and promote recaptured", "rn2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", 200},
+    {"promote", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", 800},
+    {"underpromote recaptured", "r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8n", 200 - 300},
+  }
+  for _, tt := range tests {
+    board, err := NewBoardFromFEN(tt.fen)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.name, err)
+    }
+    move, _ := ParseMove(tt.move)
+    if !board.IsLegal(move) {
+      t.Fatalf("%s: %s is illegal", tt.name, tt.move)
+    }
+    if got := board.SEE(move); got != tt.see {
+      t.Errorf("%s: SEE(%s) = %d, want %d", tt.name, tt.move, got, tt.see)
+    }
+  }
+}
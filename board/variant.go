@@ -0,0 +1,54 @@
+package chess
+
+// Variant changes the rules of the game played on a Board. A board
+// with a nil Variant plays normal chess. The board asks its variant
+// for legal moves and checks, tells it about every move played, and
+// the game loop and engine ask it whether the game is over before
+// the normal checkmate and stalemate rules apply. Implementations
+// usually embed StandardRules and override what they change.
+type Variant interface {
+  Name() string
+  LegalMoves(b *Board, color Color) []Move
+  InCheck(b *Board, color Color) bool
+  // AfterMove is called at the end of MovePiece, with the turn not
+  // yet handed over. captured is the piece taken, Empty for none, and
+  // a pawn for a promoted piece.
+  AfterMove(b *Board, move Move, piece Piece, captured Piece)
+  // Outcome reports a game the variant's own rules have ended
+  Outcome(b *Board) (GameResult, bool)
+  // Evaluate adjusts the hand written evaluation, white's view
+  Evaluate(b *Board, score int) int
+}
+
+// StandardRules are the rules of normal chess.
+type StandardRules struct {}
+
+func (StandardRules) Name() string {
+  return "chess"
+}
+
+func (StandardRules) LegalMoves(b *Board, color Color) []Move {
+  return b.StandardLegalMoves(color)
+}
+
+func (StandardRules) InCheck(b *Board, color Color) bool {
+  return b.KingAttacked(color)
+}
+
+func (StandardRules) AfterMove(b *Board, move Move, piece Piece, captured Piece) {}
+
+func (StandardRules) Outcome(b *Board) (GameResult, bool) {
+  return GameResult{}, false
+}
+
+func (StandardRules) Evaluate(b *Board, score int) int {
+  return score
+}
+
+func (b *Board) Outcome() (GameResult, bool) {
+  // the result if the variant's rules have ended the game
+  if b.Variant == nil {
+    return GameResult{}, false
+  }
+  return b.Variant.Outcome(b)
+}
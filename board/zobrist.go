@@ -2,10 +2,6 @@ package chess
 
 import (
   "math/rand"
-  "time"
-  "os"
-  "fmt"
-  "encoding/gob"
 )
 
 var zobristKeys struct {
@@ -13,10 +9,16 @@ var zobristKeys struct {
   Castling [16]uint64
   EnPassant [8]uint64
   Turn uint64
+  Checks [2][4]uint64 // Three-check
+  Pockets [2][7][32]uint64 // Crazyhouse, by the number in hand
 }
 
-func InitZobristKeys() {
-  r := rand.New(rand.NewSource(time.Now().UnixNano()))
+// zobristSeed fixes the keys so hashes are the same in every run and
+// never change while boards are in use
+const zobristSeed = 0x2545f4914f6cdd1d
+
+func init() {
+  r := rand.New(rand.NewSource(zobristSeed))
   for c := 0; c < 2; c ++ {
     for p := 0; p< 7; p++ {
       for s:= 0; s < 64; s++ {
@@ -31,35 +33,14 @@ func InitZobristKeys() {
     zobristKeys.EnPassant[i] = r.Uint64()
   }
   zobristKeys.Turn = r.Uint64()
-}
-
-func SaveZobristKeys() error {
-  file, err := os.Create(ZobristKeysFilePath)
-  if err != nil {
-    return fmt.Errorf("failed to create zobrist keys file: %w", err)
-  }
-  defer file.Close()
-
-  encoder := gob.NewEncoder(file)
-  if err := encoder.Encode(zobristKeys); err != nil {
-    return fmt.Errorf("failed to encode zobrist keys: %w", err)
-  }
-  return nil
-}
-func LoadZobristKeys() error {
-  file, err := os.Open(ZobristKeysFilePath)
-  if err != nil {
-    if os.IsNotExist(err) {
-      InitZobristKeys()
-        return SaveZobristKeys()
+  for c := 0; c < 2; c++ {
+    for i := 0; i < 4; i++ {
+      zobristKeys.Checks[c][i] = r.Uint64()
+    }
+    for p := 0; p < 7; p++ {
+      for n := 0; n < 32; n++ {
+        zobristKeys.Pockets[c][p][n] = r.Uint64()
       }
-    return fmt.Errorf("failed to open zobrist keys file: %w", err)
-  }
-  defer file.Close()
-
-  decoder := gob.NewDecoder(file)
-  if err := decoder.Decode(&zobristKeys); err != nil {
-    return fmt.Errorf("failed to decode zobrist keys: %w", err)
+    }
   }
-  return nil
 }
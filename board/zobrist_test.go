@@ -0,0 +1,20 @@
+package chess
+
+import (
+  "testing"
+)
+
+func TestHashIncludesKings(t *testing.T) {
+  // the same position but for the white king
+  a, err := NewBoardFromFEN("4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  b, err := NewBoardFromFEN("4k3/8/8/8/8/8/8/R4K2 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if a.GetZobristHash() == b.GetZobristHash() {
+    t.Error("positions that differ in the king square hash the same")
+  }
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+  "flag"
+  "fmt"
+  "os"
+  "runtime"
+  "strings"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  solver "chess/solver"
+  uci "chess/uci"
+)
+
+func analyze(args []string) int {
+  fs := newFlagSet("analyze", "[fen]", "Searches a position, the normal start if no FEN is given, and prints the best lines.")
+  multiPV := fs.Int("multipv", 3, "number of moves to list")
+  nodes := fs.Uint64("nodes", 0, "stop after this many nodes")
+  mate := fs.Int("mate", 0, "look for a mate in this many moves")
+  searchMoves := fs.String("searchmoves", "", "only search these moves, like e2e4,d2d4")
+  position := newPositionFlags(fs)
+  engineOpts := newEngineFlags(fs, 4)
+  fs.Parse(args)
+  board, err := position.board(fs.Args())
+  if err != nil {
+    return fail(err)
+  }
+  ab, err := engineOpts.provider()
+  if err != nil {
+    return fail(err)
+  }
+  ab.MultiPV = *multiPV
+  ab.Limits.Nodes = *nodes
+  ab.Limits.Mate = *mate
+  if *searchMoves != "" {
+    for _, str := range strings.Split(*searchMoves, ",") {
+      move, err := chess.ParseMove(strings.TrimSpace(str))
+      if err != nil {
+        return fail(err)
+      }
+      ab.Limits.SearchMoves = append(ab.Limits.SearchMoves, move)
+    }
+  }
+  depthSet := false
+  fs.Visit(func(f *flag.Flag) {
+    depthSet = depthSet || f.Name == "depth"
+  })
+  if !depthSet && (ab.Limits.Nodes > 0 || ab.Limits.Time > 0 || ab.Limits.Mate > 0) {
+    // the other limits decide how deep to go
+    ab.SearchDepth = 0
+  } else if depthSet {
+    ab.SearchDepth = *engineOpts.depth
+  }
+  result := ab.Search(board)
+  for i, line := range result.Lines {
+    fmt.Printf("%d. %s\n", i+1, engine.FormatLine(line))
+  }
+  fmt.Printf("depth %d  %s\n", result.Depth, result.Stats)
+  return ExitOK
+}
+
+func solve(args []string) int {
+  fs := newFlagSet("solve", "<fen>", "Exits with 1 if there is no mate in the number of moves given.")
+  n := fs.Int("mate", 2, "mate in this many moves")
+  checksOnly := fs.Bool("checks", false, "only let the attacker give check")
+  proofNumber := fs.Bool("pns", false, "use proof-number search, better for deeper problems")
+  maxNodes := fs.Int("nodes", solver.DefaultMaxNodes, "proof-number search node limit per key move")
+  fs.Parse(args)
+  if fs.NArg() == 0 {
+    return usageError(fs, "no position given")
+  }
+  board, err := chess.NewBoardFromFEN(strings.Join(fs.Args(), " "))
+  if err != nil {
+    return fail(err)
+  }
+  result := solver.Solve(board, *n, solver.Options{ChecksOnly: *checksOnly, ProofNumber: *proofNumber, MaxNodes: *maxNodes})
+  if result.Solved() {
+    keys := make([]string, len(result.Keys))
+    for i, key := range result.Keys {
+      keys[i] = key.Move.String()
+    }
+    fmt.Printf("mate in %d, key moves: %s\n\n", *n, strings.Join(keys, " "))
+    solver.WriteTree(os.Stdout, result.Keys)
+  } else {
+    fmt.Printf("no mate in %d\n", *n)
+  }
+  for _, move := range result.Unknown {
+    fmt.Printf("%s: undecided, node limit reached\n", move)
+  }
+  fmt.Printf("%d nodes\n", result.Nodes)
+  if !result.Solved() {
+    return ExitError
+  }
+  return ExitOK
+}
+
+func perft(args []string) int {
+  fs := newFlagSet("perft", "[fen]", "Counts the move paths from a position, the normal start if no FEN is given.")
+  depth := fs.Int("depth", 4, "depth in half moves")
+  divide := fs.Bool("divide", false, "print the count below each move")
+  position := newPositionFlags(fs)
+  fs.Parse(args)
+  board, err := position.board(fs.Args())
+  if err != nil {
+    return fail(err)
+  }
+  start := time.Now()
+  var nodes uint64
+  if *divide {
+    nodes = chess.Divide(os.Stdout, board, *depth)
+  } else {
+    nodes = chess.Perft(board, *depth)
+    fmt.Println(nodes)
+  }
+  elapsed := time.Since(start)
+  fmt.Printf("%d nodes in %v (%d nps)\n", nodes, elapsed.Round(time.Millisecond), engine.NPS(nodes, elapsed))
+  return ExitOK
+}
+
+func bench(args []string) int {
+  fs := newFlagSet("bench", "", "Searches a fixed set of positions and prints the node count and speed.")
+  depth := fs.Int("depth", 3, "search depth in half moves")
+  fs.Parse(args)
+  stats, elapsed, err := engine.Bench(os.Stdout, *depth)
+  if err != nil {
+    return fail(err)
+  }
+  fmt.Printf("total  %s\n", stats)
+  fmt.Printf("%d nodes %d nps\n", stats.Nodes, engine.NPS(stats.Nodes, elapsed))
+  return ExitOK
+}
+
+func smpBench(args []string) int {
+  fs := newFlagSet("smpbench", "", "Times the bench positions with 1, 2, 4 and so on threads.")
+  depth := fs.Int("depth", 4, "search depth in half moves")
+  threads := fs.Int("threads", runtime.NumCPU(), "highest thread count to time")
+  fs.Parse(args)
+  if err := engine.SMPScaling(os.Stdout, *depth, *threads); err != nil {
+    return fail(err)
+  }
+  return ExitOK
+}
+
+func runUCI(args []string) int {
+  fs := newFlagSet("uci", "", "Runs as a UCI engine for a chess GUI, reading commands from standard input.")
+  params := fs.String("params", "", "evaluation weights written by tune")
+  fs.Parse(args)
+  if err := loadParams(*params); err != nil {
+    return fail(err)
+  }
+  if err := uci.Run(os.Stdin, os.Stdout); err != nil {
+    return fail(err)
+  }
+  return ExitOK
+}
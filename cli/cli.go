@@ -0,0 +1,284 @@
+package cli
+
+import (
+  "flag"
+  "fmt"
+  "math/rand"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  nnue "chess/nnue"
+  variant "chess/variant"
+)
+
+// The chess command line. Every command parses its own flags and
+// returns the exit code, so the ones that play a game can exit with
+// its result.
+
+// Exit codes. Commands that play a game exit with one of the result
+// codes once it is over.
+const (
+  ExitOK = 0
+  ExitError = 1
+  ExitUsage = 2
+  ExitWhiteWins = 10
+  ExitBlackWins = 11
+  ExitDraw = 12
+)
+
+type command struct {
+  summary string
+  run func(args []string) int
+}
+
+var commands map[string]command
+
+func init() {
+  // set up here as help refers back to the table
+  commands = map[string]command{
+    "play": {"play a game in the terminal UI", play},
+    "selfplay": {"generate training positions from engine games", selfPlay},
+    "analyze": {"search a position and print the best lines", analyze},
+    "solve": {"solve a mate in n problem", solve},
+    "perft": {"count the positions a number of moves deep", perft},
+    "bench": {"time the search on a fixed set of positions", bench},
+    "smpbench": {"time the search with more and more threads", smpBench},
+    "uci": {"speak the UCI protocol on standard input and output", runUCI},
+    "serve": {"run games over a local HTTP API", serve},
+    "pgn": {"check, convert and summarise PGN files", pgnTool},
+    "annotate": {"annotate the games in a PGN file with engine comments", annotateGames},
+    "puzzles": {"find puzzles in the games of a PGN file", puzzles},
+    "match": {"play two engine configurations against each other", playMatch},
+    "tune": {"tune the evaluation weights on labelled positions", tune},
+    "help": {"show help for a command", help},
+  }
+}
+
+func Run(args []string) int {
+  // args are the command line without the program name, no command
+  // plays a game against the engine
+  if len(args) == 0 {
+    return play(nil)
+  }
+  name := args[0]
+  if name == "-h" || name == "-help" || name == "--help" {
+    usage(os.Stdout)
+    return ExitOK
+  }
+  cmd, ok := commands[name]
+  if !ok {
+    fmt.Fprintf(os.Stderr, "chess: unknown command %q\n\n", name)
+    usage(os.Stderr)
+    return ExitUsage
+  }
+  return cmd.run(args[1:])
+}
+
+func usage(w *os.File) {
+  names := make([]string, 0, len(commands))
+  for name := range commands {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  fmt.Fprintln(w, "usage: chess <command> [flags] [arguments]")
+  fmt.Fprintln(w)
+  fmt.Fprintln(w, "commands:")
+  for _, name := range names {
+    fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+  }
+  fmt.Fprintln(w)
+  fmt.Fprintln(w, "Run 'chess help <command>' for its flags. With no command, chess plays")
+  fmt.Fprintln(w, "a game against the engine.")
+  fmt.Fprintln(w)
+  fmt.Fprintln(w, "Exit codes: 0 success, 1 error, 2 bad usage. play exits with the")
+  fmt.Fprintf(w, "result of the game: %d white won, %d black won, %d draw.\n", ExitWhiteWins, ExitBlackWins, ExitDraw)
+}
+
+func help(args []string) int {
+  if len(args) == 0 {
+    usage(os.Stdout)
+    return ExitOK
+  }
+  cmd, ok := commands[args[0]]
+  if !ok || args[0] == "help" {
+    usage(os.Stderr)
+    return ExitUsage
+  }
+  return cmd.run([]string{"-h"})
+}
+
+func newFlagSet(name, arguments, description string) *flag.FlagSet {
+  // a flag set that prints a usage line and description before the
+  // flags, and exits with ExitUsage on a bad flag
+  fs := flag.NewFlagSet(name, flag.ExitOnError)
+  fs.Usage = func() {
+    fmt.Fprintf(fs.Output(), "usage: chess %s [flags] %s\n", name, arguments)
+    if description != "" {
+      fmt.Fprintln(fs.Output(), description)
+    }
+    fs.PrintDefaults()
+  }
+  return fs
+}
+
+func fail(err error) int {
+  fmt.Fprintln(os.Stderr, "chess:", err)
+  return ExitError
+}
+
+func usageError(fs *flag.FlagSet, format string, args ...any) int {
+  fmt.Fprintf(fs.Output(), "chess %s: %s\n", fs.Name(), fmt.Sprintf(format, args...))
+  fs.Usage()
+  return ExitUsage
+}
+
+func resultCode(result chess.GameResult) int {
+  switch {
+  case result.Draw:
+    return ExitDraw
+  case result.Winner == chess.White:
+    return ExitWhiteWins
+  }
+  return ExitBlackWins
+}
+
+func describeResult(result chess.GameResult) string {
+  switch {
+  case result.Draw:
+    return "Draw by " + result.Reason
+  case result.Winner == chess.White:
+    return "White wins by " + result.Reason
+  }
+  return "Black wins by " + result.Reason
+}
+
+// positionFlags pick the starting position of a game or search.
+type positionFlags struct {
+  fen *string
+  variant *string
+  chess960 *string
+}
+
+func newPositionFlags(fs *flag.FlagSet) positionFlags {
+  return positionFlags{
+    fen: fs.String("fen", "", "position to start from, the normal start if empty"),
+    variant: fs.String("variant", "chess", "rules to play by: "+strings.Join(variant.Names(), ", ")),
+    chess960: fs.String("chess960", "", "Chess960 start position from 0 to 959, or random"),
+  }
+}
+
+func (f positionFlags) board(args []string) (*chess.Board, error) {
+  // the position from the flags, or a FEN given as the arguments
+  fen := *f.fen
+  if len(args) > 0 {
+    if fen != "" {
+      return nil, fmt.Errorf("a FEN was given both as -fen and as arguments")
+    }
+    fen = strings.Join(args, " ")
+  }
+  rules, err := variant.ByName(*f.variant)
+  if err != nil {
+    return nil, err
+  }
+  if *f.chess960 != "" {
+    if fen != "" {
+      return nil, fmt.Errorf("-chess960 and a FEN can not be used together")
+    }
+    if rules.Name() != "chess" {
+      return nil, fmt.Errorf("-chess960 only works with normal chess rules")
+    }
+    id := rand.Intn(960)
+    if *f.chess960 != "random" {
+      id, err = strconv.Atoi(*f.chess960)
+      if err != nil {
+        return nil, fmt.Errorf("-chess960 wants a number from 0 to 959 or random, not %q", *f.chess960)
+      }
+    }
+    return chess.NewChess960Board(id)
+  }
+  if fen != "" {
+    return variant.NewBoardFromFEN(rules, fen)
+  }
+  return variant.NewBoard(rules), nil
+}
+
+// engineFlags configure the engine of a command.
+type engineFlags struct {
+  depth *int
+  moveTime *time.Duration
+  threads *int
+  eval *string
+  net *string
+  params *string
+}
+
+func newEngineFlags(fs *flag.FlagSet, depth int) engineFlags {
+  f := engineFlags{
+    depth: fs.Int("depth", depth, "engine search depth in half moves"),
+    moveTime: fs.Duration("movetime", 0, "engine time per move like 2s, searched as deep as time allows"),
+    threads: fs.Int("threads", 1, "engine search threads"),
+    eval: fs.String("eval", "heuristic", "evaluator: heuristic, material or nnue"),
+    net: fs.String("net", "", "network file for -eval nnue"),
+    params: fs.String("params", "", "evaluation weights written by tune"),
+  }
+  return f
+}
+
+func (f engineFlags) provider() (engine.AlphaBetaInputProvider, error) {
+  evaluator, err := newEvaluator(*f.eval, *f.net)
+  if err != nil {
+    return engine.AlphaBetaInputProvider{}, err
+  }
+  if *f.params != "" {
+    if *f.eval != "heuristic" {
+      return engine.AlphaBetaInputProvider{}, fmt.Errorf("-params only works with the heuristic evaluator")
+    }
+    params, err := chess.LoadEvalParams(*f.params)
+    if err != nil {
+      return engine.AlphaBetaInputProvider{}, err
+    }
+    evaluator = engine.HeuristicEvaluator{Params: params}
+  }
+  ab := engine.AlphaBetaInputProvider{
+    SearchDepth: *f.depth,
+    Threads: *f.threads,
+    Evaluator: evaluator,
+    TT: engine.NewTranspositionTable(engine.DefaultHashMB),
+  }
+  if *f.moveTime > 0 {
+    ab.Limits.Time = *f.moveTime
+    ab.SearchDepth = 0
+  }
+  return ab, nil
+}
+
+func newEvaluator(name, netPath string) (engine.Evaluator, error) {
+  if name != "nnue" {
+    return engine.NewEvaluator(name)
+  }
+  if netPath == "" {
+    return nil, fmt.Errorf("-eval nnue needs a -net file")
+  }
+  net, err := nnue.LoadNetwork(netPath)
+  if err != nil {
+    return nil, err
+  }
+  return nnue.NewEvaluator(net), nil
+}
+
+func loadParams(path string) error {
+  // swaps in tuned evaluation weights, if a file was given
+  if path == "" {
+    return nil
+  }
+  params, err := chess.LoadEvalParams(path)
+  if err != nil {
+    return err
+  }
+  chess.ActiveEvalParams = params
+  return nil
+}
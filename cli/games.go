@@ -0,0 +1,296 @@
+package cli
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "runtime"
+  "sort"
+  "strings"
+  chess "chess/board"
+  annotate "chess/annotate"
+  pgn "chess/pgn"
+  puzzle "chess/puzzle"
+  variant "chess/variant"
+)
+
+func readGames(path string) ([]*pgn.Game, error) {
+  // the games in a PGN file, - reads standard input
+  if path == "-" {
+    return pgn.ReadAll(os.Stdin)
+  }
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+  games, err := pgn.ReadAll(file)
+  if err != nil {
+    return games, fmt.Errorf("%s: %w", path, err)
+  }
+  return games, nil
+}
+
+func createOutput(path string) (io.WriteCloser, error) {
+  // the file to write to, standard output if path is empty
+  if path == "" {
+    return nopCloser{os.Stdout}, nil
+  }
+  return os.Create(path)
+}
+
+type nopCloser struct {
+  io.Writer
+}
+
+func (nopCloser) Close() error {
+  return nil
+}
+
+var pgnTools = map[string]struct {
+  summary string
+  run func(args []string) int
+}{
+  "check": {"replay every game and report the first error in each file", pgnCheck},
+  "format": {"rewrite games with the standard tag order and line wrapping", pgnFormat},
+  "fen": {"print a FEN of every game, the final position by default", pgnFEN},
+  "stats": {"count results and game lengths", pgnStats},
+}
+
+func pgnTool(args []string) int {
+  if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+    names := make([]string, 0, len(pgnTools))
+    for name := range pgnTools {
+      names = append(names, name)
+    }
+    sort.Strings(names)
+    fmt.Fprintln(os.Stderr, "usage: chess pgn <tool> [flags] <file>...")
+    fmt.Fprintln(os.Stderr, "Files named - are read from standard input.")
+    fmt.Fprintln(os.Stderr)
+    fmt.Fprintln(os.Stderr, "tools:")
+    for _, name := range names {
+      fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, pgnTools[name].summary)
+    }
+    if len(args) == 0 {
+      return ExitUsage
+    }
+    return ExitOK
+  }
+  tool, ok := pgnTools[args[0]]
+  if !ok {
+    fmt.Fprintf(os.Stderr, "chess pgn: unknown tool %q, see chess pgn -h\n", args[0])
+    return ExitUsage
+  }
+  return tool.run(args[1:])
+}
+
+func pgnCheck(args []string) int {
+  fs := newFlagSet("pgn check", "<file>...", "Exits with 1 if any game can not be read or replayed.")
+  fs.Parse(args)
+  if fs.NArg() == 0 {
+    return usageError(fs, "no files given")
+  }
+  code := ExitOK
+  for _, path := range fs.Args() {
+    games, err := readGames(path)
+    if err == nil {
+      // the moves were checked while parsing, this checks the
+      // positions in the tags
+      for i, game := range games {
+        if _, err = game.Boards(); err != nil {
+          err = fmt.Errorf("%s: game %d: %w", path, i+1, err)
+          break
+        }
+      }
+    }
+    if err != nil {
+      fmt.Println(err)
+      code = ExitError
+      continue
+    }
+    fmt.Printf("%s: %d games ok\n", path, len(games))
+  }
+  return code
+}
+
+func pgnFormat(args []string) int {
+  fs := newFlagSet("pgn format", "<file>...", "")
+  out := fs.String("out", "", "file to write to, standard output if empty")
+  fs.Parse(args)
+  if fs.NArg() == 0 {
+    return usageError(fs, "no files given")
+  }
+  w, err := createOutput(*out)
+  if err != nil {
+    return fail(err)
+  }
+  defer w.Close()
+  for _, path := range fs.Args() {
+    games, err := readGames(path)
+    if err != nil {
+      return fail(err)
+    }
+    for _, game := range games {
+      if err := game.Write(w); err != nil {
+        return fail(err)
+      }
+    }
+  }
+  return ExitOK
+}
+
+func pgnFEN(args []string) int {
+  fs := newFlagSet("pgn fen", "<file>...", "")
+  ply := fs.Int("ply", -1, "half moves into the game, -1 for the final position")
+  fs.Parse(args)
+  if fs.NArg() == 0 {
+    return usageError(fs, "no files given")
+  }
+  for _, path := range fs.Args() {
+    games, err := readGames(path)
+    if err != nil {
+      return fail(err)
+    }
+    for _, game := range games {
+      boards, err := game.Boards()
+      if err != nil {
+        return fail(err)
+      }
+      i := len(boards) - 1
+      if *ply >= 0 {
+        i = min(*ply, i)
+      }
+      fmt.Println(variant.FEN(boards[i]))
+    }
+  }
+  return ExitOK
+}
+
+func pgnStats(args []string) int {
+  fs := newFlagSet("pgn stats", "<file>...", "")
+  fs.Parse(args)
+  if fs.NArg() == 0 {
+    return usageError(fs, "no files given")
+  }
+  results := map[string]int{}
+  var games, plies, longest int
+  for _, path := range fs.Args() {
+    read, err := readGames(path)
+    if err != nil {
+      return fail(err)
+    }
+    for _, game := range read {
+      games++
+      results[game.Result]++
+      plies += len(game.Moves)
+      longest = max(longest, len(game.Moves))
+    }
+  }
+  fmt.Printf("games    %d\n", games)
+  if games == 0 {
+    return ExitOK
+  }
+  decided := results["1-0"] + results["0-1"] + results["1/2-1/2"]
+  fmt.Printf("results  1-0 %d  0-1 %d  1/2-1/2 %d  unfinished %d\n", results["1-0"], results["0-1"], results["1/2-1/2"], games-decided)
+  if decided > 0 {
+    score := float64(results["1-0"]) + float64(results["1/2-1/2"])/2
+    fmt.Printf("white    %.1f%% of the points\n", 100*score/float64(decided))
+  }
+  fmt.Printf("length   %.1f moves on average, %d at most\n", float64(plies)/float64(games)/2, (longest+1)/2)
+  return ExitOK
+}
+
+func puzzles(args []string) int {
+  defaults := puzzle.DefaultOptions()
+  fs := newFlagSet("puzzles", "<games.pgn>", "Writes FEN, solution, solution in SAN, themes and source as CSV.")
+  out := fs.String("out", "puzzles.csv", "file to write the puzzles to")
+  depth := fs.Int("depth", defaults.Depth, "search depth for every position")
+  minSwing := fs.Int("swing", defaults.MinSwing, "centipawns the mistake before the puzzle has to lose")
+  winning := fs.Int("winning", defaults.Winning, "score the solution has to reach")
+  margin := fs.Int("margin", defaults.Margin, "how much worse the second best move has to be")
+  maxMoves := fs.Int("maxmoves", defaults.MaxMoves, "longest solution in moves")
+  concurrency := fs.Int("concurrency", runtime.NumCPU(), "games analysed at the same time")
+  fs.Parse(args)
+  if fs.NArg() != 1 {
+    return usageError(fs, "expected one PGN file")
+  }
+  games, err := readGames(fs.Arg(0))
+  if err != nil {
+    return fail(err)
+  }
+  found, err := puzzle.FromGames(games, puzzle.Options{
+    Depth: *depth,
+    MinSwing: *minSwing,
+    Winning: *winning,
+    Margin: *margin,
+    MaxMoves: *maxMoves,
+    Concurrency: *concurrency,
+  })
+  if err != nil {
+    // the other games still count, so carry on without these
+    for _, line := range strings.Split(err.Error(), "\n") {
+      fmt.Fprintln(os.Stderr, "chess: skipped", line)
+    }
+  }
+  var all []puzzle.Puzzle
+  for _, p := range found {
+    all = append(all, p...)
+  }
+  outFile, err := os.Create(*out)
+  if err != nil {
+    return fail(err)
+  }
+  defer outFile.Close()
+  if err := puzzle.WriteCSV(outFile, all); err != nil {
+    return fail(err)
+  }
+  fmt.Printf("%d puzzles from %d games written to %s\n", len(all), len(games), *out)
+  return ExitOK
+}
+
+func annotateGames(args []string) int {
+  defaults := annotate.DefaultOptions()
+  fs := newFlagSet("annotate", "<games.pgn>", "Writes the games with evaluations and mistakes marked, and a summary per player on standard error.")
+  out := fs.String("out", "", "file to write the annotated games to, standard output if empty")
+  depth := fs.Int("depth", defaults.Depth, "search depth for every position")
+  moveTime := fs.Duration("time", 0, "search time limit for every position, like 500ms")
+  threads := fs.Int("threads", defaults.Threads, "search threads")
+  inaccuracy := fs.Int("inaccuracy", defaults.Inaccuracy, "centipawn loss for an inaccuracy")
+  mistake := fs.Int("mistake", defaults.Mistake, "centipawn loss for a mistake")
+  blunder := fs.Int("blunder", defaults.Blunder, "centipawn loss for a blunder")
+  fs.Parse(args)
+  if fs.NArg() != 1 {
+    return usageError(fs, "expected one PGN file")
+  }
+  games, err := readGames(fs.Arg(0))
+  if err != nil {
+    return fail(err)
+  }
+  w, err := createOutput(*out)
+  if err != nil {
+    return fail(err)
+  }
+  defer w.Close()
+  opts := annotate.Options{
+    Depth: *depth,
+    Time: *moveTime,
+    Threads: *threads,
+    Inaccuracy: *inaccuracy,
+    Mistake: *mistake,
+    Blunder: *blunder,
+  }
+  for _, game := range games {
+    annotated, summaries, err := annotate.Annotate(game, opts)
+    if err != nil {
+      return fail(err)
+    }
+    if err := annotated.Write(w); err != nil {
+      return fail(err)
+    }
+    // the summary goes to stderr so it does not end up in the PGN
+    fmt.Fprintf(os.Stderr, "%s - %s\n", game.Tag("White"), game.Tag("Black"))
+    fmt.Fprintf(os.Stderr, "  white  %s\n", summaries[chess.White])
+    fmt.Fprintf(os.Stderr, "  black  %s\n", summaries[chess.Black])
+  }
+  return ExitOK
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+  "flag"
+  "fmt"
+  "os"
+  "os/signal"
+  "strings"
+  "syscall"
+  chess "chess/board"
+  engine "chess/engine"
+  pgn "chess/pgn"
+  tui "chess/tui"
+)
+
+func play(args []string) int {
+  fs := newFlagSet("play", "", "Plays a game in the terminal UI, each side a human at the keyboard or the engine.")
+  white := fs.String("white", "human", "who plays white: human or engine")
+  black := fs.String("black", "engine", "who plays black: human or engine")
+  clock := fs.Duration("time", 0, "time on each clock like 5m, 0 plays without a time control")
+  increment := fs.Duration("inc", 0, "time added to a clock after each move like 2s")
+  flip := fs.Bool("flip", false, "show black at the bottom, the default when only black is human")
+  pgnPath := fs.String("pgn", "", "file to append the finished game to")
+  position := newPositionFlags(fs)
+  engineOpts := newEngineFlags(fs, 5)
+  fs.Parse(args)
+  if fs.NArg() > 0 {
+    return usageError(fs, "unexpected arguments %q, use -fen for a start position", strings.Join(fs.Args(), " "))
+  }
+  var humans [2]bool
+  for color, who := range map[chess.Color]string{chess.White: *white, chess.Black: *black} {
+    switch who {
+    case "human":
+      humans[color] = true
+    case "engine":
+    default:
+      return usageError(fs, "players are human or engine, not %q", who)
+    }
+  }
+  board, err := position.board(nil)
+  if err != nil {
+    return fail(err)
+  }
+  flipSet := false
+  fs.Visit(func(f *flag.Flag) {
+    flipSet = flipSet || f.Name == "flip"
+  })
+  if !flipSet {
+    *flip = humans[chess.Black] && !humans[chess.White]
+  }
+
+  names := [2]string{"Engine", "Engine"}
+  for color, human := range humans {
+    if human {
+      names[color] = "You"
+    }
+  }
+  if humans[chess.White] && humans[chess.Black] {
+    names = [2]string{"White", "Black"}
+  }
+  // hint and eval use the same evaluation with a quick search
+  analysis, err := engineOpts.provider()
+  if err != nil {
+    return fail(err)
+  }
+  analysis.SearchDepth = 4
+  analysis.Limits = engine.SearchLimits{}
+  analysis.Threads = 1
+  ui, err := tui.New(tui.Options{Names: names, Time: *clock, Increment: *increment, Flip: *flip, Analysis: analysis})
+  if err != nil {
+    return fail(err)
+  }
+  // put the terminal back if the game is interrupted
+  interrupt := make(chan os.Signal, 1)
+  signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+  go func() {
+    <-interrupt
+    ui.Close()
+    os.Exit(ExitError)
+  }()
+
+  var players [2]chess.InputProvider
+  for color := range players {
+    if humans[color] {
+      players[color] = ui.Input()
+      continue
+    }
+    provider, err := engineOpts.provider()
+    if err != nil {
+      ui.Close()
+      return fail(err)
+    }
+    players[color] = &tui.Engine{UI: ui, Provider: provider}
+  }
+  output := ui.Output()
+  result, err := chess.CoreGameplayLoop(board, chess.GameConfig{}, players[chess.White], players[chess.Black], output, output)
+  if err != nil {
+    ui.Close()
+    return fail(err)
+  }
+  ui.ShowResult(result)
+  game := ui.Game()
+  ui.Close()
+  signal.Stop(interrupt)
+
+  fmt.Println(describeResult(result))
+  if *pgnPath != "" {
+    game.Result = pgn.ResultString(result)
+    game.SetTag("Termination", result.Reason)
+    if err := appendGame(*pgnPath, game); err != nil {
+      return fail(err)
+    }
+  }
+  return resultCode(result)
+}
+
+func appendGame(path string, game *pgn.Game) error {
+  file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+  if err != nil {
+    return err
+  }
+  if err := game.Write(file); err != nil {
+    file.Close()
+    return err
+  }
+  return file.Close()
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "os/signal"
+  "syscall"
+  "time"
+  server "chess/server"
+)
+
+func serve(args []string) int {
+  fs := newFlagSet("serve", "", "Serves a JSON API for creating games, playing moves and asking the engine for analysis. Games can be followed and played over a WebSocket at /games/{id}/ws.")
+  addr := fs.String("addr", "localhost:8080", "address to listen on")
+  depth := fs.Int("depth", 5, "default engine search depth in half moves")
+  moveTime := fs.Duration("movetime", 0, "default engine time per move like 2s, overrides -depth")
+  threads := fs.Int("threads", 1, "default engine search threads")
+  maxGames := fs.Int("max-games", 100, "games kept at once, 0 for no limit")
+  fs.Parse(args)
+  if fs.NArg() > 0 {
+    return usageError(fs, "unexpected arguments")
+  }
+  defaults := server.EngineSettings{Depth: *depth, MoveTime: int(moveTime.Milliseconds()), Threads: *threads}
+  if *moveTime > 0 {
+    defaults.Depth = 0
+  }
+  api := server.New(defaults)
+  api.MaxGames = *maxGames
+  srv := &http.Server{Addr: *addr, Handler: api, ReadHeaderTimeout: 10 * time.Second}
+
+  // finish the requests in flight and stop the games on Ctrl-C
+  interrupt := make(chan os.Signal, 1)
+  signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+  go func() {
+    <-interrupt
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    srv.Shutdown(ctx)
+  }()
+  fmt.Printf("listening on http://%s\n", *addr)
+  err := srv.ListenAndServe()
+  api.Close()
+  if !errors.Is(err, http.ErrServerClosed) {
+    return fail(err)
+  }
+  return ExitOK
+}
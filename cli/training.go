@@ -0,0 +1,188 @@
+package cli
+
+import (
+  "flag"
+  "fmt"
+  "os"
+  "runtime"
+  chess "chess/board"
+  engine "chess/engine"
+  match "chess/match"
+  selfplay "chess/selfplay"
+  tuner "chess/tuner"
+)
+
+func selfPlay(args []string) int {
+  fs := newFlagSet("selfplay", "", "Plays the engine against itself and writes every position with the game result, for tune and network training.")
+  out := fs.String("out", "selfplay.txt", "file to write the positions to")
+  format := fs.String("format", "text", "output format: text or binary")
+  games := fs.Int("games", 10, "number of self-play games")
+  concurrency := fs.Int("concurrency", runtime.NumCPU(), "games played at the same time")
+  depth := fs.Int("depth", 3, "search depth in half moves")
+  evalName := fs.String("eval", "heuristic", "evaluator: heuristic, material or nnue")
+  netPath := fs.String("net", "", "network file for -eval nnue")
+  randomPlies := fs.Int("random", 8, "random half moves at the start of each game")
+  seed := fs.Int64("seed", 1, "seed for the random opening moves")
+  maxPlies := fs.Int("maxplies", 300, "half moves before a game is called a draw, 0 for no limit")
+  resignScore := fs.Int("resign-score", 1000, "score a side resigns at")
+  resignPlies := fs.Int("resign-plies", 4, "moves in a row at the resign score, 0 to never resign")
+  drawScore := fs.Int("draw-score", 10, "score within which a game counts as level")
+  drawPlies := fs.Int("draw-plies", 20, "half moves in a row level before a draw, 0 to never adjudicate")
+  drawAfter := fs.Int("draw-after", 80, "half move from which draws are adjudicated")
+  fs.Parse(args)
+
+  evaluator, err := newEvaluator(*evalName, *netPath)
+  if err != nil {
+    return fail(err)
+  }
+  file, err := os.Create(*out)
+  if err != nil {
+    return fail(err)
+  }
+  defer file.Close()
+  var w interface {
+    selfplay.RecordWriter
+    Flush() error
+  }
+  switch *format {
+  case "text":
+    w = selfplay.NewTextWriter(file)
+  case "binary":
+    w = selfplay.NewBinaryWriter(file)
+  default:
+    return usageError(fs, "unknown format %q", *format)
+  }
+  n, err := selfplay.Generate(w, selfplay.Options{
+    Games: *games,
+    Concurrency: *concurrency,
+    Depth: *depth,
+    Evaluator: evaluator,
+    RandomPlies: *randomPlies,
+    Seed: *seed,
+    MaxPlies: *maxPlies,
+    ResignScore: *resignScore,
+    ResignPlies: *resignPlies,
+    DrawScore: *drawScore,
+    DrawPlies: *drawPlies,
+    DrawAfter: *drawAfter,
+  })
+  if err == nil {
+    err = w.Flush()
+  }
+  if err != nil {
+    return fail(err)
+  }
+  fmt.Printf("wrote %d positions to %s\n", n, *out)
+  return ExitOK
+}
+
+type playerFlags struct {
+  depth *int
+  eval *string
+  net *string
+  params *string
+}
+
+func newPlayerFlags(fs *flag.FlagSet, n string, depth int) playerFlags {
+  return playerFlags{
+    depth: fs.Int("depth"+n, depth, "search depth of engine "+n),
+    eval: fs.String("eval"+n, "heuristic", "evaluator of engine "+n+": heuristic, material or nnue"),
+    net: fs.String("net"+n, "", "network file of engine "+n+" for nnue"),
+    params: fs.String("params"+n, "", "evaluation weights of engine "+n),
+  }
+}
+
+func (f playerFlags) player(name string, threads int) (match.Player, error) {
+  evaluator, err := newEvaluator(*f.eval, *f.net)
+  if err != nil {
+    return match.Player{}, err
+  }
+  if *f.params != "" {
+    if *f.eval != "heuristic" {
+      return match.Player{}, fmt.Errorf("-params only works with the heuristic evaluator")
+    }
+    params, err := chess.LoadEvalParams(*f.params)
+    if err != nil {
+      return match.Player{}, err
+    }
+    evaluator = engine.HeuristicEvaluator{Params: params}
+  }
+  return match.Player{Name: name, Depth: *f.depth, Threads: threads, Evaluator: evaluator}, nil
+}
+
+func playMatch(args []string) int {
+  fs := newFlagSet("match", "", "Plays engine 1 against engine 2, results are from engine 1's side.")
+  games := fs.Int("games", 100, "number of games, played in pairs with colors swapped")
+  concurrency := fs.Int("concurrency", runtime.NumCPU(), "games played at the same time")
+  threads := fs.Int("threads", 1, "search threads per engine")
+  openingsPath := fs.String("openings", "", "file with one FEN or move list per line")
+  sprt := fs.Bool("sprt", false, "stop early once an SPRT is decided")
+  elo0 := fs.Float64("elo0", 0, "SPRT: elo difference under H0")
+  elo1 := fs.Float64("elo1", 5, "SPRT: elo difference under H1")
+  alpha := fs.Float64("alpha", 0.05, "SPRT: false positive rate")
+  beta := fs.Float64("beta", 0.05, "SPRT: false negative rate")
+  flags1 := newPlayerFlags(fs, "1", 4)
+  flags2 := newPlayerFlags(fs, "2", 4)
+  fs.Parse(args)
+  if *sprt && *openingsPath == "" {
+    return usageError(fs, "-sprt needs -openings, games from one start position repeat")
+  }
+
+  first, err := flags1.player("engine1", *threads)
+  if err != nil {
+    return fail(err)
+  }
+  second, err := flags2.player("engine2", *threads)
+  if err != nil {
+    return fail(err)
+  }
+  opts := match.Options{Games: *games, Concurrency: *concurrency, Log: os.Stdout}
+  if *openingsPath != "" {
+    opts.Openings, err = match.LoadOpenings(*openingsPath)
+    if err != nil {
+      return fail(err)
+    }
+  }
+  if *sprt {
+    opts.SPRT = &match.SPRT{Elo0: *elo0, Elo1: *elo1, Alpha: *alpha, Beta: *beta}
+  }
+  result, err := match.Run(first, second, opts)
+  fmt.Printf("games %d  %s\n", result.Stats.Games(), result.Stats)
+  if err != nil {
+    return fail(fmt.Errorf("match aborted: %w", err))
+  }
+  if opts.SPRT != nil {
+    lower, upper := opts.SPRT.Bounds()
+    fmt.Printf("sprt llr %.2f (%.2f, %.2f) %s\n", opts.SPRT.LLR(result.Stats), lower, upper, result.Decision)
+  }
+  return ExitOK
+}
+
+func tune(args []string) int {
+  fs := newFlagSet("tune", "<positions file>", "Each line of the positions file is a FEN followed by the game result (1-0, 0-1, 1/2-1/2).")
+  out := fs.String("out", "eval_params.json", "file to write the tuned weights to")
+  paramsPath := fs.String("params", "", "weights to start from, defaults to the built in ones")
+  iterations := fs.Int("iterations", 0, "passes over every weight, 0 runs until nothing improves")
+  step := fs.Int("step", 1, "amount a weight is changed by per try")
+  fs.Parse(args)
+  if fs.NArg() != 1 {
+    return usageError(fs, "expected one positions file")
+  }
+  if err := loadParams(*paramsPath); err != nil {
+    return fail(err)
+  }
+  positions, err := tuner.LoadPositions(fs.Arg(0))
+  if err != nil {
+    return fail(err)
+  }
+  tuned := tuner.Tune(chess.ActiveEvalParams, positions, tuner.Options{
+    Iterations: *iterations,
+    Step: *step,
+    Log: os.Stdout,
+  })
+  if err := chess.SaveEvalParams(*out, tuned); err != nil {
+    return fail(err)
+  }
+  fmt.Printf("wrote %s\n", *out)
+  return ExitOK
+}
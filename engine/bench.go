@@ -0,0 +1,92 @@
+package engine
+
+import (
+  "fmt"
+  "io"
+  "time"
+  chess "chess/board"
+)
+
+var BenchFENs = []string{
+  "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+  "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+  "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+  "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+  "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+}
+
+func Bench(w io.Writer, depth int) (SearchStats, time.Duration, error) {
+  // Searches every bench position to depth on one thread with a fresh
+  // table, printing a line per position. The search is deterministic
+  // so the total node count changes only when the search does, which
+  // makes it usable as a signature for a build.
+  var total SearchStats
+  var elapsed time.Duration
+  for i, fen := range BenchFENs {
+    board, err := chess.NewBoardFromFEN(fen)
+    if err != nil {
+      return total, 0, err
+    }
+    ab := AlphaBetaInputProvider{SearchDepth: depth, TT: NewTranspositionTable(DefaultHashMB)}
+    start := time.Now()
+    result := ab.Search(board)
+    took := time.Since(start)
+    elapsed += took
+    total.add(result.Stats)
+    fmt.Fprintf(w, "position %d  move %-6s %s  time %dms\n", i+1, result.Move, result.Stats, took.Milliseconds())
+  }
+  return total, elapsed, nil
+}
+
+func NPS(nodes uint64, elapsed time.Duration) uint64 {
+  if elapsed <= 0 {
+    return 0
+  }
+  return uint64(float64(nodes) / elapsed.Seconds())
+}
+
+func TimeToDepth(depth, threads int) (time.Duration, uint64, error) {
+  // searches every bench position to depth with a fresh table and
+  // returns the total time and nodes
+  var elapsed time.Duration
+  var nodes uint64
+  for _, fen := range BenchFENs {
+    board, err := chess.NewBoardFromFEN(fen)
+    if err != nil {
+      return 0, 0, err
+    }
+    ab := AlphaBetaInputProvider{SearchDepth: depth, Threads: threads}
+    start := time.Now()
+    result := ab.Search(board)
+    elapsed += time.Since(start)
+    nodes += result.Nodes
+  }
+  return elapsed, nodes, nil
+}
+
+func scalingThreads(maxThreads int) []int {
+  // 1, 2, 4 ... doubling up to maxThreads, which is always last
+  var counts []int
+  for threads := 1; threads < maxThreads; threads *= 2 {
+    counts = append(counts, threads)
+  }
+  return append(counts, max(maxThreads, 1))
+}
+
+func SMPScaling(w io.Writer, depth, maxThreads int) error {
+  // prints time to depth for 1, 2, 4 ... maxThreads threads and the
+  // speedup over a single thread
+  var base time.Duration
+  for _, threads := range scalingThreads(maxThreads) {
+    elapsed, nodes, err := TimeToDepth(depth, threads)
+    if err != nil {
+      return err
+    }
+    if threads == 1 {
+      base = elapsed
+    }
+    fmt.Fprintf(w, "threads %2d  depth %d  time %8dms  nodes %10d  speedup %.2fx\n",
+      threads, depth, elapsed.Milliseconds(), nodes, float64(base)/float64(elapsed))
+  }
+  return nil
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+  "fmt"
+  "slices"
+  "testing"
+  chess "chess/board"
+)
+
+func TestScalingThreads(t *testing.T) {
+  tests := []struct {
+    maxThreads int
+    want []int
+  }{
+    {0, []int{1}},
+    {1, []int{1}},
+    {2, []int{1, 2}},
+    {3, []int{1, 2, 3}},
+    {6, []int{1, 2, 4, 6}},
+    {8, []int{1, 2, 4, 8}},
+  }
+  for _, tt := range tests {
+    if got := scalingThreads(tt.maxThreads); !slices.Equal(got, tt.want) {
+      t.Errorf("scalingThreads(%d) = %v, want %v", tt.maxThreads, got, tt.want)
+    }
+  }
+}
+
+func BenchmarkSearchThreads(b *testing.B) {
+  // time to depth 3 over the bench positions with a fresh table for
+  // every search, run with -bench SearchThreads
+  var boards []*chess.Board
+  for _, fen := range BenchFENs {
+    board, err := chess.NewBoardFromFEN(fen)
+    if err != nil {
+      b.Fatal(err)
+    }
+    boards = append(boards, board)
+  }
+  for _, threads := range scalingThreads(8) {
+    b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+      var nodes uint64
+      for i := 0; i < b.N; i++ {
+        for _, board := range boards {
+          ab := AlphaBetaInputProvider{SearchDepth: 3, Threads: threads, TT: NewTranspositionTable(16)}
+          nodes += ab.Search(board.Clone()).Nodes
+        }
+      }
+      b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+    })
+  }
+}
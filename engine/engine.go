@@ -1,12 +1,35 @@
 package engine
 
 import (
-  "math"
   chess "chess/board"
   "fmt"
   "sort"
+  "strings"
+  "sync/atomic"
 )
 
+// Checkmate scores chess.MateScore for the winner, less the plies it
+// takes to get there so the search goes for the quickest mate and puts
+// off being mated. Scores within maxMatePly of MateScore are mates.
+const MateScore = chess.MateScore
+
+const maxMatePly = 1000
+
+func IsMate(score int) bool {
+  return score >= MateScore-maxMatePly || score <= -MateScore+maxMatePly
+}
+
+func MatePlies(score int) int {
+  // the plies to the mate in a mate score, 0 for any other score
+  if !IsMate(score) {
+    return 0
+  }
+  if score < 0 {
+    score = -score
+  }
+  return max(MateScore-score, 0)
+}
+
 type EngineMove struct {
   Move chess.Move
   Score int
@@ -14,8 +37,8 @@ type EngineMove struct {
 
 func PlayEngine(depth int) {
 	var board *chess.Board = chess.NewBoard()
-  provider1 := AlphaBetaInputProvider{depth}
-  provider2 := AlphaBetaInputProvider{depth}
+  provider1 := AlphaBetaInputProvider{SearchDepth: depth}
+  provider2 := AlphaBetaInputProvider{SearchDepth: depth}
   handler1 := AlphaBetaOutputHandler{}
   handler2 := AlphaBetaOutputHandler{}
   config := chess.GameConfig{}
@@ -34,49 +57,66 @@ func PlayEngine(depth int) {
 }
 
 func AlphaBetaSearch(board *chess.Board, alpha, beta, depth int) int {
-  if (depth == 0 || board.IsCheckmate() || board.IsStalemate() || board.IsThreefold() || board.Is50Moves()) {
-    eval := chess.Evaluate(board)
-    return eval
-  }
-  color := board.Turn
-  legalMoves := board.GetAllLegalMoves(color)
-  if color == chess.White {
-    maxEval := math.MinInt32
-    for _, move := range legalMoves {
-      simBoard := board.Clone()
-      simBoard.MovePiece(simBoard.GetPieceAt(move.Start, simBoard.Turn), move)
-      simBoard.Turn = simBoard.Turn.Other()
-      simBoard.MoveCounter++
-      simBoard.History[simBoard.GetZobristHash()]++
-      eval := AlphaBetaSearch(simBoard, alpha, beta, depth - 1)
-      maxEval = max(maxEval, eval)
-      alpha = max(alpha, eval)
-      if eval >= beta {
-        break
-      }
+  // single threaded search without a transposition table using
+  // the default evaluator
+  t := newSearchThread(0, nil, nil, nil, board)
+  return t.alphaBeta(board, alpha, beta, depth, 0)
+}
+
+func Quiescence(board *chess.Board, alpha, beta int) int {
+  t := newSearchThread(0, nil, nil, nil, board)
+  return t.quiescence(board, alpha, beta, 0)
+}
+
+func noisyMoves(board *chess.Board) []chess.Move {
+  // returns the captures and promotions that do not lose material,
+  // best exchange first
+  var moves []EngineMove
+  for _, move := range board.GetAllLegalMoves(board.Turn) {
+    if !board.IsCapture(move) && move.Promotion == chess.Empty {
+      continue
     }
-  return maxEval
-  } else {
-    minEval := math.MaxInt32
-    for _, move := range legalMoves {
-      simBoard := board.Clone()
-      simBoard.MovePiece(simBoard.GetPieceAt(move.Start, simBoard.Turn), move)
-      simBoard.Turn = simBoard.Turn.Other()
-      simBoard.MoveCounter++
-      simBoard.History[simBoard.GetZobristHash()]++
-      eval := AlphaBetaSearch(simBoard, alpha, beta, depth - 1)
-      minEval = min(minEval, eval)
-      beta = min(beta, eval)
-      if eval <= alpha {
-        break
-      }
+    see := board.SEE(move)
+    if see < 0 {
+      continue
     }
-  return minEval
+    moves = append(moves, EngineMove{move, see})
+  }
+  sort.Slice(moves, func(i, j int) bool {
+    return moves[i].Score > moves[j].Score
+  })
+  noisy := make([]chess.Move, len(moves))
+  for i := range moves {
+    noisy[i] = moves[i].Move
+  }
+  return noisy
+}
+
+func makeMove(board *chess.Board, move chess.Move) *chess.Board {
+  // plays move on a copy of board the same way the game loop does
+  simBoard := board.Clone()
+  simBoard.PlayMove(move)
+  return simBoard
+}
+
+func FormatLine(line PVLine) string {
+  // score is in centipawns from white's point of view
+  pv := make([]string, len(line.PV))
+  for i, move := range line.PV {
+    pv[i] = move.String()
   }
+  return fmt.Sprintf("%-6s score %6d  depth %d  pv %s", line.Move, line.Score, line.Depth, strings.Join(pv, " "))
 }
 
 type AlphaBetaInputProvider struct {
-    SearchDepth int // in half moves
+    SearchDepth int // in half moves, Limits.Depth takes precedence
+    Limits SearchLimits
+    Threads int // search goroutines, 0 or 1 searches single threaded
+    TT *TranspositionTable // shared between moves when set
+    MultiPV int // number of ranked root moves to report
+    Stop *atomic.Bool // ends the search early when set to true
+    Info func(SearchResult) // called after every finished depth
+    Evaluator Evaluator // HeuristicEvaluator when nil
 }
 
 type AlphaBetaOutputHandler struct {}
@@ -90,47 +130,19 @@ func (handler AlphaBetaOutputHandler) DisplayCheck() {
 }
 
 func (ab AlphaBetaInputProvider) GetMove(board *chess.Board) (chess.Move, error) {
-  var bestMove chess.Move
-  var bestEval int
-  color := board.Turn
-  alpha := math.MinInt32
-  beta := math.MaxInt32
-  if color == chess.White {
-    bestEval = math.MinInt32
-  } else {
-    bestEval = math.MaxInt32
-  }
-  legalMoves := ab.sortMoves(board)
+  result := ab.Search(board)
+  reportMove(board, result)
+  return result.Move, nil
+}
 
-  for _, eMove := range legalMoves {
-    simBoard := board.Clone()
-    move := eMove.Move
-    piece := simBoard.GetPieceAt(move.Start, simBoard.Turn)
-    simBoard.MovePiece(piece, move)
-    simBoard.Turn = simBoard.Turn.Other()
-    simBoard.MoveCounter++
-    simBoard.History[simBoard.GetZobristHash()]++
-    eval := AlphaBetaSearch(simBoard, alpha, beta, ab.SearchDepth-1)
-    if color == chess.White {
-      if eval >= bestEval {
-        bestEval = eval
-        bestMove = move
-      }
-      alpha = max(alpha, eval)
-    } else if color == chess.Black {
-      if eval <= bestEval{
-        bestEval = eval
-        bestMove = move
+func reportMove(board *chess.Board, result SearchResult) {
+  if len(result.Lines) > 1 {
+    for i, line := range result.Lines {
+      fmt.Printf("%d. %s\n", i+1, FormatLine(line))
     }
-      beta= min(beta, eval)
-    }
-    if alpha >= beta {
-      break}
   }
-	fmt.Printf("Engine chose move: %v with evaluation: %d\n", bestMove, bestEval)
-  println(board.TotalMoves)
-
-  return bestMove, nil
+	fmt.Printf("Engine chose move: %v with evaluation: %d\n", result.Move, result.Score)
+  fmt.Printf("depth %d  %s\n", result.Depth, result.Stats)
 }
 
 func (ab AlphaBetaInputProvider) sortMoves(board *chess.Board) []EngineMove {
@@ -140,19 +152,15 @@ func (ab AlphaBetaInputProvider) sortMoves(board *chess.Board) []EngineMove {
   for i := range legalMoves {
     move := &legalMoves[i]
     eMove := EngineMove{legalMoves[i], 0}
-    capturedPiece := board.GetPieceAt(move.End, color.Other())
-    if capturedPiece != chess.Empty {
-      switch capturedPiece {
-      case chess.Pawns:
-        eMove.Score += 100
-      case chess.Knights:
-        eMove.Score += 300
-      case chess.Bishops:
-        eMove.Score += 330
-      case chess.Rooks:
-        eMove.Score += 500
-      case chess.Queens:
-        eMove.Score += 900
+    isCapture := board.IsCapture(*move)
+    if isCapture {
+      // winning and even captures go first, losing ones after the
+      // quiet moves that land on safe squares
+      see := board.SEE(*move)
+      if see >= 0 {
+        eMove.Score += 1000 + see
+      } else {
+        eMove.Score += see
       }
     }
     if move.Promotion != chess.Empty {
@@ -163,7 +171,7 @@ func (ab AlphaBetaInputProvider) sortMoves(board *chess.Board) []EngineMove {
     if simBoard.IsCheck(color.Other()) {
       eMove.Score += 100
     }
-    if !board.IsAttacked(color.Other(), move.End) {
+    if !isCapture && !board.IsAttacked(color.Other(), move.End) {
       eMove.Score += 500
     }
     // PST
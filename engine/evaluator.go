@@ -0,0 +1,66 @@
+package engine
+
+import (
+  "fmt"
+  "math/bits"
+  chess "chess/board"
+)
+
+// Evaluator scores a position in centipawns from white's point of
+// view. The search takes care of checkmate and draws itself so an
+// evaluator only ever sees positions with moves left to play.
+type Evaluator interface {
+  Evaluate(board *chess.Board) int
+}
+
+// IncrementalEvaluator is an Evaluator that keeps its own state up to
+// date as the search walks the tree instead of starting from scratch
+// at every node. MakeMove is called with the positions before and
+// after move is played and UnmakeMove when the search comes back to
+// the position before it. Every search thread gets its own Fork,
+// reset to the root position.
+type IncrementalEvaluator interface {
+  Evaluator
+  Reset(board *chess.Board)
+  MakeMove(board, child *chess.Board, move chess.Move)
+  UnmakeMove(board, child *chess.Board, move chess.Move)
+  Fork() IncrementalEvaluator
+}
+
+// HeuristicEvaluator is the hand written evaluation in chess.Evaluate.
+// Params picks the weights, nil uses chess.ActiveEvalParams.
+type HeuristicEvaluator struct {
+  Params *chess.EvalParams
+}
+
+func (h HeuristicEvaluator) Evaluate(board *chess.Board) int {
+  if h.Params == nil {
+    return chess.Evaluate(board)
+  }
+  return chess.EvaluateWith(board, h.Params)
+}
+
+// MaterialEvaluator only counts material, for testing the search.
+type MaterialEvaluator struct {}
+
+var materialValues = [7]int{0, 100, 300, 300, 500, 900, 0}
+
+func (MaterialEvaluator) Evaluate(board *chess.Board) int {
+  score := 0
+  for p := chess.Pawns; p <= chess.Queens; p++ {
+    score += bits.OnesCount64(uint64(board.PieceBB[chess.White][p])) * materialValues[p]
+    score -= bits.OnesCount64(uint64(board.PieceBB[chess.Black][p])) * materialValues[p]
+  }
+  return score
+}
+
+func NewEvaluator(name string) (Evaluator, error) {
+  // looks up an evaluator by the name used on the command line
+  switch name {
+  case "", "heuristic":
+    return HeuristicEvaluator{}, nil
+  case "material":
+    return MaterialEvaluator{}, nil
+  }
+  return nil, fmt.Errorf("unknown evaluator %q", name)
+}
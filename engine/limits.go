@@ -0,0 +1,98 @@
+package engine
+
+import (
+  "sync/atomic"
+  "time"
+  chess "chess/board"
+)
+
+// MaxDepth is how deep iterative deepening goes when only a node or
+// time limit is set.
+const MaxDepth = 64
+
+// SearchLimits says when a search should stop. Zero fields are not
+// limits, a search stops at the first limit it reaches and returns
+// the deepest iteration it finished.
+type SearchLimits struct {
+  Depth int // overrides SearchDepth when set
+  Nodes uint64
+  Time time.Duration
+  Mate int // look for a mate in this many moves and stop once found
+  SearchMoves []chess.Move // only search these root moves
+}
+
+func (ab AlphaBetaInputProvider) maxDepth() int {
+  depth := ab.SearchDepth
+  if ab.Limits.Depth > 0 {
+    depth = ab.Limits.Depth
+  }
+  if ab.Limits.Mate > 0 {
+    // a mate in n moves is 2n-1 plies deep
+    mateDepth := 2*ab.Limits.Mate - 1
+    if depth <= 0 || ab.Limits.Depth == 0 || mateDepth < depth {
+      depth = mateDepth
+    }
+  }
+  if depth <= 0 {
+    depth = MaxDepth
+  }
+  return depth
+}
+
+func (limits SearchLimits) filter(moves []chess.Move) []chess.Move {
+  // the root moves that are also in SearchMoves, or every move when
+  // none of them are
+  if len(limits.SearchMoves) == 0 {
+    return moves
+  }
+  var kept []chess.Move
+  for _, move := range moves {
+    for _, allowed := range limits.SearchMoves {
+      if move == allowed {
+        kept = append(kept, move)
+        break
+      }
+    }
+  }
+  if len(kept) == 0 {
+    return moves
+  }
+  return kept
+}
+
+// limiter enforces the node and time limits for every thread of one
+// search by setting its stop flag
+type limiter struct {
+  deadline time.Time // zero for none
+  maxNodes uint64
+  nodes atomic.Uint64
+}
+
+func newLimiter(limits SearchLimits) *limiter {
+  if limits.Nodes == 0 && limits.Time == 0 {
+    return nil
+  }
+  l := &limiter{maxNodes: limits.Nodes}
+  if limits.Time > 0 {
+    l.deadline = time.Now().Add(limits.Time)
+  }
+  return l
+}
+
+func (l *limiter) node(stop *atomic.Bool, threadNodes uint64) {
+  if l.maxNodes > 0 && l.nodes.Add(1) >= l.maxNodes {
+    stop.Store(true)
+  }
+  // the clock is only read every few nodes
+  if !l.deadline.IsZero() && threadNodes&63 == 0 && time.Now().After(l.deadline) {
+    stop.Store(true)
+  }
+}
+
+func isMateFor(color chess.Color, score, plies int) bool {
+  // whether score mates for color within plies
+  if color == chess.Black {
+    score = -score
+  }
+  return score > 0 && IsMate(score) && MatePlies(score) <= plies
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+  "sync"
+  "sync/atomic"
+  "time"
+  chess "chess/board"
+)
+
+// PonderingInputProvider searches the reply it expects from its own
+// principal variation while the opponent is thinking. If the opponent
+// plays that reply the search goes on within the time limit and its
+// deepest finished iteration is played, otherwise it is stopped and
+// thrown away.
+type PonderingInputProvider struct {
+  AlphaBetaInputProvider
+  mu sync.Mutex
+  expected chess.Move
+  hasExpected bool
+  ponder *ponderSearch
+}
+
+type ponderSearch struct {
+  hash uint64 // position after the expected reply
+  stop atomic.Bool
+  done chan struct{}
+  result SearchResult
+}
+
+func (p *PonderingInputProvider) GetMove(board *chess.Board) (chess.Move, error) {
+  result, hit := p.ponderResult(board)
+  if !hit {
+    result = p.Search(board)
+  }
+  p.mu.Lock()
+  p.expected, p.hasExpected = expectedReply(result)
+  p.mu.Unlock()
+  reportMove(board, result)
+  return result.Move, nil
+}
+
+func (p *PonderingInputProvider) Ponder(board *chess.Board) {
+  // board is the position after our move with the opponent to move
+  p.StopPonder()
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  if !p.hasExpected || !board.IsLegal(p.expected) {
+    return
+  }
+  next := board.Clone()
+  next.PlayMove(p.expected)
+  ps := &ponderSearch{hash: next.GetZobristHash(), done: make(chan struct{})}
+  ab := p.AlphaBetaInputProvider
+  ab.Stop = &ps.stop
+  ab.Info = nil
+  // our clock only starts on a ponder hit, ponderResult applies the
+  // time limit from then
+  ab.Limits.Time = 0
+  go func() {
+    defer close(ps.done)
+    ps.result = ab.Search(next)
+  }()
+  p.ponder = ps
+}
+
+func (p *PonderingInputProvider) StopPonder() {
+  p.mu.Lock()
+  ps := p.ponder
+  p.ponder = nil
+  p.mu.Unlock()
+  if ps != nil {
+    ps.stop.Store(true)
+    <-ps.done
+  }
+}
+
+func (p *PonderingInputProvider) ponderResult(board *chess.Board) (SearchResult, bool) {
+  // On a ponder hit the search carries on with the time limit counted
+  // from now, and the deepest iteration it finished is the answer. On
+  // a miss it is stopped.
+  p.mu.Lock()
+  ps := p.ponder
+  p.ponder = nil
+  p.mu.Unlock()
+  if ps == nil {
+    return SearchResult{}, false
+  }
+  if ps.hash != board.GetZobristHash() {
+    ps.stop.Store(true)
+    <-ps.done
+    return SearchResult{}, false
+  }
+  if p.Limits.Time > 0 {
+    timer := time.AfterFunc(p.Limits.Time, func() {
+      ps.stop.Store(true)
+    })
+    defer timer.Stop()
+  }
+  <-ps.done
+  return ps.result, ps.result.Depth > 0
+}
+
+func expectedReply(result SearchResult) (chess.Move, bool) {
+  if len(result.Lines) == 0 || len(result.Lines[0].PV) < 2 {
+    return chess.Move{}, false
+  }
+  return result.Lines[0].PV[1], true
+}
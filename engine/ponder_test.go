@@ -0,0 +1,91 @@
+package engine
+
+import (
+  "testing"
+  "time"
+  chess "chess/board"
+)
+
+func ponderAfter(t *testing.T, p *PonderingInputProvider, ours, expected string) *chess.Board {
+  // plays our move from the start, ponders on expected as the reply
+  // and returns the position after it
+  t.Helper()
+  board := chess.NewBoard()
+  move, _ := chess.ParseMove(ours)
+  board.PlayMove(move)
+  p.expected, _ = chess.ParseMove(expected)
+  p.hasExpected = true
+  p.Ponder(board.Clone())
+  if p.ponder == nil {
+    t.Fatalf("no ponder search on %s", expected)
+  }
+  return board
+}
+
+func play(t *testing.T, board *chess.Board, move string) {
+  t.Helper()
+  m, err := chess.ParseMove(move)
+  if err != nil || !board.IsLegal(m) {
+    t.Fatalf("cannot play %s", move)
+  }
+  board.PlayMove(m)
+}
+
+const ponderNodes = 3000
+
+func TestPonderHitUnderTimeLimit(t *testing.T) {
+  // The time limit is far too short for the ponder search to get
+  // anywhere, so only the node limit can end it. On the hit its
+  // result is played as it is.
+  limits := SearchLimits{Time: time.Nanosecond, Nodes: ponderNodes}
+  p := &PonderingInputProvider{AlphaBetaInputProvider: AlphaBetaInputProvider{Limits: limits}}
+  board := ponderAfter(t, p, "e2e4", "e7e5")
+  <-p.ponder.done
+  play(t, board, "e7e5")
+  result, hit := p.ponderResult(board)
+  if !hit || result.Nodes < ponderNodes || !board.IsLegal(result.Move) {
+    t.Fatalf("hit %v after %d nodes with %s, want a hit after %d nodes", hit, result.Nodes, result.Move, ponderNodes)
+  }
+  // the same search on the real position finds the same
+  want := AlphaBetaInputProvider{Limits: SearchLimits{Nodes: ponderNodes}}.Search(board)
+  if result.Move != want.Move || result.Depth != want.Depth || result.Score != want.Score {
+    t.Errorf("ponder search found %s at depth %d, a normal search %s at depth %d", result.Move, result.Depth, want.Move, want.Depth)
+  }
+}
+
+func TestPonderHitAtDepth(t *testing.T) {
+  p := &PonderingInputProvider{AlphaBetaInputProvider: AlphaBetaInputProvider{SearchDepth: 2}}
+  board := ponderAfter(t, p, "d2d4", "d7d5")
+  play(t, board, "d7d5")
+  if result, hit := p.ponderResult(board); !hit || result.Depth != 2 {
+    t.Errorf("hit %v at depth %d, want a hit at depth 2", hit, result.Depth)
+  }
+}
+
+func TestPonderMiss(t *testing.T) {
+  // the ponder search would run on for ever, a miss has to stop it
+  p := &PonderingInputProvider{}
+  board := ponderAfter(t, p, "e2e4", "e7e5")
+  ps := p.ponder
+  play(t, board, "c7c5")
+  if _, hit := p.ponderResult(board); hit {
+    t.Error("a different reply counted as a ponder hit")
+  }
+  if !ps.stop.Load() {
+    t.Error("the ponder search was not stopped")
+  }
+}
+
+func TestNoPonderWithoutReply(t *testing.T) {
+  p := &PonderingInputProvider{}
+  board := chess.NewBoard()
+  p.Ponder(board)
+  if p.ponder != nil {
+    t.Error("pondering without an expected reply")
+  }
+  p.expected, p.hasExpected = chess.Move{Start: 12, End: 36}, true
+  p.Ponder(board)
+  if p.ponder != nil {
+    t.Error("pondering on an illegal reply")
+  }
+}
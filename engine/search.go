@@ -0,0 +1,470 @@
+package engine
+
+import (
+  "fmt"
+  "math"
+  "sync"
+  "sync/atomic"
+  chess "chess/board"
+)
+
+type SearchResult struct {
+  Move chess.Move
+  Score int
+  Depth int // deepest fully searched iteration
+  Nodes uint64
+  Lines []PVLine // best first, up to MultiPV of them
+  Stats SearchStats // summed over every thread
+}
+
+type PVLine struct {
+  Move chess.Move
+  Score int
+  Depth int
+  PV []chess.Move // starts with Move
+}
+
+// SearchStats counts what the search did. Nodes includes QNodes,
+// the quiescence nodes.
+type SearchStats struct {
+  Nodes uint64
+  QNodes uint64
+  TTHits uint64 // probes that found the position
+  Cutoffs uint64 // nodes that failed high
+  FirstMoveCutoffs uint64 // of those, the ones where the first move did it
+  Depth int
+}
+
+func (s *SearchStats) add(other SearchStats) {
+  s.Nodes += other.Nodes
+  s.QNodes += other.QNodes
+  s.TTHits += other.TTHits
+  s.Cutoffs += other.Cutoffs
+  s.FirstMoveCutoffs += other.FirstMoveCutoffs
+  s.Depth = max(s.Depth, other.Depth)
+}
+
+func (s SearchStats) FirstMoveCutoffRate() float64 {
+  // how often move ordering put the refutation first, closer to 1 is
+  // better ordering
+  if s.Cutoffs == 0 {
+    return 0
+  }
+  return float64(s.FirstMoveCutoffs) / float64(s.Cutoffs)
+}
+
+func (s SearchStats) BranchingFactor() float64 {
+  // effective branching factor, the number of children per node a
+  // uniform tree of the same depth and size would have
+  if s.Depth == 0 || s.Nodes == 0 {
+    return 0
+  }
+  return math.Pow(float64(s.Nodes), 1/float64(s.Depth))
+}
+
+func (s SearchStats) String() string {
+  return fmt.Sprintf("nodes %d  qnodes %d  tt hits %d  cutoffs %d  first move %.1f%%  branching %.2f",
+    s.Nodes, s.QNodes, s.TTHits, s.Cutoffs, 100*s.FirstMoveCutoffRate(), s.BranchingFactor())
+}
+
+// One goroutine's view of a search. Every thread owns its own node
+// count and shares the transposition table and stop flag with the
+// others, which is all Lazy SMP needs to split the work.
+type searchThread struct {
+  id int
+  tt *TranspositionTable
+  stop *atomic.Bool
+  limits *limiter // nil without node or time limits
+  stats SearchStats
+  eval Evaluator
+  inc IncrementalEvaluator // same as eval when it keeps state
+}
+
+func newSearchThread(id int, tt *TranspositionTable, stop *atomic.Bool, eval Evaluator, root *chess.Board) *searchThread {
+  if eval == nil {
+    eval = HeuristicEvaluator{}
+  }
+  t := &searchThread{id: id, tt: tt, stop: stop, eval: eval}
+  if inc, ok := eval.(IncrementalEvaluator); ok {
+    t.inc = inc.Fork()
+    t.inc.Reset(root)
+    t.eval = t.inc
+  }
+  return t
+}
+
+func (t *searchThread) countNode() {
+  t.stats.Nodes++
+  if t.limits != nil && t.stop != nil {
+    t.limits.node(t.stop, t.stats.Nodes)
+  }
+}
+
+func (t *searchThread) stopped() bool {
+  return t.stop != nil && t.stop.Load()
+}
+
+func (t *searchThread) play(board *chess.Board, move chess.Move) *chess.Board {
+  child := makeMove(board, move)
+  if t.inc != nil {
+    t.inc.MakeMove(board, child, move)
+  }
+  return child
+}
+
+func (t *searchThread) unplay(board, child *chess.Board, move chess.Move) {
+  if t.inc != nil {
+    t.inc.UnmakeMove(board, child, move)
+  }
+}
+
+func terminalScore(board *chess.Board) (int, bool) {
+  // scores checkmate, stalemate and the draw rules, the bool is
+  // false when the game is not over
+  if result, over := board.Outcome(); over {
+    switch {
+    case result.Draw:
+      return 0, true
+    case result.Winner == chess.White:
+      return MateScore, true
+    }
+    return -MateScore, true
+  }
+  if board.IsCheckmate() {
+    if board.Turn == chess.Black {
+      return MateScore, true
+    }
+    return -MateScore, true
+  }
+  if board.IsStalemate() || board.IsThreefold() || board.Is50Moves() {
+    return 0, true
+  }
+  return 0, false
+}
+
+func mateAt(score, ply int) int {
+  // a terminal score as seen from ply plies above it
+  switch {
+  case score >= MateScore:
+    return score - ply
+  case score <= -MateScore:
+    return score + ply
+  }
+  return score
+}
+
+func scoreToTT(score, ply int) int {
+  // The table keeps mate scores as the distance from the stored
+  // position rather than from the root, so they stay right when the
+  // position comes up at another ply.
+  switch {
+  case score >= MateScore-maxMatePly:
+    return score + ply
+  case score <= -MateScore+maxMatePly:
+    return score - ply
+  }
+  return score
+}
+
+func scoreFromTT(score, ply int) int {
+  switch {
+  case score >= MateScore-maxMatePly:
+    return score - ply
+  case score <= -MateScore+maxMatePly:
+    return score + ply
+  }
+  return score
+}
+
+func (t *searchThread) alphaBeta(board *chess.Board, alpha, beta, depth, ply int) int {
+  t.countNode()
+  if t.stopped() {
+    return 0
+  }
+  if score, over := terminalScore(board); over {
+    return mateAt(score, ply)
+  }
+  if depth == 0 {
+    return t.quiescence(board, alpha, beta, ply)
+  }
+
+  var hash uint64
+  var ttMove chess.Move
+  var hasTTMove bool
+  origAlpha, origBeta := alpha, beta
+  if t.tt != nil {
+    hash = board.GetZobristHash()
+    if entry, ok := t.tt.Probe(hash); ok {
+      t.stats.TTHits++
+      ttMove, hasTTMove = entry.Move, entry.HasMove
+      if entry.Depth >= depth {
+        score := scoreFromTT(entry.Score, ply)
+        switch entry.Bound {
+        case BoundExact:
+          return score
+        case BoundLower:
+          alpha = max(alpha, score)
+        case BoundUpper:
+          beta = min(beta, score)
+        }
+        if alpha >= beta {
+          return score
+        }
+      }
+    }
+  }
+
+  color := board.Turn
+  legalMoves := board.GetAllLegalMoves(color)
+  if hasTTMove {
+    moveToFront(legalMoves, ttMove)
+  }
+  var bestEval int
+  var bestMove chess.Move
+  if color == chess.White {
+    bestEval = math.MinInt32
+  } else {
+    bestEval = math.MaxInt32
+  }
+  for i, move := range legalMoves {
+    simBoard := t.play(board, move)
+    eval := t.alphaBeta(simBoard, alpha, beta, depth - 1, ply + 1)
+    t.unplay(board, simBoard, move)
+    if color == chess.White {
+      if eval > bestEval || i == 0 {
+        bestEval, bestMove = eval, move
+      }
+      alpha = max(alpha, eval)
+    } else {
+      if eval < bestEval || i == 0 {
+        bestEval, bestMove = eval, move
+      }
+      beta = min(beta, eval)
+    }
+    if alpha >= beta {
+      t.stats.Cutoffs++
+      if i == 0 {
+        t.stats.FirstMoveCutoffs++
+      }
+      break
+    }
+  }
+
+  if t.tt != nil && !t.stopped() {
+    bound := BoundExact
+    if bestEval <= origAlpha {
+      bound = BoundUpper
+    } else if bestEval >= origBeta {
+      bound = BoundLower
+    }
+    t.tt.Store(hash, TTEntry{scoreToTT(bestEval, ply), depth, bound, bestMove, len(legalMoves) > 0})
+  }
+  return bestEval
+}
+
+func (t *searchThread) quiescence(board *chess.Board, alpha, beta, ply int) int {
+  // searches captures and promotions until the position is quiet
+  // so the static evaluation is never taken in the middle of an
+  // exchange. Captures that lose material by SEE are pruned.
+  t.countNode()
+  t.stats.QNodes++
+  // an evaluator may score a mate it runs into
+  standPat := mateAt(t.eval.Evaluate(board), ply)
+  if t.stopped() {
+    return standPat
+  }
+  color := board.Turn
+  if color == chess.White {
+    if standPat >= beta {
+      return standPat
+    }
+    alpha = max(alpha, standPat)
+  } else {
+    if standPat <= alpha {
+      return standPat
+    }
+    beta = min(beta, standPat)
+  }
+  best := standPat
+  for _, move := range noisyMoves(board) {
+    simBoard := t.play(board, move)
+    eval := t.quiescence(simBoard, alpha, beta, ply+1)
+    t.unplay(board, simBoard, move)
+    if color == chess.White {
+      best = max(best, eval)
+      alpha = max(alpha, eval)
+    } else {
+      best = min(best, eval)
+      beta = min(beta, eval)
+    }
+    if alpha >= beta {
+      break
+    }
+  }
+  return best
+}
+
+func (t *searchThread) searchRoot(board *chess.Board, moves []chess.Move, depth, multiPV int) ([]PVLine, bool) {
+  // Searches every root move to depth and returns them ranked best
+  // first for the side to move. Only the first multiPV lines have
+  // exact scores, the window is narrowed to the worst of those so
+  // the remaining moves just have to prove they are no better.
+  // The bool is false when the search was stopped part way through
+  // and the result should be thrown away.
+  color := board.Turn
+  var lines []PVLine
+  for _, move := range moves {
+    alpha := math.MinInt32
+    beta := math.MaxInt32
+    if len(lines) >= multiPV {
+      if color == chess.White {
+        alpha = lines[multiPV-1].Score
+      } else {
+        beta = lines[multiPV-1].Score
+      }
+    }
+    simBoard := t.play(board, move)
+    eval := t.alphaBeta(simBoard, alpha, beta, depth-1, 1)
+    t.unplay(board, simBoard, move)
+    if t.stopped() {
+      return nil, false
+    }
+    line := PVLine{Move: move, Score: eval, Depth: depth}
+    i := len(lines)
+    for i > 0 && better(color, eval, lines[i-1].Score) {
+      i--
+    }
+    lines = append(lines, PVLine{})
+    copy(lines[i+1:], lines[i:])
+    lines[i] = line
+  }
+  return lines, true
+}
+
+func better(color chess.Color, a, b int) bool {
+  if color == chess.White {
+    return a > b
+  }
+  return a < b
+}
+
+func (t *searchThread) principalVariation(board *chess.Board, move chess.Move, depth int) []chess.Move {
+  // follows the best moves stored in the table from the position
+  // after move. Entries can be overwritten so the line may come up
+  // short, it is never longer than depth.
+  pv := []chess.Move{move}
+  if t.tt == nil {
+    return pv
+  }
+  seen := map[uint64]bool{}
+  b := makeMove(board, move)
+  for len(pv) < depth {
+    hash := b.GetZobristHash()
+    entry, ok := t.tt.Probe(hash)
+    if !ok || !entry.HasMove || seen[hash] || !b.IsLegal(entry.Move) {
+      break
+    }
+    seen[hash] = true
+    pv = append(pv, entry.Move)
+    b = makeMove(b, entry.Move)
+  }
+  return pv
+}
+
+func (t *searchThread) iterate(board *chess.Board, moves []chess.Move, startDepth, maxDepth, multiPV int, report func(SearchResult)) {
+  // iterative deepening, reporting every finished depth and searching
+  // the moves in the previous iteration's ranking on the next one
+  moves = append([]chess.Move(nil), moves...)
+  for depth := startDepth; depth <= maxDepth; depth++ {
+    lines, ok := t.searchRoot(board, moves, depth, multiPV)
+    if !ok {
+      return
+    }
+    t.stats.Depth = depth
+    for i := range lines {
+      moves[i] = lines[i].Move
+    }
+    lines = lines[:min(multiPV, len(lines))]
+    for i := range lines {
+      lines[i].PV = t.principalVariation(board, lines[i].Move, depth)
+    }
+    report(SearchResult{Move: lines[0].Move, Score: lines[0].Score, Depth: depth, Lines: lines})
+  }
+}
+
+func (ab AlphaBetaInputProvider) Search(board *chess.Board) SearchResult {
+  // Lazy SMP. Every thread runs its own iterative deepening on the
+  // same root and they only cooperate through the shared table.
+  // Helper threads start one ply deeper on odd ids so the threads
+  // drift apart and fill the table with different parts of the tree.
+  // The first thread to finish the depth limit stops the others, as
+  // does reaching any other limit in ab.Limits.
+  threads := max(ab.Threads, 1)
+  maxDepth := ab.maxDepth()
+  multiPV := max(ab.MultiPV, 1)
+  tt := ab.TT
+  if tt == nil {
+    tt = NewTranspositionTable(DefaultHashMB)
+  }
+  moves := ab.sortMoves(board)
+  if len(moves) == 0 {
+    score, _ := terminalScore(board)
+    return SearchResult{Score: score}
+  }
+  rootMoves := make([]chess.Move, len(moves))
+  for i := range moves {
+    rootMoves[i] = moves[i].Move
+  }
+  rootMoves = ab.Limits.filter(rootMoves)
+
+  stop := ab.Stop
+  if stop == nil {
+    stop = new(atomic.Bool)
+  }
+  var mu sync.Mutex
+  var wg sync.WaitGroup
+  best := SearchResult{Move: rootMoves[0]}
+  limits := newLimiter(ab.Limits)
+  workers := make([]*searchThread, threads)
+  for i := range workers {
+    workers[i] = newSearchThread(i, tt, stop, ab.Evaluator, board)
+    workers[i].limits = limits
+    startDepth := 1 + i%2
+    if threads == 1 || startDepth > maxDepth {
+      startDepth = 1
+    }
+    wg.Add(1)
+    go func(t *searchThread, startDepth int) {
+      defer wg.Done()
+      t.iterate(board, rootMoves, startDepth, maxDepth, multiPV, func(r SearchResult) {
+        mu.Lock()
+        defer mu.Unlock()
+        if r.Depth > best.Depth || (r.Depth == best.Depth && t.id == 0) {
+          best.Move, best.Score, best.Depth, best.Lines = r.Move, r.Score, r.Depth, r.Lines
+          if ab.Info != nil {
+            ab.Info(best)
+          }
+        }
+        if r.Depth >= maxDepth || (ab.Limits.Mate > 0 && isMateFor(board.Turn, r.Score, 2*ab.Limits.Mate-1)) {
+          stop.Store(true)
+        }
+      })
+    }(workers[i], startDepth)
+  }
+  wg.Wait()
+  for _, t := range workers {
+    best.Stats.add(t.stats)
+  }
+  best.Nodes = best.Stats.Nodes
+  return best
+}
+
+func moveToFront(moves []chess.Move, move chess.Move) {
+  for i := range moves {
+    if moves[i] == move {
+      copy(moves[1:i+1], moves[:i])
+      moves[0] = move
+      return
+    }
+  }
+}
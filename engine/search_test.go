@@ -0,0 +1,101 @@
+package engine
+
+import (
+  "strings"
+  "testing"
+  chess "chess/board"
+  variant "chess/variant"
+)
+
+func TestDropInPrincipalVariation(t *testing.T) {
+  // white's only move is Ka2, then a queen drop on b2 or a3 mates and
+  // the line after the root move is read back from the table
+  board, err := variant.NewBoardFromFEN(variant.Crazyhouse{}, "8/8/8/8/8/8/2k5/K7[q] w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  result := AlphaBetaInputProvider{SearchDepth: 2}.Search(board)
+  pv := result.Lines[0].PV
+  if len(pv) < 2 || pv[1].Drop != chess.Queens {
+    t.Fatalf("pv %v, want a queen drop after %s", pv, result.Move)
+  }
+  b := board.Clone()
+  for _, move := range pv {
+    if !b.IsLegal(move) {
+      t.Fatalf("pv %v: %s is illegal", pv, move)
+    }
+    b.PlayMove(move)
+  }
+  if !b.IsCheckmate() {
+    t.Errorf("pv %v does not end in mate", pv)
+  }
+}
+
+func searchFEN(t *testing.T, fen string, ab AlphaBetaInputProvider) SearchResult {
+  t.Helper()
+  board, err := chess.NewBoardFromFEN(fen)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return ab.Search(board)
+}
+
+func TestMateDistance(t *testing.T) {
+  tests := []struct {
+    name string
+    fen string
+    depth int
+    score int
+  }{
+    {"mate in 1", "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", 3, MateScore - 1},
+    {"mate in 2", "k7/8/2K5/8/8/8/8/7R w - - 0 1", 3, MateScore - 3},
+    {"mate in 2 searched deeper", "k7/8/2K5/8/8/8/8/7R w - - 0 1", 5, MateScore - 3},
+    {"black mates in 2", "7r/8/8/8/8/2k5/8/K7 b - - 0 1", 4, -MateScore + 3},
+    {"mated in 1", "k7/8/1K6/8/8/8/8/7R b - - 0 1", 4, MateScore - 2},
+    {"mated now", "R5k1/5ppp/8/8/8/8/5PPP/6K1 b - - 0 1", 3, MateScore},
+  }
+  for _, tt := range tests {
+    result := searchFEN(t, tt.fen, AlphaBetaInputProvider{SearchDepth: tt.depth})
+    if result.Score != tt.score {
+      t.Errorf("%s: scored %d (%d plies), want %d plies", tt.name, result.Score, MatePlies(result.Score), MatePlies(tt.score))
+    }
+  }
+}
+
+func TestMateDistanceThroughTable(t *testing.T) {
+  // The first search stores the position after 1.Kb6 Kb8 a ply from
+  // its root, the second finds it two plies from its own root after
+  // the same moves. The mate is still a ply away from it.
+  tt := NewTranspositionTable(1)
+  searchFEN(t, "k7/8/1K6/8/8/8/8/7R b - - 1 1", AlphaBetaInputProvider{SearchDepth: 4, TT: tt})
+  result := searchFEN(t, "k7/8/2K5/8/8/8/8/7R w - - 0 1", AlphaBetaInputProvider{SearchDepth: 3, TT: tt})
+  if result.Score != MateScore-3 {
+    t.Errorf("%s scored %d plies to mate, want 3", result.Move, MatePlies(result.Score))
+  }
+}
+
+func TestMateLimit(t *testing.T) {
+  // go mate 2 stops once it has the mate instead of going on to the
+  // depth a mate in 2 needs
+  result := searchFEN(t, "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", AlphaBetaInputProvider{Limits: SearchLimits{Mate: 2}})
+  if result.Move.String() != "a1a8" || result.Score != MateScore-1 || result.Depth != 1 {
+    t.Errorf("%s at depth %d with %d plies to mate, want a1a8 at depth 1", result.Move, result.Depth, MatePlies(result.Score))
+  }
+}
+
+func TestNoisyMoves(t *testing.T) {
+  // quiescence searches the captures that do not lose material by
+  // SEE, best first. Qxd5 loses the queen for a pawn and is pruned,
+  // the even exd5 is kept.
+  board, err := chess.NewBoardFromFEN("4k3/8/2p5/3p4/4P1n1/8/8/3QK3 w - - 0 1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  var got []string
+  for _, move := range noisyMoves(board) {
+    got = append(got, move.String())
+  }
+  if strings.Join(got, " ") != "d1g4 e4d5" {
+    t.Errorf("noisy moves %v, want [d1g4 e4d5]", got)
+  }
+}
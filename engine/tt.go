@@ -0,0 +1,122 @@
+package engine
+
+import (
+  "sync/atomic"
+  chess "chess/board"
+)
+
+type Bound uint8
+
+const (
+  BoundNone Bound = iota
+  BoundExact
+  BoundLower
+  BoundUpper
+)
+
+const DefaultHashMB = 16
+
+type TTEntry struct {
+  Score int
+  Depth int
+  Bound Bound
+  Move chess.Move
+  HasMove bool
+}
+
+// A slot holds the packed entry and the hash xored with it. Both
+// halves are written with separate atomic stores, so a reader that
+// sees halves from two different writes gets a key that does not
+// match and treats the slot as empty. That keeps the table safe to
+// share between search goroutines without any locking.
+type ttSlot struct {
+  key atomic.Uint64
+  data atomic.Uint64
+}
+
+type TranspositionTable struct {
+  slots []ttSlot
+  mask uint64
+}
+
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+  // rounds the number of slots down to a power of two so the
+  // index is a mask of the hash
+  if sizeMB <= 0 {
+    sizeMB = DefaultHashMB
+  }
+  n := uint64(sizeMB) * 1024 * 1024 / 16
+  size := uint64(1)
+  for size*2 <= n {
+    size *= 2
+  }
+  return &TranspositionTable{
+    slots: make([]ttSlot, size),
+    mask: size - 1,
+  }
+}
+
+func (tt *TranspositionTable) Clear() {
+  for i := range tt.slots {
+    tt.slots[i].key.Store(0)
+    tt.slots[i].data.Store(0)
+  }
+}
+
+func (tt *TranspositionTable) Probe(hash uint64) (TTEntry, bool) {
+  slot := &tt.slots[hash&tt.mask]
+  data := slot.data.Load()
+  key := slot.key.Load()
+  if data == 0 || key^data != hash {
+    return TTEntry{}, false
+  }
+  return unpackEntry(data), true
+}
+
+func (tt *TranspositionTable) Store(hash uint64, entry TTEntry) {
+  // keeps the deeper result when the same position is already stored
+  slot := &tt.slots[hash&tt.mask]
+  oldData := slot.data.Load()
+  if oldData != 0 && slot.key.Load()^oldData == hash && unpackEntry(oldData).Depth > entry.Depth {
+    return
+  }
+  data := packEntry(entry)
+  slot.data.Store(data)
+  slot.key.Store(hash ^ data)
+}
+
+func packEntry(e TTEntry) uint64 {
+  // score  bits 0-31
+  // depth  bits 32-39
+  // bound  bits 40-41
+  // move   bits 42-60 (start, end, promotion, has move flag, drop)
+  data := uint64(uint32(int32(e.Score)))
+  data |= uint64(uint8(e.Depth)) << 32
+  data |= uint64(e.Bound&3) << 40
+  if e.HasMove {
+    data |= uint64(e.Move.Start&63) << 42
+    data |= uint64(e.Move.End&63) << 48
+    data |= uint64(e.Move.Promotion&7) << 54
+    data |= 1 << 57
+    data |= uint64(e.Move.Drop&7) << 58
+  }
+  return data
+}
+
+func unpackEntry(data uint64) TTEntry {
+  e := TTEntry{
+    Score: int(int32(uint32(data))),
+    Depth: int(uint8(data >> 32)),
+    Bound: Bound((data >> 40) & 3),
+    HasMove: (data>>57)&1 == 1,
+  }
+  if e.HasMove {
+    e.Move = chess.Move{
+      Start: chess.Square((data >> 42) & 63),
+      End: chess.Square((data >> 48) & 63),
+      Promotion: chess.Piece((data >> 54) & 7),
+      Drop: chess.Piece((data >> 58) & 7),
+    }
+  }
+  return e
+}
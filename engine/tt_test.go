@@ -0,0 +1,21 @@
+package engine
+
+import (
+  "testing"
+  chess "chess/board"
+)
+
+func TestPackEntry(t *testing.T) {
+  entries := []TTEntry{
+    {Score: 35, Depth: 4, Bound: BoundExact, Move: chess.Move{Start: 12, End: 28}, HasMove: true},
+    {Score: -1200, Depth: 9, Bound: BoundUpper, Move: chess.Move{Start: 52, End: 60, Promotion: chess.Queens}, HasMove: true},
+    {Score: 7, Depth: 2, Bound: BoundLower, Move: chess.Move{End: 45, Drop: chess.Knights}, HasMove: true},
+    {Score: 0, Depth: 1, Bound: BoundLower, Move: chess.Move{End: 8, Drop: chess.Pawns}, HasMove: true},
+    {Score: -3, Depth: 0, Bound: BoundUpper},
+  }
+  for _, e := range entries {
+    if got := unpackEntry(packEntry(e)); got != e {
+      t.Errorf("packed %+v, unpacked %+v", e, got)
+    }
+  }
+}
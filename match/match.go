@@ -0,0 +1,260 @@
+package match
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "sync"
+  chess "chess/board"
+  engine "chess/engine"
+)
+
+// A match plays two engine configurations against each other. Every
+// opening is played twice with the colors swapped so neither side
+// profits from a lopsided start, and games run on several goroutines
+// at once.
+
+type Player struct {
+  Name string
+  Depth int
+  Threads int
+  HashMB int
+  Evaluator engine.Evaluator // nil for the default evaluator
+}
+
+type Options struct {
+  Games int // games to play, rounded up to a whole number of pairs
+  Concurrency int // games played at the same time
+  Openings []*chess.Board // starting positions, the normal start if empty
+  SPRT *SPRT // stop early once the test is decided, nil to play every game
+  Log io.Writer // a line per finished game, nil for none
+}
+
+// Game is the outcome of one game, Score is from the first player's
+// point of view: 1 for a win, 0.5 for a draw and 0 for a loss.
+type Game struct {
+  Round int
+  Opening int
+  FirstIsWhite bool
+  Result chess.GameResult
+  Score float64
+}
+
+type Result struct {
+  Stats Stats
+  Games []Game
+  Decision Decision // DecisionNone unless an SPRT stopped the match
+}
+
+// ErrSPRTNeedsOpenings is returned by Run for an SPRT without
+// openings. Both engines search deterministically, so every pair of
+// games from the start position would be the same two games and the
+// test would be decided on one result repeated.
+var ErrSPRTNeedsOpenings = errors.New("an SPRT needs a set of openings")
+
+// played is a finished game, or the error that ended it
+type played struct {
+  game Game
+  err error
+}
+
+func LoadOpenings(path string) ([]*chess.Board, error) {
+  // One opening per line, either a FEN or a list of moves from the
+  // start position in long algebraic notation like "e2e4 e7e5 g1f3".
+  // Empty lines and lines starting with # are skipped.
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open openings file: %w", err)
+  }
+  defer file.Close()
+  var openings []*chess.Board
+  scanner := bufio.NewScanner(file)
+  lineNumber := 0
+  for scanner.Scan() {
+    lineNumber++
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    board, err := parseOpening(line)
+    if err != nil {
+      return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+    }
+    openings = append(openings, board)
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, fmt.Errorf("failed to read openings file: %w", err)
+  }
+  return openings, nil
+}
+
+func parseOpening(line string) (*chess.Board, error) {
+  if strings.Contains(line, "/") {
+    return chess.NewBoardFromFEN(line)
+  }
+  board := chess.NewBoard()
+  for _, field := range strings.Fields(line) {
+    move, err := chess.ParseMove(field)
+    if err != nil {
+      return nil, err
+    }
+    if !board.IsLegal(move) {
+      return nil, fmt.Errorf("illegal move %s", field)
+    }
+    board.PlayMove(move)
+  }
+  return board, nil
+}
+
+type job struct {
+  round int
+  opening int
+  firstIsWhite bool
+}
+
+func Run(first, second Player, opts Options) (Result, error) {
+  // Plays the match. A game that fails stops the match, the games
+  // already running are finished and the result so far is returned
+  // with the first error.
+  if opts.SPRT != nil && len(opts.Openings) == 0 {
+    return Result{}, ErrSPRTNeedsOpenings
+  }
+  openings := opts.Openings
+  if len(openings) == 0 {
+    openings = []*chess.Board{chess.NewBoard()}
+  }
+  games := opts.Games + opts.Games%2
+  workers := max(opts.Concurrency, 1)
+
+  jobs := make(chan job)
+  done := make(chan played)
+  stop := make(chan struct{})
+  var wg sync.WaitGroup
+  for w := 0; w < workers; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for j := range jobs {
+        game, err := playGame(first, second, openings[j.opening], j)
+        done <- played{game, err}
+      }
+    }()
+  }
+  go func() {
+    // hands out games in color swapped pairs until every game is
+    // scheduled or the match is stopped
+    defer close(jobs)
+    for round := 0; round < games; round++ {
+      j := job{round: round, opening: (round / 2) % len(openings), firstIsWhite: round%2 == 0}
+      select {
+      case jobs <- j:
+      case <-stop:
+        return
+      }
+    }
+  }()
+  go func() {
+    wg.Wait()
+    close(done)
+  }()
+
+  var result Result
+  var firstErr error
+  stopped := false
+  for p := range done {
+    game := p.game
+    if p.err != nil {
+      if firstErr == nil {
+        firstErr = fmt.Errorf("game %d: %w", game.Round+1, p.err)
+      }
+      if !stopped {
+        stopped = true
+        close(stop)
+      }
+      continue
+    }
+    result.Games = append(result.Games, game)
+    result.Stats.Add(game.Score)
+    if opts.Log != nil {
+      fmt.Fprintf(opts.Log, "game %d: %s  %s\n", game.Round+1, describe(first, second, game), result.Stats)
+    }
+    if opts.SPRT != nil && !stopped {
+      if d := opts.SPRT.Test(result.Stats); d != DecisionNone {
+        // games already running are finished and still counted
+        result.Decision = d
+        stopped = true
+        close(stop)
+      }
+    }
+  }
+  return result, firstErr
+}
+
+func playGame(first, second Player, opening *chess.Board, j job) (Game, error) {
+  white, black := first, second
+  if !j.firstIsWhite {
+    white, black = second, first
+  }
+  board := opening.Clone()
+  quiet := quietOutput{}
+  gameResult, err := chess.CoreGameplayLoop(board, chess.GameConfig{WhiteAI: true, BlackAI: true}, newSearcher(white), newSearcher(black), quiet, quiet)
+  game := Game{Round: j.round, Opening: j.opening, FirstIsWhite: j.firstIsWhite, Result: gameResult}
+  switch {
+  case err != nil:
+    return game, err
+  case gameResult.Draw:
+    game.Score = 0.5
+  case (gameResult.Winner == chess.White) == j.firstIsWhite:
+    game.Score = 1
+  default:
+    game.Score = 0
+  }
+  return game, nil
+}
+
+func describe(first, second Player, game Game) string {
+  white, black := first.Name, second.Name
+  if !game.FirstIsWhite {
+    white, black = black, white
+  }
+  result := "1/2-1/2"
+  if !game.Result.Draw {
+    result = "1-0"
+    if game.Result.Winner == chess.Black {
+      result = "0-1"
+    }
+  }
+  return fmt.Sprintf("%s vs %s %s (%s)", white, black, result, game.Result.Reason)
+}
+
+// searcher plays the engine's best move without printing anything,
+// each game gets its own so transposition tables are not shared
+type searcher struct {
+  ab engine.AlphaBetaInputProvider
+}
+
+func newSearcher(p Player) searcher {
+  hash := p.HashMB
+  if hash == 0 {
+    hash = engine.DefaultHashMB
+  }
+  return searcher{engine.AlphaBetaInputProvider{
+    SearchDepth: p.Depth,
+    Threads: p.Threads,
+    TT: engine.NewTranspositionTable(hash),
+    Evaluator: p.Evaluator,
+  }}
+}
+
+func (s searcher) GetMove(board *chess.Board) (chess.Move, error) {
+  return s.ab.Search(board).Move, nil
+}
+
+type quietOutput struct {}
+
+func (quietOutput) DisplayBoard(board *chess.Board) {}
+
+func (quietOutput) DisplayCheck() {}
@@ -0,0 +1,40 @@
+package match
+
+import (
+  "errors"
+  "testing"
+)
+
+func TestSPRTNeedsOpenings(t *testing.T) {
+  player := Player{Name: "engine", Depth: 1}
+  opts := Options{Games: 2, SPRT: &SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}}
+  result, err := Run(player, player, opts)
+  if !errors.Is(err, ErrSPRTNeedsOpenings) {
+    t.Errorf("got %v, want ErrSPRTNeedsOpenings", err)
+  }
+  if result.Stats.Games() != 0 {
+    t.Errorf("played %d games", result.Stats.Games())
+  }
+}
+
+func TestParseOpening(t *testing.T) {
+  tests := []struct {
+    line, fen string
+  }{
+    {"e2e4 e7e5 g1f3", "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"},
+    {"4k3/8/8/8/8/8/8/4K2R w K - 0 1", "4k3/8/8/8/8/8/8/4K2R w K - 0 1"},
+  }
+  for _, tt := range tests {
+    board, err := parseOpening(tt.line)
+    if err != nil {
+      t.Errorf("%s: %v", tt.line, err)
+      continue
+    }
+    if fen := board.FEN(); fen != tt.fen {
+      t.Errorf("%s: got %s, want %s", tt.line, fen, tt.fen)
+    }
+  }
+  if _, err := parseOpening("e2e4 e2e4"); err == nil {
+    t.Error("e2e4 e2e4: no error for an illegal move")
+  }
+}
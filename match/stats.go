@@ -0,0 +1,131 @@
+package match
+
+import (
+  "fmt"
+  "math"
+)
+
+// Stats counts wins, draws and losses from the first player's point
+// of view.
+type Stats struct {
+  Wins int
+  Draws int
+  Losses int
+}
+
+func (s *Stats) Add(score float64) {
+  switch score {
+  case 1:
+    s.Wins++
+  case 0:
+    s.Losses++
+  default:
+    s.Draws++
+  }
+}
+
+func (s Stats) Games() int {
+  return s.Wins + s.Draws + s.Losses
+}
+
+func (s Stats) Score() float64 {
+  // average points per game
+  if s.Games() == 0 {
+    return 0.5
+  }
+  return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+func (s Stats) variance() float64 {
+  // variance of the points scored in a single game
+  n := float64(s.Games())
+  if n == 0 {
+    return 0
+  }
+  mean := s.Score()
+  return (float64(s.Wins)*(1-mean)*(1-mean) +
+    float64(s.Draws)*(0.5-mean)*(0.5-mean) +
+    float64(s.Losses)*mean*mean) / n
+}
+
+// Elo returns the rating difference implied by the score along with
+// the margin of its 95% confidence interval.
+func (s Stats) Elo() (float64, float64) {
+  n := float64(s.Games())
+  if n == 0 {
+    return 0, 0
+  }
+  mean := s.Score()
+  margin := 1.959964 * math.Sqrt(s.variance()/n)
+  low, high := scoreToElo(mean-margin), scoreToElo(mean+margin)
+  return scoreToElo(mean), (high - low) / 2
+}
+
+func (s Stats) String() string {
+  elo, margin := s.Elo()
+  return fmt.Sprintf("+%d =%d -%d  elo %.1f +/- %.1f", s.Wins, s.Draws, s.Losses, elo, margin)
+}
+
+func scoreToElo(score float64) float64 {
+  // clamped so a clean sweep gives a large number instead of infinity
+  score = math.Min(math.Max(score, 1e-6), 1-1e-6)
+  return -400 * math.Log10(1/score-1)
+}
+
+func eloToScore(elo float64) float64 {
+  return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+type Decision int
+
+const (
+  DecisionNone Decision = iota
+  AcceptH0 // the first player is not stronger by Elo1
+  AcceptH1 // the first player is stronger by at least Elo1
+)
+
+func (d Decision) String() string {
+  switch d {
+  case AcceptH0:
+    return "H0 accepted"
+  case AcceptH1:
+    return "H1 accepted"
+  }
+  return "undecided"
+}
+
+// SPRT is a sequential probability ratio test between H0, the first
+// player is Elo0 stronger, and H1, it is Elo1 stronger. Alpha and Beta
+// are the false positive and false negative rates.
+type SPRT struct {
+  Elo0, Elo1 float64
+  Alpha, Beta float64
+}
+
+func (t *SPRT) Bounds() (float64, float64) {
+  return math.Log(t.Beta / (1 - t.Alpha)), math.Log((1 - t.Beta) / t.Alpha)
+}
+
+func (t *SPRT) LLR(s Stats) float64 {
+  // log likelihood ratio using a normal approximation of the
+  // per game score
+  variance := s.variance()
+  if s.Games() == 0 || variance == 0 {
+    return 0
+  }
+  s0, s1 := eloToScore(t.Elo0), eloToScore(t.Elo1)
+  n := float64(s.Games())
+  return n * (s1 - s0) * (2*s.Score() - s0 - s1) / (2 * variance)
+}
+
+func (t *SPRT) Test(s Stats) Decision {
+  lower, upper := t.Bounds()
+  llr := t.LLR(s)
+  if llr >= upper {
+    return AcceptH1
+  }
+  if llr <= lower {
+    return AcceptH0
+  }
+  return DecisionNone
+}
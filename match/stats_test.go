@@ -0,0 +1,71 @@
+package match
+
+import (
+  "math"
+  "testing"
+)
+
+func near(a, b float64) bool {
+  return math.Abs(a-b) < 1e-6
+}
+
+func TestStats(t *testing.T) {
+  tests := []struct {
+    stats Stats
+    score, elo, margin float64
+  }{
+    {Stats{6, 2, 2}, 0.7, 147.190714, 268.716325},
+    {Stats{10, 10, 10}, 0.5, 0, 104.557864},
+    {Stats{30, 40, 10}, 0.625, 88.739500, 54.233522},
+    {Stats{1000, 1600, 1200}, 0.473684, -18.302996, 8.410056},
+    {Stats{}, 0.5, 0, 0},
+  }
+  for _, tt := range tests {
+    if score := tt.stats.Score(); !near(score, tt.score) {
+      t.Errorf("%+v: score %f, want %f", tt.stats, score, tt.score)
+    }
+    if elo, margin := tt.stats.Elo(); !near(elo, tt.elo) || !near(margin, tt.margin) {
+      t.Errorf("%+v: elo %f +/- %f, want %f +/- %f", tt.stats, elo, margin, tt.elo, tt.margin)
+    }
+  }
+}
+
+func TestStatsAdd(t *testing.T) {
+  var s Stats
+  for _, score := range []float64{1, 0.5, 0, 1, 1} {
+    s.Add(score)
+  }
+  if s != (Stats{3, 1, 1}) || s.Games() != 5 {
+    t.Errorf("got %+v", s)
+  }
+}
+
+func TestSPRT(t *testing.T) {
+  sprt := &SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}
+  lower, upper := sprt.Bounds()
+  if !near(lower, -2.944439) || !near(upper, 2.944439) {
+    t.Errorf("bounds (%f, %f), want (-2.944439, 2.944439)", lower, upper)
+  }
+  tests := []struct {
+    stats Stats
+    llr float64
+    decision Decision
+  }{
+    {Stats{6, 2, 2}, 0.088321, DecisionNone},
+    {Stats{10, 10, 10}, -0.004659, DecisionNone},
+    {Stats{300, 400, 300}, -0.172564, DecisionNone},
+    {Stats{1200, 1600, 1000}, 4.312191, AcceptH1},
+    {Stats{1000, 1600, 1200}, -5.677903, AcceptH0},
+    // no spread in the results to measure yet
+    {Stats{10, 0, 0}, 0, DecisionNone},
+    {Stats{}, 0, DecisionNone},
+  }
+  for _, tt := range tests {
+    if llr := sprt.LLR(tt.stats); !near(llr, tt.llr) {
+      t.Errorf("%+v: llr %f, want %f", tt.stats, llr, tt.llr)
+    }
+    if d := sprt.Test(tt.stats); d != tt.decision {
+      t.Errorf("%+v: %s, want %s", tt.stats, d, tt.decision)
+    }
+  }
+}
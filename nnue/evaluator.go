@@ -0,0 +1,78 @@
+package nnue
+
+import (
+  "math/bits"
+  chess "chess/board"
+  engine "chess/engine"
+)
+
+// Evaluator runs a Network inside the search. It keeps a stack of
+// accumulators, one per ply, and only applies the pieces that changed
+// between a position and the next instead of rebuilding them.
+type Evaluator struct {
+  net *Network
+  stack []Accumulator
+  top int
+}
+
+func NewEvaluator(net *Network) *Evaluator {
+  e := &Evaluator{net: net}
+  e.stack = []Accumulator{net.newAccumulator()}
+  return e
+}
+
+func (e *Evaluator) Reset(board *chess.Board) {
+  e.top = 0
+  e.net.Refresh(e.stack[0], board)
+}
+
+func (e *Evaluator) Fork() engine.IncrementalEvaluator {
+  return NewEvaluator(e.net)
+}
+
+func (e *Evaluator) MakeMove(board, child *chess.Board, move chess.Move) {
+  // Works from the difference between the two boards rather than the
+  // move itself, so castling, en passant and promotions need no
+  // special cases.
+  if e.top+1 == len(e.stack) {
+    e.stack = append(e.stack, e.net.newAccumulator())
+  }
+  prev, next := e.stack[e.top], e.stack[e.top+1]
+  e.top++
+  for perspective := chess.White; perspective <= chess.Black; perspective++ {
+    copy(next[perspective], prev[perspective])
+  }
+  for c := chess.White; c <= chess.Black; c++ {
+    for p := chess.Pawns; p <= chess.Kings; p++ {
+      before, after := board.PieceBB[c][p], child.PieceBB[c][p]
+      removed := before &^ after
+      for removed != 0 {
+        e.net.removeFeature(next, c, p, popSquare(&removed))
+      }
+      added := after &^ before
+      for added != 0 {
+        e.net.addFeature(next, c, p, popSquare(&added))
+      }
+    }
+  }
+}
+
+func (e *Evaluator) UnmakeMove(board, child *chess.Board, move chess.Move) {
+  e.top--
+}
+
+func (e *Evaluator) Evaluate(board *chess.Board) int {
+  // the network scores for the side to move, the engine wants white's
+  // point of view
+  score := e.net.Output(e.stack[e.top], board.Turn)
+  if board.Turn == chess.Black {
+    return -score
+  }
+  return score
+}
+
+func popSquare(bb *chess.Bitboard) chess.Square {
+  sq := chess.Square(bits.TrailingZeros64(uint64(*bb)))
+  *bb &= *bb - 1
+  return sq
+}
@@ -0,0 +1,203 @@
+package nnue
+
+import (
+  "bufio"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "math/rand"
+  "os"
+  chess "chess/board"
+)
+
+// A small efficiently updatable network with 768 inputs, one per
+// color, piece and square, seen from each side's perspective.
+//
+//   768 -> Hidden (shared weights, one accumulator per perspective)
+//   clipped relu, side to move's half first
+//   2*Hidden -> 1
+//
+// Weights are int16. The hidden layer is quantised by QA and the
+// output layer, weights and bias, by QB. The output sum is then in
+// QA*QB units and is scaled to centipawns by Scale.
+
+const (
+  InputSize = 768
+  QA = 255
+  QB = 64
+  Scale = 400
+)
+
+var magic = [4]byte{'C', 'N', 'N', 'U'}
+
+const version = 1
+
+type Network struct {
+  Hidden int
+  FeatureWeights []int16 // InputSize rows of Hidden
+  FeatureBias []int16 // Hidden
+  OutputWeights []int16 // 2*Hidden, side to move's half first
+  OutputBias int32
+}
+
+func featureIndex(perspective, color chess.Color, piece chess.Piece, sq chess.Square) int {
+  // from black's perspective the board is flipped and the colors
+  // swapped so both sides share the same weights
+  if perspective == chess.Black {
+    color = color.Other()
+    sq ^= 56
+  }
+  return (int(color)*6 + int(piece-chess.Pawns))*64 + int(sq)
+}
+
+func NewNetwork(hidden int) *Network {
+  return &Network{
+    Hidden: hidden,
+    FeatureWeights: make([]int16, InputSize*hidden),
+    FeatureBias: make([]int16, hidden),
+    OutputWeights: make([]int16, 2*hidden),
+  }
+}
+
+func RandomNetwork(hidden int, seed int64) *Network {
+  // small random weights, only useful for trying out the pipeline
+  r := rand.New(rand.NewSource(seed))
+  net := NewNetwork(hidden)
+  for i := range net.FeatureWeights {
+    net.FeatureWeights[i] = int16(r.Intn(17) - 8)
+  }
+  for i := range net.OutputWeights {
+    net.OutputWeights[i] = int16(r.Intn(17) - 8)
+  }
+  return net
+}
+
+func LoadNetwork(path string) (*Network, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open network file: %w", err)
+  }
+  defer file.Close()
+  return ReadNetwork(bufio.NewReader(file))
+}
+
+func ReadNetwork(r io.Reader) (*Network, error) {
+  // file layout, little endian:
+  //   "CNNU" version:uint32 hidden:uint32
+  //   feature weights [768][hidden]int16
+  //   feature bias [hidden]int16
+  //   output weights [2*hidden]int16
+  //   output bias int32
+  var header struct {
+    Magic [4]byte
+    Version uint32
+    Hidden uint32
+  }
+  if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+    return nil, fmt.Errorf("failed to read network header: %w", err)
+  }
+  if header.Magic != magic {
+    return nil, fmt.Errorf("not a network file")
+  }
+  if header.Version != version {
+    return nil, fmt.Errorf("unsupported network version %d", header.Version)
+  }
+  if header.Hidden == 0 || header.Hidden > 4096 {
+    return nil, fmt.Errorf("invalid hidden layer size %d", header.Hidden)
+  }
+  net := NewNetwork(int(header.Hidden))
+  for _, data := range []any{net.FeatureWeights, net.FeatureBias, net.OutputWeights, &net.OutputBias} {
+    if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+      return nil, fmt.Errorf("failed to read network weights: %w", err)
+    }
+  }
+  return net, nil
+}
+
+func (net *Network) Save(path string) error {
+  file, err := os.Create(path)
+  if err != nil {
+    return fmt.Errorf("failed to create network file: %w", err)
+  }
+  defer file.Close()
+  w := bufio.NewWriter(file)
+  if err := net.Write(w); err != nil {
+    return err
+  }
+  return w.Flush()
+}
+
+func (net *Network) Write(w io.Writer) error {
+  header := []any{magic, uint32(version), uint32(net.Hidden)}
+  for _, data := range append(header, net.FeatureWeights, net.FeatureBias, net.OutputWeights, net.OutputBias) {
+    if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+      return fmt.Errorf("failed to write network: %w", err)
+    }
+  }
+  return nil
+}
+
+// Accumulator holds the hidden layer before activation from both
+// perspectives, indexed by color.
+type Accumulator [2][]int16
+
+func (net *Network) newAccumulator() Accumulator {
+  return Accumulator{make([]int16, net.Hidden), make([]int16, net.Hidden)}
+}
+
+func (net *Network) Refresh(acc Accumulator, board *chess.Board) {
+  // rebuilds acc from scratch for board
+  for perspective := chess.White; perspective <= chess.Black; perspective++ {
+    copy(acc[perspective], net.FeatureBias)
+  }
+  for c := chess.White; c <= chess.Black; c++ {
+    for p := chess.Pawns; p <= chess.Kings; p++ {
+      bb := board.PieceBB[c][p]
+      for bb != 0 {
+        sq := popSquare(&bb)
+        net.addFeature(acc, c, p, sq)
+      }
+    }
+  }
+}
+
+func (net *Network) addFeature(acc Accumulator, c chess.Color, p chess.Piece, sq chess.Square) {
+  for perspective := chess.White; perspective <= chess.Black; perspective++ {
+    row := net.FeatureWeights[featureIndex(perspective, c, p, sq)*net.Hidden:]
+    a := acc[perspective]
+    for i := range a {
+      a[i] += row[i]
+    }
+  }
+}
+
+func (net *Network) removeFeature(acc Accumulator, c chess.Color, p chess.Piece, sq chess.Square) {
+  for perspective := chess.White; perspective <= chess.Black; perspective++ {
+    row := net.FeatureWeights[featureIndex(perspective, c, p, sq)*net.Hidden:]
+    a := acc[perspective]
+    for i := range a {
+      a[i] -= row[i]
+    }
+  }
+}
+
+func (net *Network) Output(acc Accumulator, turn chess.Color) int {
+  // evaluation in centipawns from the side to move's point of view
+  var sum int64
+  us, them := acc[turn], acc[turn.Other()]
+  for i := 0; i < net.Hidden; i++ {
+    sum += crelu(us[i]) * int64(net.OutputWeights[i])
+    sum += crelu(them[i]) * int64(net.OutputWeights[net.Hidden+i])
+  }
+  return int((sum + int64(net.OutputBias)*QA) * Scale / (QA * QB))
+}
+
+func crelu(x int16) int64 {
+  if x < 0 {
+    return 0
+  }
+  if x > QA {
+    return QA
+  }
+  return int64(x)
+}
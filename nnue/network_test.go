@@ -0,0 +1,94 @@
+package nnue
+
+import (
+  "slices"
+  "testing"
+  chess "chess/board"
+)
+
+func TestOutput(t *testing.T) {
+  // one hidden neuron at activation 1.0 seen from the side to move
+  tests := []struct {
+    name string
+    weight int16 // side to move's output weight, QB is 1.0
+    bias int32 // output bias, QB is 1.0
+    want int
+  }{
+    {"weight 1.0", QB, 0, Scale},
+    {"weight -0.5", -QB / 2, 0, -Scale / 2},
+    {"bias 1.0", 0, QB, Scale},
+    {"weight and bias", QB, -QB / 4, Scale * 3 / 4},
+  }
+  board := chess.NewBoard()
+  for _, tt := range tests {
+    net := NewNetwork(1)
+    net.FeatureBias[0] = QA
+    net.OutputWeights[0] = tt.weight
+    net.OutputBias = tt.bias
+    e := NewEvaluator(net)
+    e.Reset(board)
+    if got := e.Evaluate(board); got != tt.want {
+      t.Errorf("%s: evaluated %d, want %d", tt.name, got, tt.want)
+    }
+  }
+}
+
+func TestOutputClipped(t *testing.T) {
+  // activations clip at 1.0 and below 0
+  net := NewNetwork(2)
+  net.FeatureBias[0] = 3 * QA
+  net.FeatureBias[1] = -QA
+  net.OutputWeights[0] = QB
+  net.OutputWeights[1] = QB
+  acc := net.newAccumulator()
+  net.Refresh(acc, chess.NewBoard())
+  if got := net.Output(acc, chess.White); got != Scale {
+    t.Errorf("got %d, want %d", got, Scale)
+  }
+}
+
+func TestIncrementalMatchesRefresh(t *testing.T) {
+  // castling, en passant, promotions and captures all come up within
+  // two plies of these
+  fens := []string{
+    "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+    "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+    "n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+  }
+  net := RandomNetwork(16, 1)
+  for _, fen := range fens {
+    board, err := chess.NewBoardFromFEN(fen)
+    if err != nil {
+      t.Fatal(err)
+    }
+    e := NewEvaluator(net)
+    e.Reset(board)
+    checkTree(t, e, board, 2)
+  }
+}
+
+func checkTree(t *testing.T, e *Evaluator, board *chess.Board, depth int) {
+  t.Helper()
+  for _, move := range board.GetAllLegalMoves(board.Turn) {
+    child := board.Clone()
+    child.PlayMove(move)
+    e.MakeMove(board, child, move)
+    if !sameAccumulator(e, child) {
+      t.Fatalf("%s after %s: the accumulator differs from a refresh", board.FEN(), move)
+    }
+    if depth > 1 {
+      checkTree(t, e, child, depth-1)
+    }
+    e.UnmakeMove(board, child, move)
+    if !sameAccumulator(e, board) {
+      t.Fatalf("%s after taking back %s: the accumulator differs from a refresh", board.FEN(), move)
+    }
+  }
+}
+
+func sameAccumulator(e *Evaluator, board *chess.Board) bool {
+  want := e.net.newAccumulator()
+  e.net.Refresh(want, board)
+  got := e.stack[e.top]
+  return slices.Equal(got[chess.White], want[chess.White]) && slices.Equal(got[chess.Black], want[chess.Black])
+}
@@ -0,0 +1,356 @@
+package pgn
+
+import (
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  chess "chess/board"
+  variant "chess/variant"
+)
+
+// Reading and writing games in Portable Game Notation.
+
+type Tag struct {
+  Name string
+  Value string
+}
+
+// Move is a move in the movetext along with its annotations.
+// Variations are alternatives to this move, played from the position
+// before it.
+type Move struct {
+  Move chess.Move
+  SAN string
+  NAGs []int
+  Comment string
+  Variations [][]Move
+}
+
+type Game struct {
+  Tags []Tag
+  Comment string // before the first move
+  Moves []Move
+  Result string // 1-0, 0-1, 1/2-1/2 or *
+}
+
+// the symbolic annotations and the NAG each one stands for
+var symbolNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+func (g *Game) Tag(name string) string {
+  for _, tag := range g.Tags {
+    if tag.Name == name {
+      return tag.Value
+    }
+  }
+  return ""
+}
+
+func (g *Game) SetTag(name, value string) {
+  for i := range g.Tags {
+    if g.Tags[i].Name == name {
+      g.Tags[i].Value = value
+      return
+    }
+  }
+  g.Tags = append(g.Tags, Tag{name, value})
+}
+
+func (g *Game) StartBoard() (*chess.Board, error) {
+  // the position in the FEN tag, or the normal start, with the rules
+  // named in the Variant tag
+  var rules chess.Variant
+  chess960 := false
+  switch name := strings.ToLower(g.Tag("Variant")); name {
+  case "", "standard":
+  case "chess960", "chess 960", "fischerandom", "fischer random":
+    chess960 = true
+  default:
+    v, err := variant.ByName(name)
+    if err != nil {
+      return nil, err
+    }
+    rules = v
+  }
+  board := variant.NewBoard(rules)
+  if fen := g.Tag("FEN"); fen != "" {
+    b, err := variant.NewBoardFromFEN(rules, fen)
+    if err != nil {
+      return nil, err
+    }
+    board = b
+  }
+  board.Chess960 = board.Chess960 || chess960
+  return board, nil
+}
+
+func (g *Game) Boards() ([]*chess.Board, error) {
+  // every position of the main line, the start first and the final
+  // position last
+  board, err := g.StartBoard()
+  if err != nil {
+    return nil, err
+  }
+  boards := []*chess.Board{board}
+  for _, move := range g.Moves {
+    board = board.Clone()
+    board.PlayMove(move.Move)
+    boards = append(boards, board)
+  }
+  return boards, nil
+}
+
+func NewGame(start *chess.Board, moves []chess.Move) *Game {
+  // a game played from start, with the Variant, SetUp and FEN tags
+  // needed to read it back
+  game := &Game{Result: "*"}
+  if start.Variant != nil {
+    game.SetTag("Variant", start.Variant.Name())
+  } else if start.Chess960 {
+    game.SetTag("Variant", "Chess960")
+  }
+  if fen := variant.FEN(start); fen != variant.FEN(variant.NewBoard(start.Variant)) {
+    game.SetTag("SetUp", "1")
+    game.SetTag("FEN", fen)
+  }
+  board := start.Clone()
+  for _, move := range moves {
+    game.Moves = append(game.Moves, Move{Move: move, SAN: board.SAN(move)})
+    board.PlayMove(move)
+  }
+  return game
+}
+
+func ResultString(result chess.GameResult) string {
+  switch {
+  case result.Draw:
+    return "1/2-1/2"
+  case result.Winner == chess.White:
+    return "1-0"
+  }
+  return "0-1"
+}
+
+func ReadAll(r io.Reader) ([]*Game, error) {
+  data, err := io.ReadAll(r)
+  if err != nil {
+    return nil, fmt.Errorf("failed to read PGN: %w", err)
+  }
+  return Parse(string(data))
+}
+
+func Parse(text string) ([]*Game, error) {
+  p := &parser{text: text, line: 1}
+  var games []*Game
+  for {
+    p.skipSpace()
+    if p.pos >= len(p.text) {
+      return games, nil
+    }
+    game, err := p.game()
+    if err != nil {
+      return games, fmt.Errorf("game %d, line %d: %w", len(games)+1, p.line, err)
+    }
+    games = append(games, game)
+  }
+}
+
+type parser struct {
+  text string
+  pos int
+  line int
+}
+
+func (p *parser) peek() byte {
+  if p.pos >= len(p.text) {
+    return 0
+  }
+  return p.text[p.pos]
+}
+
+func (p *parser) next() byte {
+  c := p.peek()
+  if c == '\n' {
+    p.line++
+  }
+  p.pos++
+  return c
+}
+
+func (p *parser) skipSpace() {
+  for p.pos < len(p.text) {
+    c := p.peek()
+    switch {
+    case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+      p.next()
+    case c == '%' && (p.pos == 0 || p.text[p.pos-1] == '\n'):
+      // escaped line
+      for p.pos < len(p.text) && p.peek() != '\n' {
+        p.next()
+      }
+    default:
+      return
+    }
+  }
+}
+
+func (p *parser) until(end byte) (string, error) {
+  start := p.pos
+  for p.pos < len(p.text) && p.peek() != end {
+    p.next()
+  }
+  if p.pos >= len(p.text) {
+    return "", fmt.Errorf("missing %q", end)
+  }
+  s := p.text[start:p.pos]
+  p.next()
+  return s, nil
+}
+
+func (p *parser) game() (*Game, error) {
+  game := &Game{}
+  for {
+    p.skipSpace()
+    if p.peek() != '[' {
+      break
+    }
+    p.next()
+    tag, err := p.until(']')
+    if err != nil {
+      return nil, err
+    }
+    name, value, _ := strings.Cut(strings.TrimSpace(tag), " ")
+    value = strings.TrimSpace(value)
+    if unquoted, err := strconv.Unquote(value); err == nil {
+      value = unquoted
+    } else {
+      value = strings.Trim(value, "\"")
+    }
+    game.Tags = append(game.Tags, Tag{name, value})
+  }
+  board, err := game.StartBoard()
+  if err != nil {
+    return nil, err
+  }
+  moves, result, err := p.moves(board, game, false)
+  if err != nil {
+    return nil, err
+  }
+  game.Moves = moves
+  game.Result = result
+  if game.Result == "" {
+    game.Result = game.Tag("Result")
+  }
+  return game, nil
+}
+
+func (p *parser) moves(board *chess.Board, game *Game, variation bool) ([]Move, string, error) {
+  // reads movetext up to the result, the next game's tags or, in a
+  // variation, the closing bracket
+  var moves []Move
+  var before *chess.Board // position before the last move, for variations
+  for {
+    p.skipSpace()
+    c := p.peek()
+    switch {
+    case c == 0 || (c == '[' && !variation):
+      if variation {
+        return nil, "", fmt.Errorf("unclosed variation")
+      }
+      return moves, "", nil
+    case c == ')':
+      p.next()
+      if !variation {
+        return nil, "", fmt.Errorf("unexpected )")
+      }
+      return moves, "", nil
+    case c == '(':
+      p.next()
+      if before == nil {
+        return nil, "", fmt.Errorf("variation before any move")
+      }
+      line, _, err := p.moves(before.Clone(), game, true)
+      if err != nil {
+        return nil, "", err
+      }
+      last := &moves[len(moves)-1]
+      last.Variations = append(last.Variations, line)
+    case c == '{' || c == ';':
+      p.next()
+      end := byte('}')
+      if c == ';' {
+        end = '\n'
+      }
+      comment, err := p.until(end)
+      if err != nil && c == '{' {
+        return nil, "", err
+      }
+      comment = strings.Join(strings.Fields(comment), " ")
+      switch {
+      case len(moves) > 0:
+        moves[len(moves)-1].Comment = joinComment(moves[len(moves)-1].Comment, comment)
+      case !variation:
+        game.Comment = joinComment(game.Comment, comment)
+      }
+    case c == '$':
+      p.next()
+      nag, err := strconv.Atoi(p.token())
+      if err != nil || len(moves) == 0 {
+        return nil, "", fmt.Errorf("invalid NAG")
+      }
+      moves[len(moves)-1].NAGs = append(moves[len(moves)-1].NAGs, nag)
+    default:
+      token := p.token()
+      switch {
+      case token == "1-0" || token == "0-1" || token == "1/2-1/2" || token == "*":
+        if variation {
+          continue
+        }
+        return moves, token, nil
+      case token == "":
+        return nil, "", fmt.Errorf("unexpected %q", p.next())
+      case token[0] >= '0' && token[0] <= '9' && strings.Trim(token, "0123456789.") == "":
+        continue // move number
+      }
+      // a move number can be stuck to the move, as in 1.e4
+      if i := strings.LastIndex(token, "."); i >= 0 {
+        token = token[i+1:]
+      }
+      san, annotation := splitAnnotation(token)
+      move, err := board.ParseSAN(san)
+      if err != nil {
+        return nil, "", err
+      }
+      m := Move{Move: move, SAN: board.SAN(move)}
+      if nag, ok := symbolNAGs[annotation]; ok {
+        m.NAGs = append(m.NAGs, nag)
+      }
+      moves = append(moves, m)
+      before = board.Clone()
+      board.PlayMove(move)
+    }
+  }
+}
+
+func (p *parser) token() string {
+  start := p.pos
+  for p.pos < len(p.text) && !strings.ContainsRune(" \t\r\n(){};[]$", rune(p.peek())) {
+    p.next()
+  }
+  return p.text[start:p.pos]
+}
+
+func splitAnnotation(token string) (string, string) {
+  i := strings.IndexAny(token, "!?")
+  if i < 0 {
+    return token, ""
+  }
+  return token[:i], token[i:]
+}
+
+func joinComment(a, b string) string {
+  if a == "" {
+    return b
+  }
+  return a + " " + b
+}
@@ -0,0 +1,118 @@
+package pgn
+
+import (
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  chess "chess/board"
+)
+
+// the tags every PGN game should have, written first and in this order
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+func (g *Game) String() string {
+  var sb strings.Builder
+  g.Write(&sb)
+  return sb.String()
+}
+
+func (g *Game) Write(w io.Writer) error {
+  result := g.Result
+  if result == "" {
+    result = "*"
+  }
+  written := map[string]bool{}
+  for _, name := range sevenTagRoster {
+    value := g.Tag(name)
+    if name == "Result" {
+      value = result
+    } else if value == "" {
+      value = "?"
+    }
+    if _, err := fmt.Fprintf(w, "[%s %s]\n", name, strconv.Quote(value)); err != nil {
+      return err
+    }
+    written[name] = true
+  }
+  for _, tag := range g.Tags {
+    if written[tag.Name] {
+      continue
+    }
+    if _, err := fmt.Fprintf(w, "[%s %s]\n", tag.Name, strconv.Quote(tag.Value)); err != nil {
+      return err
+    }
+  }
+
+  board, err := g.StartBoard()
+  if err != nil {
+    return err
+  }
+  var tokens []string
+  if g.Comment != "" {
+    tokens = append(tokens, "{"+g.Comment+"}")
+  }
+  tokens = appendMoves(tokens, board, g.Moves)
+  tokens = append(tokens, result)
+  _, err = fmt.Fprintf(w, "\n%s\n\n", wrap(tokens, 79))
+  return err
+}
+
+func appendMoves(tokens []string, board *chess.Board, moves []Move) []string {
+  // the move number goes before every white move and before a black
+  // move that starts a line or follows a comment or variation
+  number := int(board.TotalMoves)/2 + 1
+  turn := board.Turn
+  needNumber := true
+  for _, move := range moves {
+    if turn == chess.White {
+      tokens = append(tokens, fmt.Sprintf("%d.", number))
+    } else if needNumber {
+      tokens = append(tokens, fmt.Sprintf("%d...", number))
+    }
+    needNumber = false
+    tokens = append(tokens, move.SAN)
+    for _, nag := range move.NAGs {
+      tokens = append(tokens, "$"+strconv.Itoa(nag))
+    }
+    if move.Comment != "" {
+      tokens = append(tokens, "{"+move.Comment+"}")
+      needNumber = true
+    }
+    for _, variation := range move.Variations {
+      tokens = append(tokens, "(")
+      tokens = appendMoves(tokens, board, variation)
+      tokens = append(tokens, ")")
+      needNumber = true
+    }
+    board = board.Clone()
+    board.PlayMove(move.Move)
+    if turn == chess.Black {
+      number++
+    }
+    turn = turn.Other()
+  }
+  return tokens
+}
+
+func wrap(tokens []string, width int) string {
+  // joins tokens with spaces, without a space inside brackets, and
+  // breaks lines before they get longer than width
+  var sb strings.Builder
+  lineLength := 0
+  for i, token := range tokens {
+    space := i > 0 && tokens[i-1] != "(" && token != ")"
+    if space && lineLength+1+len(token) > width {
+      sb.WriteByte('\n')
+      lineLength = 0
+      space = false
+    }
+    if space {
+      sb.WriteByte(' ')
+      lineLength++
+    }
+    sb.WriteString(token)
+    lineLength += len(token)
+  }
+  return sb.String()
+}
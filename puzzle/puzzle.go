@@ -0,0 +1,269 @@
+package puzzle
+
+import (
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "io"
+  "strings"
+  "sync"
+  chess "chess/board"
+  engine "chess/engine"
+  pgn "chess/pgn"
+)
+
+// Tactics puzzles taken from played games. A puzzle starts right
+// after a move that threw the game away: the other side now has a
+// winning move, and only one. The solution follows the engine's line
+// for as long as every one of the solver's moves stays forcing and
+// the only one that keeps the win.
+
+type Options struct {
+  Depth int // engine depth for every position
+  MinSwing int // centipawns the opponent's mistake has to have cost
+  Winning int // score the solver has to reach with the best move
+  Margin int // how much worse the second best move has to be
+  MaxMoves int // solver moves in the longest solution
+  Concurrency int // games analysed at the same time
+}
+
+func DefaultOptions() Options {
+  return Options{Depth: 4, MinSwing: 200, Winning: 250, Margin: 200, MaxMoves: 4, Concurrency: 1}
+}
+
+type Puzzle struct {
+  FEN string
+  Moves []chess.Move // the solver moves first, replies in between
+  SAN []string
+  Themes []string
+  Score int // engine score after the first move, from the solver's side
+  Source string
+}
+
+type analysis struct {
+  score int // white's point of view
+  lines []engine.PVLine
+}
+
+type finder struct {
+  opts Options
+  ab engine.AlphaBetaInputProvider
+  cache map[uint64]analysis
+}
+
+func relative(score int, color chess.Color) int {
+  if color == chess.Black {
+    return -score
+  }
+  return score
+}
+
+func FromGames(games []*pgn.Game, opts Options) ([][]Puzzle, error) {
+  // the puzzles of each game, in the same order as games. Games that
+  // cannot be replayed give no puzzles; the error joins one error for
+  // each of them, numbered from 1 as in the file.
+  found := make([][]Puzzle, len(games))
+  errs := make([]error, len(games))
+  jobs := make(chan int)
+  var wg sync.WaitGroup
+  for w := 0; w < max(opts.Concurrency, 1); w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range jobs {
+        var err error
+        found[i], err = FromGame(games[i], opts)
+        if err != nil {
+          errs[i] = fmt.Errorf("game %d: %w", i+1, err)
+        }
+      }
+    }()
+  }
+  for i := range games {
+    jobs <- i
+  }
+  close(jobs)
+  wg.Wait()
+  return found, errors.Join(errs...)
+}
+
+func FromGame(game *pgn.Game, opts Options) ([]Puzzle, error) {
+  boards, err := game.Boards()
+  if err != nil {
+    return nil, err
+  }
+  f := &finder{
+    opts: opts,
+    ab: engine.AlphaBetaInputProvider{SearchDepth: opts.Depth, MultiPV: 2, TT: engine.NewTranspositionTable(engine.DefaultHashMB)},
+    cache: map[uint64]analysis{},
+  }
+  var puzzles []Puzzle
+  for ply := 1; ply < len(boards); ply++ {
+    board := boards[ply]
+    if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+      break
+    }
+    solver := board.Turn
+    before := relative(f.analyse(boards[ply-1]).score, solver)
+    now := f.analyse(board)
+    if relative(now.score, solver) < opts.Winning || relative(now.score, solver)-before < opts.MinSwing || !f.unique(now, solver) {
+      continue
+    }
+    puzzle := f.solve(board, now)
+    puzzle.Source = fmt.Sprintf("%s - %s, %s", game.Tag("White"), game.Tag("Black"), moveLabel(board))
+    puzzles = append(puzzles, puzzle)
+    // the positions inside the solution are not puzzles of their own
+    ply += len(puzzle.Moves)
+  }
+  return puzzles, nil
+}
+
+func (f *finder) analyse(board *chess.Board) analysis {
+  hash := board.GetZobristHash()
+  if a, ok := f.cache[hash]; ok {
+    return a
+  }
+  result := f.ab.Search(board)
+  a := analysis{result.Score, result.Lines}
+  f.cache[hash] = a
+  return a
+}
+
+func (f *finder) unique(a analysis, solver chess.Color) bool {
+  // the best move wins and the second best is well behind and short
+  // of winning. A single legal move is no puzzle.
+  if len(a.lines) < 2 {
+    return false
+  }
+  best := relative(a.lines[0].Score, solver)
+  second := relative(a.lines[1].Score, solver)
+  return best >= f.opts.Winning && second < f.opts.Winning && best-second >= f.opts.Margin
+}
+
+func forcing(board *chess.Board, move chess.Move) bool {
+  if board.IsCapture(move) || move.Promotion != chess.Empty {
+    return true
+  }
+  after := board.Clone()
+  after.PlayMove(move)
+  return after.IsCheck(after.Turn)
+}
+
+func (f *finder) solve(board *chess.Board, a analysis) Puzzle {
+  solver := board.Turn
+  puzzle := Puzzle{FEN: board.FEN(), Score: relative(a.score, solver)}
+  b := board
+  move := a.lines[0].Move
+  for {
+    puzzle.Moves = append(puzzle.Moves, move)
+    puzzle.SAN = append(puzzle.SAN, b.SAN(move))
+    b = b.Clone()
+    b.PlayMove(move)
+    if len(b.GetAllLegalMoves(b.Turn)) == 0 || (len(puzzle.Moves)+1)/2 >= f.opts.MaxMoves {
+      break
+    }
+    reply := f.analyse(b).lines[0].Move
+    next := b.Clone()
+    next.PlayMove(reply)
+    if len(next.GetAllLegalMoves(next.Turn)) == 0 {
+      break
+    }
+    na := f.analyse(next)
+    if !f.unique(na, solver) || !forcing(next, na.lines[0].Move) {
+      break
+    }
+    puzzle.Moves = append(puzzle.Moves, reply)
+    puzzle.SAN = append(puzzle.SAN, b.SAN(reply))
+    b = next
+    move = na.lines[0].Move
+  }
+  puzzle.Themes = themes(board, puzzle, b)
+  return puzzle
+}
+
+func themes(start *chess.Board, puzzle Puzzle, end *chess.Board) []string {
+  var tags []string
+  solverMoves := (len(puzzle.Moves) + 1) / 2
+  if end.IsCheckmate() {
+    tags = append(tags, "mate", fmt.Sprintf("mateIn%d", solverMoves))
+  } else if puzzle.Score >= 600 {
+    tags = append(tags, "crushing")
+  } else {
+    tags = append(tags, "advantage")
+  }
+  switch solverMoves {
+  case 1:
+    tags = append(tags, "oneMove")
+  case 2:
+    tags = append(tags, "short")
+  default:
+    tags = append(tags, "long")
+  }
+
+  first := puzzle.Moves[0]
+  if start.SEE(first) < 0 {
+    tags = append(tags, "sacrifice")
+  }
+  for i := 0; i < len(puzzle.Moves); i += 2 {
+    if puzzle.Moves[i].Promotion != chess.Empty {
+      tags = append(tags, "promotion")
+      break
+    }
+  }
+  if fork(start, first) {
+    tags = append(tags, "fork")
+  }
+
+  switch phase := chess.GamePhase(start); {
+  case start.TotalMoves < 20:
+    tags = append(tags, "opening")
+  case phase <= 6:
+    tags = append(tags, "endgame")
+  default:
+    tags = append(tags, "middlegame")
+  }
+  return tags
+}
+
+func fork(board *chess.Board, move chess.Move) bool {
+  // the moved piece attacks two or more enemy pieces other than pawns
+  after := board.Clone()
+  after.PlayMove(move)
+  mover := board.Turn
+  from := chess.Bitboard(1) << move.End
+  targets := 0
+  for p := chess.Knights; p <= chess.Kings; p++ {
+    pieces := after.PieceBB[mover.Other()][p]
+    for sq := chess.Square(0); sq < 64; sq++ {
+      if pieces.GetBit(sq) && after.AttackersTo(sq, after.FullBB)&from != 0 {
+        targets++
+      }
+    }
+  }
+  return targets >= 2
+}
+
+func moveLabel(board *chess.Board) string {
+  number := int(board.TotalMoves)/2 + 1
+  if board.Turn == chess.White {
+    return fmt.Sprintf("%d.", number)
+  }
+  return fmt.Sprintf("%d...", number)
+}
+
+func WriteCSV(w io.Writer, puzzles []Puzzle) error {
+  // FEN, moves in long algebraic, moves in SAN, themes, source
+  cw := csv.NewWriter(w)
+  for _, p := range puzzles {
+    moves := make([]string, len(p.Moves))
+    for i, move := range p.Moves {
+      moves[i] = move.String()
+    }
+    record := []string{p.FEN, strings.Join(moves, " "), strings.Join(p.SAN, " "), strings.Join(p.Themes, " "), p.Source}
+    if err := cw.Write(record); err != nil {
+      return err
+    }
+  }
+  cw.Flush()
+  return cw.Error()
+}
@@ -0,0 +1,107 @@
+package puzzle
+
+import (
+  "bytes"
+  "encoding/csv"
+  "slices"
+  "strings"
+  "testing"
+  pgn "chess/pgn"
+)
+
+// 3...Nf6?? lets white mate on f7
+const scholarsMate = `[White "A"]
+[Black "B"]
+[Result "1-0"]
+
+1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0
+`
+
+func testOptions() Options {
+  opts := DefaultOptions()
+  opts.Depth = 2
+  return opts
+}
+
+func parse(t *testing.T, text string) []*pgn.Game {
+  t.Helper()
+  games, err := pgn.Parse(text)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return games
+}
+
+func TestFromGame(t *testing.T) {
+  puzzles, err := FromGame(parse(t, scholarsMate)[0], testOptions())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(puzzles) != 1 {
+    t.Fatalf("got %d puzzles, want 1", len(puzzles))
+  }
+  p := puzzles[0]
+  if want := "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4"; p.FEN != want {
+    t.Errorf("FEN %q, want %q", p.FEN, want)
+  }
+  if !slices.Equal(p.SAN, []string{"Qxf7#"}) {
+    t.Errorf("solution %v, want [Qxf7#]", p.SAN)
+  }
+  for _, theme := range []string{"mate", "mateIn1", "oneMove", "opening"} {
+    if !slices.Contains(p.Themes, theme) {
+      t.Errorf("themes %v, missing %s", p.Themes, theme)
+    }
+  }
+  if want := "A - B, 4."; p.Source != want {
+    t.Errorf("source %q, want %q", p.Source, want)
+  }
+}
+
+func TestFromGameNoMistake(t *testing.T) {
+  // nothing is thrown away before the game stops
+  games := parse(t, "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 *\n")
+  puzzles, err := FromGame(games[0], testOptions())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(puzzles) != 0 {
+    t.Errorf("got %d puzzles, want none: %v", len(puzzles), puzzles)
+  }
+}
+
+func TestFromGamesReportsErrors(t *testing.T) {
+  broken := &pgn.Game{Result: "*"}
+  broken.SetTag("Variant", "no such variant")
+  games := append(parse(t, scholarsMate), broken, parse(t, scholarsMate)[0])
+  opts := testOptions()
+  opts.Concurrency = 2
+  found, err := FromGames(games, opts)
+  if err == nil || !strings.HasPrefix(err.Error(), "game 2: ") {
+    t.Errorf("got error %v, want one for game 2", err)
+  }
+  if len(found) != 3 || len(found[0]) != 1 || len(found[1]) != 0 || len(found[2]) != 1 {
+    t.Errorf("got %v, want the puzzles of games 1 and 3 only", found)
+  }
+}
+
+func TestWriteCSV(t *testing.T) {
+  puzzles, err := FromGame(parse(t, scholarsMate)[0], testOptions())
+  if err != nil {
+    t.Fatal(err)
+  }
+  var buf bytes.Buffer
+  if err := WriteCSV(&buf, puzzles); err != nil {
+    t.Fatal(err)
+  }
+  records, err := csv.NewReader(&buf).ReadAll()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(records) != 1 {
+    t.Fatalf("got %d records, want 1", len(records))
+  }
+  want := []string{puzzles[0].FEN, "h5f7", "Qxf7#", strings.Join(puzzles[0].Themes, " "), "A - B, 4."}
+  if !slices.Equal(records[0], want) {
+    t.Errorf("got %q, want %q", records[0], want)
+  }
+}
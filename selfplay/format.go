@@ -0,0 +1,196 @@
+package selfplay
+
+import (
+  "bufio"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "math"
+  "math/bits"
+  "strconv"
+  "strings"
+  chess "chess/board"
+)
+
+// TextWriter writes one record per line as
+//
+//   <fen> | <score> | <1.0, 0.5 or 0.0>
+//
+// which the tuner can read directly.
+type TextWriter struct {
+  w *bufio.Writer
+}
+
+func NewTextWriter(w io.Writer) *TextWriter {
+  return &TextWriter{bufio.NewWriter(w)}
+}
+
+func (t *TextWriter) Write(rec Record) error {
+  _, err := fmt.Fprintf(t.w, "%s | %d | %.1f\n", rec.FEN, rec.Score, rec.Result)
+  return err
+}
+
+func (t *TextWriter) Flush() error {
+  return t.w.Flush()
+}
+
+// The binary format stores every record in RecordSize bytes, little
+// endian:
+//
+//   0   occupied squares, uint64
+//   8   a nibble per occupied square from a1 up, low nibble first:
+//       piece | color<<3
+//   24  flags: bit 0 black to move, bits 1-4 castling KQkq
+//   25  en passant square, 64 for none
+//   26  half move clock
+//   27  result for white: 0 loss, 1 draw, 2 win
+//   28  score, int16 clamped
+//   30  full move number, uint16
+const RecordSize = 32
+
+type BinaryWriter struct {
+  w *bufio.Writer
+}
+
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+  return &BinaryWriter{bufio.NewWriter(w)}
+}
+
+func (b *BinaryWriter) Write(rec Record) error {
+  data, err := EncodeRecord(rec)
+  if err != nil {
+    return err
+  }
+  _, err = b.w.Write(data[:])
+  return err
+}
+
+func (b *BinaryWriter) Flush() error {
+  return b.w.Flush()
+}
+
+func EncodeRecord(rec Record) ([RecordSize]byte, error) {
+  var data [RecordSize]byte
+  board, err := chess.NewBoardFromFEN(rec.FEN)
+  if err != nil {
+    return data, err
+  }
+  binary.LittleEndian.PutUint64(data[0:], uint64(board.FullBB))
+  occupied := board.FullBB
+  for i := 0; occupied != 0; i++ {
+    sq := chess.Square(bits.TrailingZeros64(uint64(occupied)))
+    occupied &= occupied - 1
+    color := chess.White
+    piece := board.GetPieceAt(sq, chess.White)
+    if piece == chess.Empty {
+      color = chess.Black
+      piece = board.GetPieceAt(sq, chess.Black)
+    }
+    data[8+i/2] |= byte(int(piece)|int(color)<<3) << (4 * (i % 2))
+  }
+
+  // the rest is read back out of the FEN so the two formats agree
+  fields := strings.Fields(rec.FEN)
+  for len(fields) < 6 {
+    fields = append(fields, "-")
+  }
+  if fields[1] == "b" {
+    data[24] |= 1
+  }
+  for i, c := range "KQkq" {
+    if strings.ContainsRune(fields[2], c) {
+      data[24] |= 2 << i
+    }
+  }
+  data[25] = 64
+  if board.EnPassantSquare != nil {
+    data[25] = byte(*board.EnPassantSquare)
+  }
+  halfMoves, _ := strconv.Atoi(fields[4])
+  fullMoves, _ := strconv.Atoi(fields[5])
+  data[26] = byte(min(halfMoves, 255))
+  data[27] = byte(rec.Result * 2)
+  score := min(max(rec.Score, math.MinInt16), math.MaxInt16)
+  binary.LittleEndian.PutUint16(data[28:], uint16(int16(score)))
+  binary.LittleEndian.PutUint16(data[30:], uint16(max(fullMoves, 1)))
+  return data, nil
+}
+
+func DecodeRecord(data [RecordSize]byte) Record {
+  var pieces [64]string
+  occupied := chess.Bitboard(binary.LittleEndian.Uint64(data[0:]))
+  for i := 0; occupied != 0; i++ {
+    sq := bits.TrailingZeros64(uint64(occupied))
+    occupied &= occupied - 1
+    nibble := data[8+i/2] >> (4 * (i % 2)) & 0xf
+    symbol := chess.PieceToChar(chess.Piece(nibble&7), chess.Color(nibble>>3))
+    if nibble>>3 == byte(chess.White) {
+      symbol = strings.ToUpper(symbol)
+    }
+    pieces[sq] = symbol
+  }
+
+  var sb strings.Builder
+  for rank := 7; rank >= 0; rank-- {
+    empty := 0
+    for file := 0; file < 8; file++ {
+      if pieces[rank*8+file] == "" {
+        empty++
+        continue
+      }
+      if empty > 0 {
+        sb.WriteString(strconv.Itoa(empty))
+        empty = 0
+      }
+      sb.WriteString(pieces[rank*8+file])
+    }
+    if empty > 0 {
+      sb.WriteString(strconv.Itoa(empty))
+    }
+    if rank > 0 {
+      sb.WriteByte('/')
+    }
+  }
+  if data[24]&1 != 0 {
+    sb.WriteString(" b ")
+  } else {
+    sb.WriteString(" w ")
+  }
+  castling := ""
+  for i, c := range "KQkq" {
+    if data[24]&(2<<i) != 0 {
+      castling += string(c)
+    }
+  }
+  if castling == "" {
+    castling = "-"
+  }
+  sb.WriteString(castling)
+  if data[25] < 64 {
+    sb.WriteString(" " + chess.IndexToNotation(chess.Square(data[25])))
+  } else {
+    sb.WriteString(" -")
+  }
+  fmt.Fprintf(&sb, " %d %d", data[26], binary.LittleEndian.Uint16(data[30:]))
+
+  return Record{
+    FEN: sb.String(),
+    Score: int(int16(binary.LittleEndian.Uint16(data[28:]))),
+    Result: float64(data[27]) / 2,
+  }
+}
+
+func ReadBinary(r io.Reader) ([]Record, error) {
+  var records []Record
+  br := bufio.NewReader(r)
+  for {
+    var data [RecordSize]byte
+    if _, err := io.ReadFull(br, data[:]); err != nil {
+      if err == io.EOF {
+        return records, nil
+      }
+      return records, fmt.Errorf("failed to read record %d: %w", len(records)+1, err)
+    }
+    records = append(records, DecodeRecord(data))
+  }
+}
@@ -0,0 +1,189 @@
+package selfplay
+
+import (
+  "math/rand"
+  "sync"
+  chess "chess/board"
+  engine "chess/engine"
+)
+
+// Self-play games for tuning and training. Each game starts with a few
+// random moves so games differ, then the engine plays both sides
+// through CoreGameplayLoop. Every quiet position it searches becomes a
+// Record, labelled with the result once the game is over.
+
+type Options struct {
+  Games int
+  Concurrency int // games played at the same time
+  Depth int
+  Evaluator engine.Evaluator // nil for the default evaluator
+  RandomPlies int // random half moves at the start of each game
+  Seed int64
+
+  // Adjudication, each rule is off when its plies are 0.
+  MaxPlies int // a draw once the game is this long
+  ResignScore int // a side resigns when its own score stays at or below -ResignScore
+  ResignPlies int // for this many of its moves in a row
+  DrawScore int // a draw when the score stays within DrawScore of 0
+  DrawPlies int // for this many half moves in a row
+  DrawAfter int // but not before this half move
+}
+
+// Record is a searched position. Score is the search score in
+// centipawns from white's point of view and Result the outcome of the
+// game for white: 1, 0.5 or 0.
+type Record struct {
+  FEN string
+  Score int
+  Result float64
+}
+
+type RecordWriter interface {
+  Write(rec Record) error
+}
+
+func Generate(w RecordWriter, opts Options) (int, error) {
+  // Plays opts.Games games and writes their records to w, returning
+  // how many were written. Game i always uses the seed Seed+i so a run
+  // can be repeated regardless of how games are scheduled. The first
+  // write error stops the run, games still being played are left to
+  // finish on their own and thrown away.
+  workers := max(opts.Concurrency, 1)
+  games := make(chan int)
+  done := make(chan []Record)
+  stop := make(chan struct{})
+  var wg sync.WaitGroup
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for game := range games {
+        records := PlayGame(opts, opts.Seed+int64(game))
+        select {
+        case done <- records:
+        case <-stop:
+          return
+        }
+      }
+    }()
+  }
+  go func() {
+    defer func() {
+      close(games)
+      wg.Wait()
+      close(done)
+    }()
+    for game := 0; game < opts.Games; game++ {
+      select {
+      case games <- game:
+      case <-stop:
+        return
+      }
+    }
+  }()
+
+  written := 0
+  for records := range done {
+    for _, rec := range records {
+      if err := w.Write(rec); err != nil {
+        close(stop)
+        return written, err
+      }
+      written++
+    }
+  }
+  return written, nil
+}
+
+func PlayGame(opts Options, seed int64) []Record {
+  p := &player{
+    opts: opts,
+    rand: rand.New(rand.NewSource(seed)),
+    ab: engine.AlphaBetaInputProvider{
+      SearchDepth: opts.Depth,
+      TT: engine.NewTranspositionTable(engine.DefaultHashMB),
+      Evaluator: opts.Evaluator,
+    },
+  }
+  out := quietOutput{}
+  result, err := chess.CoreGameplayLoop(chess.NewBoard(), chess.GameConfig{WhiteAI: true, BlackAI: true}, p, p, out, out)
+  score := 0.5
+  if err == nil && !result.Draw {
+    score = 1
+    if result.Winner == chess.Black {
+      score = 0
+    }
+  }
+  for i := range p.records {
+    p.records[i].Result = score
+  }
+  return p.records
+}
+
+// player searches for both sides of one game and keeps what it needs
+// to adjudicate
+type player struct {
+  opts Options
+  rand *rand.Rand
+  ab engine.AlphaBetaInputProvider
+  ply int
+  records []Record
+  losing [2]int
+  drawn int
+}
+
+func (p *player) GetMove(board *chess.Board) (chess.Move, error) {
+  ply := p.ply
+  p.ply++
+  if p.opts.MaxPlies > 0 && ply >= p.opts.MaxPlies {
+    return chess.Move{}, chess.ErrDraw
+  }
+  if ply < p.opts.RandomPlies {
+    moves := board.GetAllLegalMoves(board.Turn)
+    return moves[p.rand.Intn(len(moves))], nil
+  }
+
+  result := p.ab.Search(board)
+  score := result.Score
+  mate := engine.IsMate(score)
+  // positions in check or with a capture to make are not quiet and
+  // make poor training targets
+  if !mate && !board.IsCheck(board.Turn) && !board.IsCapture(result.Move) {
+    p.records = append(p.records, Record{FEN: board.FEN(), Score: score})
+  }
+  return result.Move, p.adjudicate(board.Turn, score, ply)
+}
+
+func (p *player) adjudicate(turn chess.Color, score, ply int) error {
+  own := score
+  if turn == chess.Black {
+    own = -own
+  }
+  if p.opts.ResignPlies > 0 {
+    if own <= -p.opts.ResignScore {
+      p.losing[turn]++
+    } else {
+      p.losing[turn] = 0
+    }
+    if p.losing[turn] >= p.opts.ResignPlies {
+      return chess.ErrResign
+    }
+  }
+  if p.opts.DrawPlies > 0 && ply >= p.opts.DrawAfter {
+    if own >= -p.opts.DrawScore && own <= p.opts.DrawScore {
+      p.drawn++
+    } else {
+      p.drawn = 0
+    }
+    if p.drawn >= p.opts.DrawPlies {
+      return chess.ErrDraw
+    }
+  }
+  return nil
+}
+
+type quietOutput struct {}
+
+func (quietOutput) DisplayBoard(board *chess.Board) {}
+
+func (quietOutput) DisplayCheck() {}
@@ -0,0 +1,30 @@
+package selfplay
+
+import (
+  "errors"
+  "testing"
+  "time"
+)
+
+type failingWriter struct {
+  writes int
+}
+
+func (w *failingWriter) Write(rec Record) error {
+  w.writes++
+  return errors.New("disk full")
+}
+
+func TestGenerateStopsOnWriteError(t *testing.T) {
+  w := &failingWriter{}
+  opts := Options{Games: 10000, Concurrency: 2, Depth: 1, RandomPlies: 4, MaxPlies: 12}
+  start := time.Now()
+  written, err := Generate(w, opts)
+  if err == nil || written != 0 || w.writes != 1 {
+    t.Errorf("got %d written, %d writes, error %v, want the first write's error", written, w.writes, err)
+  }
+  // ten thousand games would take minutes
+  if took := time.Since(start); took > 10*time.Second {
+    t.Errorf("Generate went on for %v after the write failed", took)
+  }
+}
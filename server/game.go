@@ -0,0 +1,387 @@
+package server
+
+import (
+  "errors"
+  "fmt"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  pgn "chess/pgn"
+  variant "chess/variant"
+)
+
+// Every game runs CoreGameplayLoop in its own goroutine. Human sides
+// are NetworkInputs whose moves arrive through the API or a WebSocket,
+// engine sides search with the game's engine settings. The game is
+// the loop's OutputHandler, keeps the state the API reports and passes
+// every change on to the NetworkOutputs watching it.
+
+const (
+  Human = "human"
+  Engine = "engine"
+)
+
+// errors a move request can fail with, on top of a move that does
+// not parse or is illegal
+var (
+  errGameOver = errors.New("the game is over")
+  errNotYourTurn = errors.New("it is not a human player's turn")
+  errSeated = errors.New("that side is played over a WebSocket")
+)
+
+type moveRequest struct {
+  text string
+  ply int // moves played when the request was made, to catch stale ones
+  reply chan error
+}
+
+type game struct {
+  id string
+  players [2]string
+  settings EngineSettings
+  timeControl time.Duration // on each clock at the start, 0 for none
+  increment time.Duration
+  created time.Time
+
+  mu sync.Mutex
+  start *chess.Board
+  board *chess.Board // the position last shown by the loop
+  moves []chess.Move
+  sans []string
+  clocks [2]time.Duration // left at the start of the current turn
+  turnStart time.Time
+  played *chess.Move // returned by a player, not yet on the board
+  pending chan error // answers the move request in flight
+  result *chess.GameResult
+  stopped bool
+  changed chan struct{} // closed and replaced on every change
+  outputs map[*NetworkOutput]bool
+  seats [2]*NetworkOutput // WebSocket players of the human sides
+
+  requests chan moveRequest
+  stop chan struct{}
+  stopOnce sync.Once
+  search *atomic.Bool // stops the engine's search in progress
+  done chan struct{}
+}
+
+func newGame(id string, board *chess.Board, players [2]string, settings EngineSettings, timeControl, increment time.Duration) *game {
+  return &game{
+    id: id,
+    players: players,
+    settings: settings,
+    timeControl: timeControl,
+    increment: increment,
+    created: time.Now(),
+    start: board.Clone(),
+    board: board.Clone(),
+    clocks: [2]time.Duration{timeControl, timeControl},
+    turnStart: time.Now(),
+    changed: make(chan struct{}),
+    outputs: map[*NetworkOutput]bool{},
+    requests: make(chan moveRequest),
+    stop: make(chan struct{}),
+    done: make(chan struct{}),
+  }
+}
+
+func (g *game) run(board *chess.Board) {
+  // plays the game out, the caller starts this in a goroutine
+  defer close(g.done)
+  var players [2]chess.InputProvider
+  for color, kind := range g.players {
+    if kind == Human {
+      players[color] = &NetworkInput{g}
+    } else {
+      players[color] = &enginePlayer{g, g.settings.provider()}
+    }
+  }
+  result, err := chess.CoreGameplayLoop(board, chess.GameConfig{}, players[chess.White], players[chess.Black], g, g)
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  // a game drawn by repetition ends before the loop shows the board
+  g.update(board)
+  g.charge(time.Now())
+  if err != nil {
+    g.stopped = true
+  }
+  g.result = &result
+  g.answer(nil)
+  g.notify()
+}
+
+func (g *game) DisplayBoard(board *chess.Board) {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  g.update(board)
+  g.answer(nil)
+  g.notify()
+}
+
+func (g *game) DisplayCheck() {}
+
+func (g *game) update(board *chess.Board) {
+  // adds the move a player made to the history once the loop has
+  // played it on board
+  if g.played == nil || board.TotalMoves == g.board.TotalMoves {
+    return
+  }
+  g.charge(time.Now())
+  if g.timeControl > 0 {
+    g.clocks[g.board.Turn] += g.increment
+  }
+  g.moves = append(g.moves, *g.played)
+  g.sans = append(g.sans, g.board.SAN(*g.played))
+  g.played = nil
+  g.board = board.Clone()
+}
+
+func (g *game) charge(now time.Time) {
+  // counts the time since the turn started against the side to move
+  if g.timeControl > 0 && g.running() {
+    g.clocks[g.board.Turn] -= now.Sub(g.turnStart)
+  }
+  g.turnStart = now
+}
+
+func (g *game) running() bool {
+  return g.result == nil && !g.stopped
+}
+
+func (g *game) clock(color chess.Color) time.Duration {
+  // the clock as it stands now, running for the side to move
+  t := g.clocks[color]
+  if color == g.board.Turn && g.running() {
+    t -= time.Since(g.turnStart)
+  }
+  return max(t, 0)
+}
+
+func (g *game) answer(err error) {
+  if g.pending != nil {
+    g.pending <- err
+    g.pending = nil
+  }
+}
+
+func (g *game) notify() {
+  // wakes everything waiting on the game and shows the change to
+  // every output
+  close(g.changed)
+  g.changed = make(chan struct{})
+  for out := range g.outputs {
+    out.DisplayBoard(g.board)
+  }
+}
+
+func (g *game) Stop() {
+  // ends the game without a result, a search in progress included
+  g.stopOnce.Do(func() {
+    g.mu.Lock()
+    if g.result == nil {
+      g.stopped = true
+    }
+    if g.search != nil {
+      g.search.Store(true)
+    }
+    close(g.stop)
+    g.mu.Unlock()
+  })
+}
+
+func (g *game) submit(text string, from *NetworkOutput) error {
+  // Hands a move to the human player to move and waits until the loop
+  // has played it. from is the WebSocket player sending it, nil for
+  // the HTTP API. The error is errGameOver, errNotYourTurn, errSeated
+  // or why the move was rejected.
+  g.mu.Lock()
+  turn := g.board.Turn
+  switch {
+  case !g.running():
+    g.mu.Unlock()
+    return errGameOver
+  case g.players[turn] != Human || (from != nil && g.seats[turn] != from):
+    g.mu.Unlock()
+    return errNotYourTurn
+  case g.seats[turn] != from:
+    g.mu.Unlock()
+    return errSeated
+  }
+  req := moveRequest{text, len(g.moves), make(chan error, 1)}
+  g.mu.Unlock()
+  select {
+  case g.requests <- req:
+  case <-g.done:
+    return errGameOver
+  }
+  select {
+  case err := <-req.reply:
+    return err
+  case <-g.done:
+    return errGameOver
+  }
+}
+
+func parseMove(board *chess.Board, text string) (chess.Move, error) {
+  // SAN, or long algebraic like e2e4 and e7e8q
+  text = strings.TrimSpace(text)
+  if move, err := board.ParseSAN(text); err == nil {
+    return move, nil
+  }
+  move, err := chess.ParseMove(text)
+  if err != nil || !board.IsLegal(move) {
+    return chess.Move{}, fmt.Errorf("illegal or unreadable move %q", text)
+  }
+  return move, nil
+}
+
+type enginePlayer struct {
+  g *game
+  provider engine.AlphaBetaInputProvider
+}
+
+func (p *enginePlayer) GetMove(board *chess.Board) (chess.Move, error) {
+  // each search gets its own stop flag, the search sets it when done
+  g := p.g
+  provider := p.provider
+  provider.Stop = new(atomic.Bool)
+  g.mu.Lock()
+  if g.stopped {
+    g.mu.Unlock()
+    return chess.Move{}, chess.ErrResign
+  }
+  g.search = provider.Stop
+  if g.timeControl > 0 {
+    // a share of what is left on the clock
+    budget := max(g.clock(board.Turn)/30+g.increment*3/4, 50*time.Millisecond)
+    if provider.Limits.Time == 0 || budget < provider.Limits.Time {
+      provider.Limits.Time = budget
+    }
+  }
+  g.mu.Unlock()
+  result := provider.Search(board)
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  g.search = nil
+  switch {
+  case g.stopped:
+    return chess.Move{}, chess.ErrResign
+  case g.timeControl > 0 && g.clock(board.Turn) == 0:
+    return chess.Move{}, chess.ErrTimeout
+  }
+  g.played = &result.Move
+  return result.Move, nil
+}
+
+// State is what the API reports about a game.
+type State struct {
+  ID string `json:"id"`
+  Status string `json:"status"` // active, over or stopped
+  White string `json:"white"`
+  Black string `json:"black"`
+  Variant string `json:"variant"`
+  FEN string `json:"fen"`
+  Turn string `json:"turn"`
+  Waiting string `json:"waiting_for,omitempty"` // human or engine, while active
+  Check bool `json:"check"`
+  Moves []string `json:"moves"` // SAN
+  UCIMoves []string `json:"uci_moves"`
+  LegalMoves []string `json:"legal_moves"` // SAN, while a human is to move
+  Clock *Clock `json:"clock,omitempty"` // with a time control
+  Result string `json:"result,omitempty"` // 1-0, 0-1 or 1/2-1/2
+  Reason string `json:"reason,omitempty"`
+  Created time.Time `json:"created"`
+}
+
+// Clock is the time left on both clocks.
+type Clock struct {
+  White int64 `json:"white_ms"`
+  Black int64 `json:"black_ms"`
+  Running string `json:"running,omitempty"` // the side whose clock runs
+}
+
+func (g *game) state() State {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.describe()
+}
+
+func (g *game) describe() State {
+  // the state with the lock held
+  board := g.board
+  s := State{
+    ID: g.id,
+    Status: "active",
+    White: g.players[chess.White],
+    Black: g.players[chess.Black],
+    Variant: variantName(board),
+    FEN: variant.FEN(board),
+    Turn: colorName(board.Turn),
+    Check: board.IsCheck(board.Turn),
+    Moves: append([]string{}, g.sans...),
+    UCIMoves: make([]string, len(g.moves)),
+    LegalMoves: []string{},
+    Clock: g.clockState(),
+    Created: g.created,
+  }
+  for i, move := range g.moves {
+    s.UCIMoves[i] = move.String()
+  }
+  switch {
+  case g.stopped:
+    s.Status = "stopped"
+  case g.result != nil:
+    s.Status = "over"
+    s.Result = pgn.ResultString(*g.result)
+    s.Reason = g.result.Reason
+  default:
+    s.Waiting = g.players[board.Turn]
+    if s.Waiting == Human {
+      for _, move := range board.GetAllLegalMoves(board.Turn) {
+        s.LegalMoves = append(s.LegalMoves, board.SAN(move))
+      }
+    }
+  }
+  return s
+}
+
+func (g *game) clockState() *Clock {
+  // nil without a time control
+  if g.timeControl == 0 {
+    return nil
+  }
+  c := &Clock{
+    White: g.clock(chess.White).Milliseconds(),
+    Black: g.clock(chess.Black).Milliseconds(),
+  }
+  if g.running() {
+    c.Running = colorName(g.board.Turn)
+  }
+  return c
+}
+
+func (g *game) snapshot() *chess.Board {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  return g.board.Clone()
+}
+
+func colorName(color chess.Color) string {
+  if color == chess.White {
+    return "white"
+  }
+  return "black"
+}
+
+func variantName(board *chess.Board) string {
+  switch {
+  case board.Variant != nil:
+    return board.Variant.Name()
+  case board.Chess960:
+    return "chess960"
+  }
+  return "chess"
+}
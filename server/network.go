@@ -0,0 +1,286 @@
+package server
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "sync"
+  "time"
+  chess "chess/board"
+  variant "chess/variant"
+)
+
+// The network side of a game. A NetworkInput plays a human side with
+// the moves that clients send, a NetworkOutput streams the game to one
+// WebSocket client as events:
+//
+//   {"type": "state", "state": {...}}     on joining, the full State
+//   {"type": "move", "ply": 1, "san": "e4", "uci": "e2e4", ...}
+//   {"type": "clock", "clock": {...}}     every second while a clock runs
+//   {"type": "result", "status": "over", "result": "1-0", "reason": "..."}
+//   {"type": "error", "error": "..."}     a message that was refused
+//
+// Players send {"type": "move", "move": "e4"}. The connection closes
+// after the result.
+
+const (
+  eventBuffer = 64 // events queued for a client before it is dropped
+  clockInterval = time.Second
+)
+
+// NetworkInput is the InputProvider of a human side. Its moves come
+// from the HTTP API or the side's WebSocket player.
+type NetworkInput struct {
+  g *game
+}
+
+func (p *NetworkInput) GetMove(board *chess.Board) (chess.Move, error) {
+  g := p.g
+  var flag <-chan time.Time
+  g.mu.Lock()
+  if g.timeControl > 0 {
+    timer := time.NewTimer(g.clock(board.Turn))
+    defer timer.Stop()
+    flag = timer.C
+  }
+  g.mu.Unlock()
+  for {
+    select {
+    case <-g.stop:
+      return chess.Move{}, chess.ErrResign
+    case <-flag:
+      return chess.Move{}, chess.ErrTimeout
+    case req := <-g.requests:
+      g.mu.Lock()
+      stale := req.ply != len(g.moves)
+      g.mu.Unlock()
+      if stale {
+        req.reply <- errNotYourTurn
+        continue
+      }
+      move, err := parseMove(board, req.text)
+      if err != nil {
+        req.reply <- err
+        continue
+      }
+      g.mu.Lock()
+      g.played = &move
+      g.pending = req.reply
+      g.mu.Unlock()
+      return move, nil
+    }
+  }
+}
+
+type event struct {
+  Type string `json:"type"`
+  State *State `json:"state,omitempty"`
+  Ply int `json:"ply,omitempty"` // moves played, counting both sides
+  SAN string `json:"san,omitempty"`
+  UCI string `json:"uci,omitempty"`
+  FEN string `json:"fen,omitempty"` // after the move
+  Turn string `json:"turn,omitempty"`
+  Check bool `json:"check,omitempty"`
+  Clock *Clock `json:"clock,omitempty"`
+  Status string `json:"status,omitempty"`
+  Result string `json:"result,omitempty"`
+  Reason string `json:"reason,omitempty"`
+  Error string `json:"error,omitempty"`
+}
+
+// NetworkOutput is the OutputHandler of one WebSocket client, a player
+// or a spectator. The game shows it every change with its lock held.
+type NetworkOutput struct {
+  g *game
+  conn *wsConn
+  seat *chess.Color // nil for a spectator
+  sent int // moves sent so far
+  finished bool // the result has been sent
+  events chan event
+  closed chan struct{}
+  closeOnce sync.Once
+}
+
+func (o *NetworkOutput) DisplayBoard(board *chess.Board) {
+  g := o.g
+  for ; o.sent < len(g.moves); o.sent++ {
+    e := event{Type: "move", Ply: o.sent + 1, SAN: g.sans[o.sent], UCI: g.moves[o.sent].String()}
+    if o.sent == len(g.moves)-1 {
+      e.FEN = variant.FEN(board)
+      e.Turn = colorName(board.Turn)
+      e.Check = board.IsCheck(board.Turn)
+      e.Clock = g.clockState()
+    }
+    o.send(e)
+  }
+  if !g.running() && !o.finished {
+    o.finished = true
+    s := g.describe()
+    o.send(event{Type: "result", Status: s.Status, Result: s.Result, Reason: s.Reason, Clock: s.Clock})
+  }
+}
+
+func (o *NetworkOutput) DisplayCheck() {}
+
+func (o *NetworkOutput) send(e event) {
+  // queues an event, a client too slow to keep up is dropped
+  select {
+  case o.events <- e:
+  case <-o.closed:
+  default:
+    o.close()
+  }
+}
+
+func (o *NetworkOutput) close() {
+  o.closeOnce.Do(func() {
+    close(o.closed)
+    o.conn.conn.Close()
+  })
+}
+
+func (o *NetworkOutput) write() {
+  // sends the queued events and the clock, until the result is out
+  ticker := time.NewTicker(clockInterval)
+  defer ticker.Stop()
+  for {
+    var e event
+    select {
+    case <-o.closed:
+      return
+    case e = <-o.events:
+    case <-ticker.C:
+      o.g.mu.Lock()
+      clock := o.g.clockState()
+      o.g.mu.Unlock()
+      if clock == nil || clock.Running == "" {
+        continue
+      }
+      e = event{Type: "clock", Clock: clock}
+    }
+    data, _ := json.Marshal(e)
+    if err := o.conn.writeText(data); err != nil {
+      o.close()
+      return
+    }
+    if e.Type == "result" {
+      // the read side finishes the closing handshake
+      o.conn.close(closeNormal, "game over")
+      return
+    }
+  }
+}
+
+func (o *NetworkOutput) read() {
+  // plays the moves a player sends until the connection ends
+  for {
+    data, err := o.conn.readMessage()
+    if err != nil {
+      return
+    }
+    var msg struct {
+      Type string `json:"type"`
+      Move string `json:"move"`
+    }
+    if err := json.Unmarshal(data, &msg); err != nil {
+      o.send(event{Type: "error", Error: "bad message: " + err.Error()})
+      continue
+    }
+    switch {
+    case msg.Type != "move":
+      err = fmt.Errorf("unknown message type %q", msg.Type)
+    case o.seat == nil:
+      err = fmt.Errorf("spectators can not move")
+    default:
+      err = o.g.submit(msg.Move, o)
+    }
+    if err != nil {
+      o.send(event{Type: "error", Error: err.Error()})
+    }
+  }
+}
+
+func (g *game) attach(o *NetworkOutput) error {
+  // takes the output's seat and starts showing it the game
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  if o.seat != nil {
+    if err := g.seatFree(*o.seat); err != nil {
+      return err
+    }
+    g.seats[*o.seat] = o
+  }
+  g.outputs[o] = true
+  state := g.describe()
+  o.send(event{Type: "state", State: &state})
+  o.sent = len(g.moves)
+  o.DisplayBoard(g.board)
+  return nil
+}
+
+func (g *game) detach(o *NetworkOutput) {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  delete(g.outputs, o)
+  if o.seat != nil && g.seats[*o.seat] == o {
+    g.seats[*o.seat] = nil
+  }
+}
+
+func (g *game) seatFree(color chess.Color) error {
+  // with the lock held
+  switch {
+  case g.players[color] != Human:
+    return fmt.Errorf("%s is played by the engine", colorName(color))
+  case g.seats[color] != nil:
+    return fmt.Errorf("%s already has a player", colorName(color))
+  }
+  return nil
+}
+
+func (s *Server) join(w http.ResponseWriter, r *http.Request) {
+  // GET /games/{id}/ws?as=white, black or spectator, the default
+  g := s.lookup(w, r)
+  if g == nil {
+    return
+  }
+  var seat *chess.Color
+  switch as := r.URL.Query().Get("as"); as {
+  case "", "spectator":
+  case "white", "black":
+    color := chess.White
+    if as == "black" {
+      color = chess.Black
+    }
+    seat = &color
+    g.mu.Lock()
+    err := g.seatFree(color)
+    g.mu.Unlock()
+    if err != nil {
+      writeError(w, http.StatusConflict, err)
+      return
+    }
+  default:
+    writeError(w, http.StatusBadRequest, fmt.Errorf("join as white, black or spectator, not %q", as))
+    return
+  }
+  conn, err := upgrade(w, r)
+  if err != nil {
+    return
+  }
+  o := &NetworkOutput{g: g, conn: conn, seat: seat, events: make(chan event, eventBuffer), closed: make(chan struct{})}
+  if err := g.attach(o); err != nil {
+    // someone else took the seat since the check
+    conn.close(closePolicy, err.Error())
+    conn.conn.Close()
+    return
+  }
+  s.streams.Add(1)
+  go func() {
+    defer s.streams.Done()
+    o.write()
+  }()
+  o.read()
+  g.detach(o)
+  o.close()
+}
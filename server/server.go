@@ -0,0 +1,409 @@
+package server
+
+import (
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "sort"
+  "sync"
+  "sync/atomic"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  variant "chess/variant"
+)
+
+// A local HTTP API for playing games against the engine. Everything
+// is JSON, errors come back as {"error": "..."}.
+//
+//   POST   /games                create a game
+//   GET    /games                list the games
+//   GET    /games/{id}           the state of a game
+//   POST   /games/{id}/moves     play a move for the human to move
+//   POST   /games/{id}/analysis  search the current position
+//   POST   /games/{id}/stop      end a game without a result
+//   DELETE /games/{id}           stop a game and forget it
+//   GET    /games/{id}/ws        follow or play a game over a WebSocket
+
+// limits on what a request can ask the engine for
+const (
+  maxDepth = 30
+  maxMoveTime = time.Minute
+  maxThreads = 8
+  maxMultiPV = 10
+  maxBody = 1 << 16
+)
+
+// EngineSettings configure the engine of a game. Zero fields take the
+// server's defaults.
+type EngineSettings struct {
+  Depth int `json:"depth"`
+  MoveTime int `json:"movetime_ms"` // searched as deep as time allows
+  Threads int `json:"threads"`
+}
+
+func (s EngineSettings) withDefaults(defaults EngineSettings) EngineSettings {
+  if s.Depth == 0 && s.MoveTime == 0 {
+    s.Depth = defaults.Depth
+    s.MoveTime = defaults.MoveTime
+  }
+  if s.Threads == 0 {
+    s.Threads = defaults.Threads
+  }
+  return s
+}
+
+func (s EngineSettings) check() error {
+  switch {
+  case s.Depth < 0 || s.Depth > maxDepth:
+    return fmt.Errorf("depth must be from 1 to %d", maxDepth)
+  case s.MoveTime < 0 || time.Duration(s.MoveTime)*time.Millisecond > maxMoveTime:
+    return fmt.Errorf("movetime_ms must be at most %d", maxMoveTime.Milliseconds())
+  case s.Threads < 0 || s.Threads > maxThreads:
+    return fmt.Errorf("threads must be from 1 to %d", maxThreads)
+  }
+  return nil
+}
+
+func (s EngineSettings) provider() engine.AlphaBetaInputProvider {
+  ab := engine.AlphaBetaInputProvider{
+    SearchDepth: s.Depth,
+    Threads: max(s.Threads, 1),
+    TT: engine.NewTranspositionTable(engine.DefaultHashMB),
+  }
+  if s.MoveTime > 0 {
+    ab.Limits.Time = time.Duration(s.MoveTime) * time.Millisecond
+    ab.SearchDepth = 0
+  }
+  return ab
+}
+
+type Server struct {
+  Engine EngineSettings // defaults for new games
+  MaxGames int // games kept at once, 0 for no limit
+
+  mu sync.Mutex
+  games map[string]*game
+  mux *http.ServeMux
+  streams sync.WaitGroup // WebSocket writers still sending
+}
+
+func New(defaults EngineSettings) *Server {
+  s := &Server{Engine: defaults, games: map[string]*game{}, mux: http.NewServeMux()}
+  s.mux.HandleFunc("POST /games", s.createGame)
+  s.mux.HandleFunc("GET /games", s.listGames)
+  s.mux.HandleFunc("GET /games/{id}", s.getGame)
+  s.mux.HandleFunc("POST /games/{id}/moves", s.playMove)
+  s.mux.HandleFunc("POST /games/{id}/analysis", s.analyse)
+  s.mux.HandleFunc("POST /games/{id}/stop", s.stopGame)
+  s.mux.HandleFunc("DELETE /games/{id}", s.deleteGame)
+  s.mux.HandleFunc("GET /games/{id}/ws", s.join)
+  return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) Close() {
+  // stops every game and waits for their loops to finish and the
+  // results to reach the WebSocket clients
+  s.mu.Lock()
+  games := make([]*game, 0, len(s.games))
+  for _, g := range s.games {
+    games = append(games, g)
+  }
+  s.mu.Unlock()
+  for _, g := range games {
+    g.Stop()
+    <-g.done
+  }
+  s.streams.Wait()
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *game {
+  // the game named in the path, or nil once a 404 has been written
+  s.mu.Lock()
+  g := s.games[r.PathValue("id")]
+  s.mu.Unlock()
+  if g == nil {
+    writeError(w, http.StatusNotFound, fmt.Errorf("no game %q", r.PathValue("id")))
+  }
+  return g
+}
+
+type createRequest struct {
+  FEN string `json:"fen"`
+  Variant string `json:"variant"`
+  Chess960 *int `json:"chess960"`
+  White string `json:"white"` // human or engine, human by default
+  Black string `json:"black"` // engine by default
+  Engine EngineSettings `json:"engine"`
+  Time int `json:"time_ms"` // on each clock, 0 plays without one
+  Increment int `json:"increment_ms"`
+}
+
+func (req createRequest) board() (*chess.Board, error) {
+  name := req.Variant
+  if name == "" {
+    name = "chess"
+  }
+  rules, err := variant.ByName(name)
+  if err != nil {
+    return nil, err
+  }
+  switch {
+  case req.Chess960 != nil:
+    if req.FEN != "" || rules.Name() != "chess" {
+      return nil, fmt.Errorf("chess960 can not be combined with a FEN or another variant")
+    }
+    return chess.NewChess960Board(*req.Chess960)
+  case req.FEN != "":
+    return variant.NewBoardFromFEN(rules, req.FEN)
+  }
+  return variant.NewBoard(rules), nil
+}
+
+func (s *Server) createGame(w http.ResponseWriter, r *http.Request) {
+  var req createRequest
+  if !readJSON(w, r, &req) {
+    return
+  }
+  players := [2]string{Human, Engine}
+  for color, kind := range [2]string{req.White, req.Black} {
+    switch kind {
+    case "":
+    case Human, Engine:
+      players[color] = kind
+    default:
+      writeError(w, http.StatusBadRequest, fmt.Errorf("players are human or engine, not %q", kind))
+      return
+    }
+  }
+  settings := req.Engine.withDefaults(s.Engine)
+  if err := settings.check(); err != nil {
+    writeError(w, http.StatusBadRequest, err)
+    return
+  }
+  if req.Time < 0 || req.Increment < 0 || (req.Time == 0 && req.Increment > 0) {
+    writeError(w, http.StatusBadRequest, errors.New("time_ms must be positive for increment_ms to count"))
+    return
+  }
+  board, err := req.board()
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err)
+    return
+  }
+  if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+    writeError(w, http.StatusBadRequest, errors.New("the position has no legal moves"))
+    return
+  }
+
+  s.mu.Lock()
+  if s.MaxGames > 0 && len(s.games) >= s.MaxGames {
+    s.mu.Unlock()
+    writeError(w, http.StatusServiceUnavailable, fmt.Errorf("already %d games, delete one first", len(s.games)))
+    return
+  }
+  id := newID()
+  g := newGame(id, board, players, settings, time.Duration(req.Time)*time.Millisecond, time.Duration(req.Increment)*time.Millisecond)
+  s.games[id] = g
+  s.mu.Unlock()
+  go g.run(board)
+  writeJSON(w, http.StatusCreated, g.state())
+}
+
+func (s *Server) listGames(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  games := make([]*game, 0, len(s.games))
+  for _, g := range s.games {
+    games = append(games, g)
+  }
+  s.mu.Unlock()
+  sort.Slice(games, func(i, j int) bool {
+    return games[i].created.Before(games[j].created)
+  })
+  states := make([]State, len(games))
+  for i, g := range games {
+    states[i] = g.state()
+  }
+  writeJSON(w, http.StatusOK, states)
+}
+
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
+  if g := s.lookup(w, r); g != nil {
+    writeJSON(w, http.StatusOK, g.state())
+  }
+}
+
+func (s *Server) playMove(w http.ResponseWriter, r *http.Request) {
+  // answers once the move is on the board, the engine's reply may
+  // still be coming
+  g := s.lookup(w, r)
+  if g == nil {
+    return
+  }
+  var req struct {
+    Move string `json:"move"` // SAN or long algebraic
+  }
+  if !readJSON(w, r, &req) {
+    return
+  }
+  switch err := g.submit(req.Move, nil); {
+  case errors.Is(err, errGameOver) || errors.Is(err, errNotYourTurn) || errors.Is(err, errSeated):
+    writeError(w, http.StatusConflict, err)
+  case err != nil:
+    writeError(w, http.StatusBadRequest, err)
+  default:
+    writeJSON(w, http.StatusOK, g.state())
+  }
+}
+
+type analysisLine struct {
+  Move string `json:"move"`
+  SAN string `json:"san"`
+  Score int `json:"score_cp"` // from white's side, 0 with a mate
+  Mate int `json:"mate,omitempty"` // moves to mate, negative when black mates
+  PV []string `json:"pv"` // SAN
+}
+
+type analysis struct {
+  FEN string `json:"fen"`
+  Depth int `json:"depth"`
+  Nodes uint64 `json:"nodes"`
+  Lines []analysisLine `json:"lines"`
+}
+
+func (s *Server) analyse(w http.ResponseWriter, r *http.Request) {
+  // searches the position the game is at, the game goes on meanwhile
+  g := s.lookup(w, r)
+  if g == nil {
+    return
+  }
+  var req struct {
+    EngineSettings
+    Nodes uint64 `json:"nodes"`
+    MultiPV int `json:"multipv"`
+  }
+  if !readJSON(w, r, &req) {
+    return
+  }
+  settings := req.EngineSettings
+  if settings.Depth == 0 && settings.MoveTime == 0 && req.Nodes == 0 {
+    settings = settings.withDefaults(s.Engine)
+  } else {
+    settings = settings.withDefaults(EngineSettings{Threads: s.Engine.Threads})
+  }
+  if err := settings.check(); err != nil {
+    writeError(w, http.StatusBadRequest, err)
+    return
+  }
+  if req.MultiPV < 0 || req.MultiPV > maxMultiPV {
+    writeError(w, http.StatusBadRequest, fmt.Errorf("multipv must be from 1 to %d", maxMultiPV))
+    return
+  }
+  board := g.snapshot()
+  if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+    writeError(w, http.StatusConflict, errGameOver)
+    return
+  }
+  ab := settings.provider()
+  ab.MultiPV = max(req.MultiPV, 1)
+  ab.Limits.Nodes = req.Nodes
+  if req.Nodes > 0 && settings.Depth == 0 {
+    ab.SearchDepth = 0
+  }
+  // a client that goes away stops the search
+  ab.Stop = new(atomic.Bool)
+  done := make(chan struct{})
+  go func() {
+    select {
+    case <-r.Context().Done():
+      ab.Stop.Store(true)
+    case <-done:
+    }
+  }()
+  result := ab.Search(board)
+  close(done)
+
+  out := analysis{FEN: variant.FEN(board), Depth: result.Depth, Nodes: result.Nodes, Lines: []analysisLine{}}
+  for _, line := range result.Lines {
+    out.Lines = append(out.Lines, newAnalysisLine(board, line))
+  }
+  writeJSON(w, http.StatusOK, out)
+}
+
+func newAnalysisLine(board *chess.Board, line engine.PVLine) analysisLine {
+  out := analysisLine{Move: line.Move.String(), SAN: board.SAN(line.Move), Score: line.Score, PV: []string{}}
+  if engine.IsMate(line.Score) {
+    out.Score = 0
+    out.Mate = (engine.MatePlies(line.Score) + 1) / 2
+    if line.Score < 0 {
+      out.Mate = -out.Mate
+    }
+  }
+  b := board.Clone()
+  for _, move := range line.PV {
+    if !b.IsLegal(move) {
+      break
+    }
+    out.PV = append(out.PV, b.SAN(move))
+    b.PlayMove(move)
+  }
+  return out
+}
+
+func (s *Server) stopGame(w http.ResponseWriter, r *http.Request) {
+  g := s.lookup(w, r)
+  if g == nil {
+    return
+  }
+  g.Stop()
+  <-g.done
+  writeJSON(w, http.StatusOK, g.state())
+}
+
+func (s *Server) deleteGame(w http.ResponseWriter, r *http.Request) {
+  g := s.lookup(w, r)
+  if g == nil {
+    return
+  }
+  g.Stop()
+  <-g.done
+  s.mu.Lock()
+  delete(s.games, g.id)
+  s.mu.Unlock()
+  w.WriteHeader(http.StatusNoContent)
+}
+
+func newID() string {
+  var b [8]byte
+  rand.Read(b[:])
+  return hex.EncodeToString(b[:])
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+  // decodes the request body into v, an empty body leaves v as it is
+  r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+  dec := json.NewDecoder(r.Body)
+  dec.DisallowUnknownFields()
+  if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+    writeError(w, http.StatusBadRequest, fmt.Errorf("bad request body: %w", err))
+    return false
+  }
+  return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+  writeJSON(w, status, map[string]string{"error": err.Error()})
+}
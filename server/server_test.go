@@ -0,0 +1,337 @@
+package server
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+// the engine searches one ply unless a test asks for more, so games
+// move along quickly
+var testEngine = EngineSettings{Depth: 1, Threads: 1}
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+  t.Helper()
+  s := New(testEngine)
+  ts := httptest.NewServer(s)
+  t.Cleanup(func() {
+    ts.Close()
+    s.Close()
+  })
+  return s, ts
+}
+
+func call(t *testing.T, ts *httptest.Server, method, path string, body any, wantStatus int, out any) {
+  // sends body as JSON and decodes the answer into out, failing the
+  // test on any other status than wantStatus
+  t.Helper()
+  var buf bytes.Buffer
+  switch b := body.(type) {
+  case nil:
+  case string:
+    buf.WriteString(b)
+  default:
+    json.NewEncoder(&buf).Encode(b)
+  }
+  req, err := http.NewRequest(method, ts.URL+path, &buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  resp, err := ts.Client().Do(req)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != wantStatus {
+    var e map[string]string
+    json.NewDecoder(resp.Body).Decode(&e)
+    t.Fatalf("%s %s: status %d (%s), want %d", method, path, resp.StatusCode, e["error"], wantStatus)
+  }
+  if out != nil {
+    if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+      t.Fatalf("%s %s: %v", method, path, err)
+    }
+  }
+}
+
+func create(t *testing.T, ts *httptest.Server, body any) State {
+  t.Helper()
+  var s State
+  call(t, ts, "POST", "/games", body, http.StatusCreated, &s)
+  return s
+}
+
+func waitFor(t *testing.T, ts *httptest.Server, id string, what string, ready func(State) bool) State {
+  // polls the game until ready says so
+  t.Helper()
+  deadline := time.Now().Add(10 * time.Second)
+  for {
+    var s State
+    call(t, ts, "GET", "/games/"+id, nil, http.StatusOK, &s)
+    if ready(s) {
+      return s
+    }
+    if time.Now().After(deadline) {
+      t.Fatalf("game %s: gave up waiting for %s", id, what)
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+}
+
+func waitForHuman(t *testing.T, ts *httptest.Server, id string) State {
+  // until a human is to move or the game has ended
+  t.Helper()
+  return waitFor(t, ts, id, "a human to move", func(s State) bool {
+    return s.Waiting == Human || s.Status != "active"
+  })
+}
+
+func waitForEnd(t *testing.T, ts *httptest.Server, id string) State {
+  t.Helper()
+  return waitFor(t, ts, id, "the result", func(s State) bool {
+    return s.Status != "active"
+  })
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestCreateGame(t *testing.T) {
+  _, ts := newTestServer(t)
+  tests := []struct {
+    name    string
+    body    string
+    variant string
+    fen     string
+  }{
+    {"default", `{}`, "chess", startFEN},
+    {"empty body", ``, "chess", startFEN},
+    {"fen", `{"fen": "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"}`, "chess", "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"},
+    {"chess960", `{"chess960": 518}`, "chess960", startFEN},
+    {"variant", `{"variant": "koth"}`, "kingofthehill", startFEN},
+  }
+  for _, tt := range tests {
+    s := create(t, ts, tt.body)
+    if s.Status != "active" || s.Variant != tt.variant || s.FEN != tt.fen {
+      t.Errorf("%s: got %s %s %q, want active %s %q", tt.name, s.Status, s.Variant, s.FEN, tt.variant, tt.fen)
+    }
+    if s.White != Human || s.Black != Engine || s.Waiting != Human || len(s.LegalMoves) == 0 {
+      t.Errorf("%s: white %s, black %s, waiting for %s with %d moves", tt.name, s.White, s.Black, s.Waiting, len(s.LegalMoves))
+    }
+  }
+
+  s := create(t, ts, `{"chess960": 0}`)
+  if s.Variant != "chess960" || !strings.HasPrefix(s.FEN, "bbqnnrkr/") {
+    t.Errorf("chess960 position 0: got %s %q", s.Variant, s.FEN)
+  }
+}
+
+func TestCreateGameBadInput(t *testing.T) {
+  _, ts := newTestServer(t)
+  for _, body := range []string{
+    `{"fen": "not a fen"}`,
+    `{"variant": "bughouse"}`,
+    `{"chess960": 960}`,
+    `{"chess960": 1, "fen": "4k3/8/8/8/8/8/8/4K3 w - - 0 1"}`,
+    `{"chess960": 1, "variant": "atomic"}`,
+    `{"white": "robot"}`,
+    `{"engine": {"depth": 99}}`,
+    `{"engine": {"threads": 100}}`,
+    `{"time_ms": -1}`,
+    `{"increment_ms": 1000}`,
+    `{"colour": "white"}`,
+    `{"fen": "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"}`,
+    `{`,
+  } {
+    call(t, ts, "POST", "/games", body, http.StatusBadRequest, nil)
+  }
+}
+
+func TestPlayMoves(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, nil)
+  var s State
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "e4"}`, http.StatusOK, &s)
+  if len(s.Moves) == 0 || s.Moves[0] != "e4" || s.UCIMoves[0] != "e2e4" {
+    t.Fatalf("after e4 the moves are %v", s.Moves)
+  }
+  s = waitForHuman(t, ts, g.ID)
+  if len(s.Moves) != 2 || s.Turn != "white" {
+    t.Fatalf("the engine did not reply: %v, %s to move", s.Moves, s.Turn)
+  }
+  // long algebraic works too
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "d2d4"}`, http.StatusOK, &s)
+  if s.UCIMoves[2] != "d2d4" {
+    t.Errorf("after d2d4 the moves are %v", s.UCIMoves)
+  }
+
+  h := create(t, ts, `{"black": "human"}`)
+  for _, move := range []string{`{"move": "e5"}`, `{"move": "Ke2"}`, `{"move": "e2e5"}`, `{"move": "zz"}`, `{"mvoe": "e4"}`} {
+    call(t, ts, "POST", "/games/"+h.ID+"/moves", move, http.StatusBadRequest, nil)
+  }
+  call(t, ts, "GET", "/games/"+h.ID, nil, http.StatusOK, &s)
+  if len(s.Moves) != 0 {
+    t.Errorf("rejected moves were played: %v", s.Moves)
+  }
+  call(t, ts, "POST", "/games/nope/moves", `{"move": "e4"}`, http.StatusNotFound, nil)
+}
+
+func TestNotYourTurn(t *testing.T) {
+  _, ts := newTestServer(t)
+  // the engine plays white and takes its time
+  g := create(t, ts, `{"white": "engine", "black": "human", "engine": {"movetime_ms": 60000}}`)
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "e4"}`, http.StatusConflict, nil)
+  call(t, ts, "DELETE", "/games/"+g.ID, nil, http.StatusNoContent, nil)
+}
+
+func TestGameOver(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, `{"black": "human"}`)
+  var s State
+  for _, move := range []string{"f3", "e5", "g4", "Qh4#"} {
+    call(t, ts, "POST", "/games/"+g.ID+"/moves", fmt.Sprintf(`{"move": %q}`, move), http.StatusOK, nil)
+  }
+  s = waitForEnd(t, ts, g.ID)
+  if s.Status != "over" || s.Result != "0-1" || s.Reason != "Checkmate" || !s.Check {
+    t.Errorf("after fool's mate: %s %s %s", s.Status, s.Result, s.Reason)
+  }
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "Kf2"}`, http.StatusConflict, nil)
+  call(t, ts, "POST", "/games/"+g.ID+"/analysis", nil, http.StatusConflict, nil)
+}
+
+func TestStateAndList(t *testing.T) {
+  _, ts := newTestServer(t)
+  var games []State
+  call(t, ts, "GET", "/games", nil, http.StatusOK, &games)
+  if len(games) != 0 {
+    t.Fatalf("a new server lists %d games", len(games))
+  }
+  first := create(t, ts, `{"black": "human"}`)
+  second := create(t, ts, `{"black": "human", "time_ms": 60000, "increment_ms": 1000}`)
+  call(t, ts, "GET", "/games", nil, http.StatusOK, &games)
+  if len(games) != 2 || games[0].ID != first.ID || games[1].ID != second.ID {
+    t.Fatalf("listed %d games, want %s then %s", len(games), first.ID, second.ID)
+  }
+
+  var s State
+  call(t, ts, "GET", "/games/"+second.ID, nil, http.StatusOK, &s)
+  if s.ID != second.ID || s.Clock == nil || s.Clock.Running != "white" || s.Clock.White > 60000 || s.Clock.Black != 60000 {
+    t.Errorf("clock of %s: %+v", s.ID, s.Clock)
+  }
+  call(t, ts, "POST", "/games/"+second.ID+"/moves", `{"move": "Nf3"}`, http.StatusOK, &s)
+  if s.Clock.Running != "black" || s.Clock.White <= 60000-1000 || s.Clock.White > 61000 {
+    t.Errorf("after a move with an increment: %+v", s.Clock)
+  }
+  var f State
+  call(t, ts, "GET", "/games/"+first.ID, nil, http.StatusOK, &f)
+  if f.Clock != nil || len(f.LegalMoves) != 20 {
+    t.Errorf("game without a clock: %+v with %d moves", f.Clock, len(f.LegalMoves))
+  }
+  call(t, ts, "GET", "/games/nope", nil, http.StatusNotFound, nil)
+}
+
+func TestAnalysis(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, `{"fen": "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1", "black": "human"}`)
+  var a analysis
+  call(t, ts, "POST", "/games/"+g.ID+"/analysis", `{"depth": 3}`, http.StatusOK, &a)
+  if a.Depth != 3 || len(a.Lines) != 1 || a.Nodes == 0 {
+    t.Fatalf("depth 3: depth %d, %d lines, %d nodes", a.Depth, len(a.Lines), a.Nodes)
+  }
+  if line := a.Lines[0]; line.SAN != "Ra8#" || line.Mate != 1 {
+    t.Errorf("missed the back rank mate: %+v", line)
+  }
+
+  call(t, ts, "POST", "/games/"+g.ID+"/analysis", `{"depth": 2, "multipv": 3}`, http.StatusOK, &a)
+  if len(a.Lines) != 3 {
+    t.Fatalf("multipv 3 gave %d lines", len(a.Lines))
+  }
+  seen := map[string]bool{}
+  for i, line := range a.Lines {
+    if seen[line.Move] || len(line.PV) == 0 || line.PV[0] != line.SAN {
+      t.Errorf("line %d: %+v", i, line)
+    }
+    seen[line.Move] = true
+  }
+
+  for _, body := range []string{`{"multipv": 11}`, `{"depth": 31}`, `{"movetime_ms": -5}`} {
+    call(t, ts, "POST", "/games/"+g.ID+"/analysis", body, http.StatusBadRequest, nil)
+  }
+}
+
+func TestStopAndDelete(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, nil)
+  var s State
+  call(t, ts, "POST", "/games/"+g.ID+"/stop", nil, http.StatusOK, &s)
+  if s.Status != "stopped" || s.Waiting != "" || len(s.LegalMoves) != 0 {
+    t.Errorf("stopped game: %s, waiting for %q", s.Status, s.Waiting)
+  }
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "e4"}`, http.StatusConflict, nil)
+  call(t, ts, "DELETE", "/games/"+g.ID, nil, http.StatusNoContent, nil)
+  call(t, ts, "GET", "/games/"+g.ID, nil, http.StatusNotFound, nil)
+  call(t, ts, "DELETE", "/games/"+g.ID, nil, http.StatusNotFound, nil)
+
+  // deleting stops an engine in the middle of a search
+  e := create(t, ts, `{"white": "engine", "engine": {"movetime_ms": 60000}}`)
+  done := make(chan struct{})
+  go func() {
+    defer close(done)
+    call(t, ts, "DELETE", "/games/"+e.ID, nil, http.StatusNoContent, nil)
+  }()
+  select {
+  case <-done:
+  case <-time.After(5 * time.Second):
+    t.Fatal("deleting a game left the engine searching")
+  }
+}
+
+func TestMaxGames(t *testing.T) {
+  s, ts := newTestServer(t)
+  s.MaxGames = 2
+  g := create(t, ts, nil)
+  create(t, ts, nil)
+  call(t, ts, "POST", "/games", nil, http.StatusServiceUnavailable, nil)
+  call(t, ts, "DELETE", "/games/"+g.ID, nil, http.StatusNoContent, nil)
+  create(t, ts, nil)
+}
+
+func TestConcurrentGames(t *testing.T) {
+  _, ts := newTestServer(t)
+  t.Run("games", func(t *testing.T) {
+    for i := 0; i < 6; i++ {
+      t.Run(fmt.Sprint(i), func(t *testing.T) {
+        t.Parallel()
+        // the engine opens every other game
+        body := `{}`
+        if i%2 == 1 {
+          body = `{"white": "engine", "black": "human"}`
+        }
+        g := create(t, ts, body)
+        for ply := 0; ply < 6; ply++ {
+          s := waitForHuman(t, ts, g.ID)
+          if s.Status != "active" {
+            return
+          }
+          // every game takes a different path through the opening
+          move := s.LegalMoves[(i+ply)%len(s.LegalMoves)]
+          call(t, ts, "POST", "/games/"+g.ID+"/moves", fmt.Sprintf(`{"move": %q}`, move), http.StatusOK, nil)
+          call(t, ts, "POST", "/games/"+g.ID+"/analysis", `{"depth": 1}`, http.StatusOK, nil)
+        }
+        s := waitForHuman(t, ts, g.ID)
+        if s.Status == "active" && len(s.Moves) != 12+i%2 {
+          t.Errorf("%d moves played: %v", len(s.Moves), s.Moves)
+        }
+      })
+    }
+  })
+  var games []State
+  call(t, ts, "GET", "/games", nil, http.StatusOK, &games)
+  if len(games) != 6 {
+    t.Errorf("listed %d games, want 6", len(games))
+  }
+}
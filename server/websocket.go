@@ -0,0 +1,263 @@
+package server
+
+import (
+  "bufio"
+  "crypto/sha1"
+  "encoding/base64"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+  "unicode/utf8"
+)
+
+// Just enough of RFC 6455 for the game events: text messages,
+// fragmented or not, pings and the closing handshake. No extensions
+// or subprotocols are negotiated.
+
+const (
+  opContinuation = 0x0
+  opText = 0x1
+  opBinary = 0x2
+  opClose = 0x8
+  opPing = 0x9
+  opPong = 0xA
+)
+
+// close codes
+const (
+  closeNormal = 1000
+  closeProtocolError = 1002
+  closeUnsupported = 1003
+  closeInvalidData = 1007
+  closePolicy = 1008
+  closeTooBig = 1009
+)
+
+const (
+  wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+  maxMessage = 1 << 16
+  writeTimeout = 10 * time.Second
+)
+
+var errClosed = errors.New("websocket closed")
+
+type wsConn struct {
+  conn net.Conn
+  r *bufio.Reader
+  mu sync.Mutex // guards writes and closeSent
+  closeSent bool
+}
+
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+  // checks the opening handshake and takes the connection over from
+  // net/http, the error has already been written to w
+  key := r.Header.Get("Sec-WebSocket-Key")
+  decoded, err := base64.StdEncoding.DecodeString(key)
+  switch {
+  case r.Method != http.MethodGet || !hasToken(r.Header, "Connection", "upgrade") || !hasToken(r.Header, "Upgrade", "websocket"):
+    err = errors.New("expected a WebSocket upgrade request")
+  case r.Header.Get("Sec-WebSocket-Version") != "13":
+    w.Header().Set("Sec-WebSocket-Version", "13")
+    err = errors.New("unsupported WebSocket version")
+  case err != nil || len(decoded) != 16:
+    err = errors.New("bad Sec-WebSocket-Key")
+  }
+  if err != nil {
+    writeError(w, http.StatusBadRequest, err)
+    return nil, err
+  }
+  hijacker, ok := w.(http.Hijacker)
+  if !ok {
+    err = errors.New("the connection can not be taken over")
+    writeError(w, http.StatusInternalServerError, err)
+    return nil, err
+  }
+  conn, rw, err := hijacker.Hijack()
+  if err != nil {
+    return nil, err
+  }
+  sum := sha1.Sum([]byte(key + wsGUID))
+  fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+  if err := rw.Flush(); err != nil {
+    conn.Close()
+    return nil, err
+  }
+  // deadlines from the server's timeouts stay on a hijacked connection
+  conn.SetDeadline(time.Time{})
+  return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+func hasToken(header http.Header, name, token string) bool {
+  // whether a comma separated header lists token, ignoring case
+  for _, value := range header.Values(name) {
+    for _, t := range strings.Split(value, ",") {
+      if strings.EqualFold(strings.TrimSpace(t), token) {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+func (c *wsConn) readMessage() ([]byte, error) {
+  // The next text message. Pings are answered on the way, a close
+  // from the client is answered and ends the connection with
+  // errClosed.
+  var message []byte
+  fragmented := false
+  for {
+    fin, op, payload, err := c.readFrame()
+    if err != nil {
+      return nil, err
+    }
+    switch op {
+    case opPing:
+      if err := c.writeFrame(opPong, payload); err != nil {
+        return nil, err
+      }
+      continue
+    case opPong:
+      continue
+    case opClose:
+      c.close(closeNormal, "")
+      return nil, errClosed
+    case opBinary:
+      c.close(closeUnsupported, "only text messages are understood")
+      return nil, errClosed
+    case opText:
+      if fragmented {
+        return nil, c.fail(closeProtocolError, "new message inside a fragmented one")
+      }
+      message = payload
+    case opContinuation:
+      if !fragmented {
+        return nil, c.fail(closeProtocolError, "continuation without a message")
+      }
+      message = append(message, payload...)
+    default:
+      return nil, c.fail(closeProtocolError, "unknown opcode")
+    }
+    if len(message) > maxMessage {
+      return nil, c.fail(closeTooBig, "message too big")
+    }
+    if !fin {
+      fragmented = true
+      continue
+    }
+    if !utf8.Valid(message) {
+      return nil, c.fail(closeInvalidData, "text is not UTF-8")
+    }
+    return message, nil
+  }
+}
+
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+  var header [2]byte
+  if _, err = io.ReadFull(c.r, header[:]); err != nil {
+    return
+  }
+  fin = header[0]&0x80 != 0
+  op = header[0] & 0x0F
+  masked := header[1]&0x80 != 0
+  length := uint64(header[1] & 0x7F)
+  switch {
+  case header[0]&0x70 != 0:
+    err = c.fail(closeProtocolError, "reserved bits set")
+  case !masked:
+    err = c.fail(closeProtocolError, "client frames must be masked")
+  case op >= opClose && (!fin || length > 125):
+    err = c.fail(closeProtocolError, "bad control frame")
+  }
+  if err != nil {
+    return
+  }
+  switch length {
+  case 126:
+    var ext [2]byte
+    if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+      return
+    }
+    length = uint64(binary.BigEndian.Uint16(ext[:]))
+  case 127:
+    var ext [8]byte
+    if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+      return
+    }
+    length = binary.BigEndian.Uint64(ext[:])
+  }
+  if length > maxMessage {
+    err = c.fail(closeTooBig, "message too big")
+    return
+  }
+  var mask [4]byte
+  if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+    return
+  }
+  payload = make([]byte, length)
+  if _, err = io.ReadFull(c.r, payload); err != nil {
+    return
+  }
+  for i := range payload {
+    payload[i] ^= mask[i%4]
+  }
+  return
+}
+
+func (c *wsConn) writeText(data []byte) error {
+  return c.writeFrame(opText, data)
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+  // one unfragmented, unmasked frame, servers do not mask
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if c.closeSent {
+    return errClosed
+  }
+  return c.writeFrameLocked(op, payload)
+}
+
+func (c *wsConn) writeFrameLocked(op byte, payload []byte) error {
+  header := []byte{0x80 | op, 0}
+  switch n := len(payload); {
+  case n <= 125:
+    header[1] = byte(n)
+  case n <= 0xFFFF:
+    header[1] = 126
+    header = binary.BigEndian.AppendUint16(header, uint16(n))
+  default:
+    header[1] = 127
+    header = binary.BigEndian.AppendUint64(header, uint64(n))
+  }
+  c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+  if _, err := c.conn.Write(append(header, payload...)); err != nil {
+    return err
+  }
+  return nil
+}
+
+func (c *wsConn) close(code int, reason string) {
+  // Starts or answers the closing handshake. The client has a few
+  // seconds to answer before the read side gives up.
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if c.closeSent {
+    return
+  }
+  c.closeSent = true
+  payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+  c.writeFrameLocked(opClose, append(payload, reason...))
+  c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+}
+
+func (c *wsConn) fail(code int, reason string) error {
+  // closes the connection over a client that broke the protocol
+  c.close(code, reason)
+  return fmt.Errorf("websocket: %s", reason)
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+  "bufio"
+  "encoding/binary"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+// wsClient speaks the client side of RFC 6455 frame by frame, so the
+// tests can also send what a well behaved client never would.
+type wsClient struct {
+  t *testing.T
+  conn net.Conn
+  r *bufio.Reader
+}
+
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+func handshake(t *testing.T, ts *httptest.Server, path string, header map[string]string) (*wsClient, *http.Response) {
+  // sends the opening handshake with header on top of a valid one,
+  // an empty value leaves that header out
+  t.Helper()
+  conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { conn.Close() })
+  conn.SetDeadline(time.Now().Add(10 * time.Second))
+  req, err := http.NewRequest("GET", ts.URL+path, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  req.Header.Set("Connection", "Upgrade")
+  req.Header.Set("Upgrade", "websocket")
+  req.Header.Set("Sec-WebSocket-Version", "13")
+  req.Header.Set("Sec-WebSocket-Key", testKey)
+  for name, value := range header {
+    if value == "" {
+      req.Header.Del(name)
+    } else {
+      req.Header.Set(name, value)
+    }
+  }
+  if err := req.Write(conn); err != nil {
+    t.Fatal(err)
+  }
+  r := bufio.NewReader(conn)
+  resp, err := http.ReadResponse(r, req)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return &wsClient{t, conn, r}, resp
+}
+
+func dial(t *testing.T, ts *httptest.Server, id, as string) *wsClient {
+  // joins a game and reads the state event every client starts with
+  t.Helper()
+  c, resp := handshake(t, ts, "/games/"+id+"/ws?as="+as, nil)
+  if resp.StatusCode != http.StatusSwitchingProtocols {
+    t.Fatalf("joining %s as %s: status %d", id, as, resp.StatusCode)
+  }
+  if e := c.readEvent(); e.Type != "state" || e.State == nil || e.State.ID != id {
+    t.Fatalf("first event %+v, want the state", e)
+  }
+  return c
+}
+
+func (c *wsClient) writeFrame(fin bool, op byte, payload []byte, masked bool) {
+  c.t.Helper()
+  header := []byte{op, 0}
+  if fin {
+    header[0] |= 0x80
+  }
+  switch n := len(payload); {
+  case n <= 125:
+    header[1] = byte(n)
+  case n <= 0xFFFF:
+    header[1] = 126
+    header = binary.BigEndian.AppendUint16(header, uint16(n))
+  default:
+    header[1] = 127
+    header = binary.BigEndian.AppendUint64(header, uint64(n))
+  }
+  data := payload
+  if masked {
+    mask := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+    header[1] |= 0x80
+    header = append(header, mask[:]...)
+    data = make([]byte, len(payload))
+    for i := range payload {
+      data[i] = payload[i] ^ mask[i%4]
+    }
+  }
+  if _, err := c.conn.Write(append(header, data...)); err != nil {
+    c.t.Fatal(err)
+  }
+}
+
+func (c *wsClient) send(msg string) {
+  c.t.Helper()
+  c.writeFrame(true, opText, []byte(msg), true)
+}
+
+func (c *wsClient) readFrame() (op byte, payload []byte) {
+  // the next frame from the server, which never masks
+  c.t.Helper()
+  var header [2]byte
+  if _, err := io.ReadFull(c.r, header[:]); err != nil {
+    c.t.Fatal(err)
+  }
+  if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+    c.t.Fatalf("server frame %x: fragmented or masked", header)
+  }
+  length := uint64(header[1] & 0x7F)
+  switch length {
+  case 126:
+    var ext [2]byte
+    io.ReadFull(c.r, ext[:])
+    length = uint64(binary.BigEndian.Uint16(ext[:]))
+  case 127:
+    var ext [8]byte
+    io.ReadFull(c.r, ext[:])
+    length = binary.BigEndian.Uint64(ext[:])
+  }
+  payload = make([]byte, length)
+  if _, err := io.ReadFull(c.r, payload); err != nil {
+    c.t.Fatal(err)
+  }
+  return header[0] & 0x0F, payload
+}
+
+func (c *wsClient) readEvent() event {
+  c.t.Helper()
+  op, payload := c.readFrame()
+  if op != opText {
+    c.t.Fatalf("got opcode %d (%q), want a text frame", op, payload)
+  }
+  var e event
+  if err := json.Unmarshal(payload, &e); err != nil {
+    c.t.Fatal(err)
+  }
+  return e
+}
+
+func (c *wsClient) expectClose(code int) {
+  // reads up to the server's close frame, which must carry code
+  c.t.Helper()
+  for {
+    op, payload := c.readFrame()
+    if op != opClose {
+      continue
+    }
+    if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+      c.t.Fatalf("close frame %q, want code %d", payload, code)
+    }
+    return
+  }
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, nil)
+  path := "/games/" + g.ID + "/ws"
+
+  // the example from RFC 6455
+  _, resp := handshake(t, ts, path, nil)
+  if resp.StatusCode != http.StatusSwitchingProtocols {
+    t.Fatalf("status %d, want 101", resp.StatusCode)
+  }
+  if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+    t.Errorf("Sec-WebSocket-Accept %q", got)
+  }
+
+  tests := []struct {
+    name string
+    header map[string]string
+  }{
+    {"version 8", map[string]string{"Sec-WebSocket-Version": "8"}},
+    {"no version", map[string]string{"Sec-WebSocket-Version": ""}},
+    {"key not base64", map[string]string{"Sec-WebSocket-Key": "not a key!"}},
+    {"short key", map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}},
+    {"no key", map[string]string{"Sec-WebSocket-Key": ""}},
+    {"no upgrade", map[string]string{"Upgrade": ""}},
+    {"keep-alive", map[string]string{"Connection": "keep-alive"}},
+  }
+  for _, tt := range tests {
+    _, resp := handshake(t, ts, path, tt.header)
+    if resp.StatusCode != http.StatusBadRequest {
+      t.Errorf("%s: status %d, want 400", tt.name, resp.StatusCode)
+    }
+    if tt.name == "version 8" && resp.Header.Get("Sec-WebSocket-Version") != "13" {
+      t.Errorf("%s: the supported version is not named", tt.name)
+    }
+  }
+
+  _, resp = handshake(t, ts, "/games/nope/ws", nil)
+  if resp.StatusCode != http.StatusNotFound {
+    t.Errorf("unknown game: status %d, want 404", resp.StatusCode)
+  }
+  _, resp = handshake(t, ts, path+"?as=referee", nil)
+  if resp.StatusCode != http.StatusBadRequest {
+    t.Errorf("joining as a referee: status %d, want 400", resp.StatusCode)
+  }
+}
+
+func TestWebSocketFrames(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, `{"black": "human"}`)
+  white := dial(t, ts, g.ID, "white")
+
+  // one masked frame
+  white.send(`{"type": "move", "move": "e4"}`)
+  if e := white.readEvent(); e.Type != "move" || e.SAN != "e4" || e.Ply != 1 || e.Turn != "black" {
+    t.Fatalf("after e4: %+v", e)
+  }
+
+  // a message in three fragments, with a ping between them
+  black := dial(t, ts, g.ID, "black")
+  black.writeFrame(false, opText, []byte(`{"type": "mo`), true)
+  black.writeFrame(true, opPing, []byte("between"), true)
+  if op, payload := black.readFrame(); op != opPong || string(payload) != "between" {
+    t.Fatalf("ping answered with opcode %d %q", op, payload)
+  }
+  black.writeFrame(false, opContinuation, []byte(`ve", "move"`), true)
+  black.writeFrame(true, opContinuation, []byte(`: "c5"}`), true)
+  if e := black.readEvent(); e.Type != "move" || e.SAN != "c5" || e.Ply != 2 {
+    t.Fatalf("after the fragmented c5: %+v", e)
+  }
+  if e := white.readEvent(); e.SAN != "c5" {
+    t.Fatalf("white did not see c5: %+v", e)
+  }
+
+  // bad messages get an error event and the connection stays up
+  for _, msg := range []string{`not json`, `{"type": "chat"}`, `{"type": "move", "move": "Ke3"}`, `{"type": "move", "move": "Nf3"}`} {
+    black.send(msg)
+    if e := black.readEvent(); e.Type != "error" || e.Error == "" {
+      t.Errorf("%s: got %+v, want an error", msg, e)
+    }
+  }
+
+  // the closing handshake, the server echoes the code
+  black.writeFrame(true, opClose, binary.BigEndian.AppendUint16(nil, closeNormal), true)
+  black.expectClose(closeNormal)
+  if _, err := black.r.ReadByte(); err != io.EOF {
+    t.Errorf("the connection stayed open after the close: %v", err)
+  }
+  // the seat is free again
+  dial(t, ts, g.ID, "black")
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, `{"black": "human"}`)
+
+  c := dial(t, ts, g.ID, "spectator")
+  c.writeFrame(true, opText, []byte(`{"type": "move", "move": "e4"}`), false)
+  c.expectClose(closeProtocolError)
+
+  c = dial(t, ts, g.ID, "spectator")
+  c.writeFrame(true, opText, make([]byte, maxMessage+1), true)
+  c.expectClose(closeTooBig)
+
+  // too big once the fragments are put together
+  c = dial(t, ts, g.ID, "spectator")
+  c.writeFrame(false, opText, make([]byte, maxMessage/2+1), true)
+  c.writeFrame(true, opContinuation, make([]byte, maxMessage/2+1), true)
+  c.expectClose(closeTooBig)
+
+  c = dial(t, ts, g.ID, "spectator")
+  c.writeFrame(true, opContinuation, []byte("{}"), true)
+  c.expectClose(closeProtocolError)
+
+  c = dial(t, ts, g.ID, "spectator")
+  c.writeFrame(true, opBinary, []byte{1, 2, 3}, true)
+  c.expectClose(closeUnsupported)
+
+  c = dial(t, ts, g.ID, "spectator")
+  c.writeFrame(true, opText, []byte{0xff, 0xfe}, true)
+  c.expectClose(closeInvalidData)
+}
+
+func TestWebSocketSeats(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, nil)
+  dial(t, ts, g.ID, "white")
+  for _, as := range []string{"white", "black"} {
+    if _, resp := handshake(t, ts, "/games/"+g.ID+"/ws?as="+as, nil); resp.StatusCode != http.StatusConflict {
+      t.Errorf("joining as %s: status %d, want 409", as, resp.StatusCode)
+    }
+  }
+  // a seated side only moves over its WebSocket
+  call(t, ts, "POST", "/games/"+g.ID+"/moves", `{"move": "e4"}`, http.StatusConflict, nil)
+  // any number of spectators
+  dial(t, ts, g.ID, "spectator")
+  dial(t, ts, g.ID, "")
+}
+
+func TestWebSocketGame(t *testing.T) {
+  _, ts := newTestServer(t)
+  g := create(t, ts, `{"black": "human"}`)
+  white := dial(t, ts, g.ID, "white")
+  black := dial(t, ts, g.ID, "black")
+  spectator := dial(t, ts, g.ID, "spectator")
+
+  spectator.send(`{"type": "move", "move": "e4"}`)
+  if e := spectator.readEvent(); e.Type != "error" {
+    t.Errorf("a spectator moved: %+v", e)
+  }
+  black.send(`{"type": "move", "move": "e5"}`)
+  if e := black.readEvent(); e.Type != "error" {
+    t.Errorf("black moved first: %+v", e)
+  }
+
+  players := []*wsClient{white, black}
+  clients := []*wsClient{white, black, spectator}
+  for ply, san := range []string{"f3", "e5", "g4", "Qh4#"} {
+    players[ply%2].send(fmt.Sprintf(`{"type": "move", "move": %q}`, san))
+    for i, c := range clients {
+      e := c.readEvent()
+      if e.Type != "move" || e.SAN != san || e.Ply != ply+1 || !strings.Contains(e.FEN, " ") {
+        t.Fatalf("client %d, ply %d: got %+v, want %s", i, ply+1, e, san)
+      }
+    }
+  }
+  for i, c := range clients {
+    e := c.readEvent()
+    if e.Type != "result" || e.Status != "over" || e.Result != "0-1" || e.Reason != "Checkmate" {
+      t.Errorf("client %d: got %+v, want the result", i, e)
+    }
+    c.expectClose(closeNormal)
+  }
+}
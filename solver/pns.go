@@ -0,0 +1,158 @@
+package solver
+
+import (
+  chess "chess/board"
+)
+
+// Proof-number search grows the tree towards whichever position is
+// cheapest to prove or disprove, measured by how many leaves would
+// still need to be solved, so deep forced lines are found without
+// searching every move to full depth. Nodes only keep their move and
+// boards are rebuilt on the way down to keep the tree small.
+
+const infinity = 1 << 30
+
+type pnNode struct {
+  move chess.Move
+  attacker bool // the attacker is to move, an OR node
+  plies int // half moves left for the mate
+  proof, disproof int
+  parent *pnNode
+  children []*pnNode
+  expanded bool
+}
+
+func (s *solver) solveProofNumber(board *chess.Board, n int) Result {
+  maxNodes := s.opts.MaxNodes
+  if maxNodes == 0 {
+    maxNodes = DefaultMaxNodes
+  }
+  var result Result
+  for _, move := range s.attackerMoves(board, n) {
+    child := play(board, move)
+    root := s.newNode(nil, move, child, false, 2*n-2)
+    start := s.nodes
+    for root.proof != 0 && root.disproof != 0 && s.nodes-start < maxNodes {
+      node, nodeBoard := s.mostProving(root, child)
+      s.expand(node, nodeBoard)
+      update(node)
+    }
+    switch {
+    case root.proof == 0:
+      result.Keys = append(result.Keys, proofTree(root))
+    case root.disproof != 0:
+      result.Unknown = append(result.Unknown, move)
+    }
+  }
+  result.Nodes = s.nodes
+  return result
+}
+
+func (s *solver) newNode(parent *pnNode, move chess.Move, board *chess.Board, attacker bool, plies int) *pnNode {
+  s.nodes++
+  node := &pnNode{move: move, attacker: attacker, plies: plies, parent: parent, proof: 1, disproof: 1}
+  if !attacker {
+    // the defender is either mated, or out of time when the plies
+    // have run out
+    if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+      if board.IsCheck(board.Turn) {
+        node.proof, node.disproof = 0, infinity
+      } else {
+        node.proof, node.disproof = infinity, 0
+      }
+      node.expanded = true
+    } else if plies == 0 {
+      node.proof, node.disproof = infinity, 0
+      node.expanded = true
+    }
+  }
+  return node
+}
+
+func (s *solver) mostProving(node *pnNode, board *chess.Board) (*pnNode, *chess.Board) {
+  // walks down from the root always taking the child that decides
+  // its parent's number
+  for node.expanded {
+    var next *pnNode
+    for _, child := range node.children {
+      if (node.attacker && child.proof == node.proof) || (!node.attacker && child.disproof == node.disproof) {
+        next = child
+        break
+      }
+    }
+    if next == nil {
+      break
+    }
+    node = next
+    board = play(board, node.move)
+  }
+  return node, board
+}
+
+func (s *solver) expand(node *pnNode, board *chess.Board) {
+  node.expanded = true
+  var moves []chess.Move
+  if node.attacker {
+    moves = s.attackerMoves(board, (node.plies+1)/2)
+  } else {
+    moves = board.GetAllLegalMoves(board.Turn)
+  }
+  for _, move := range moves {
+    child := s.newNode(node, move, play(board, move), !node.attacker, node.plies-1)
+    node.children = append(node.children, child)
+    // stop early once the node is decided
+    if (node.attacker && child.proof == 0) || (!node.attacker && child.disproof == 0) {
+      break
+    }
+  }
+}
+
+func (node *pnNode) setNumbers() {
+  if !node.expanded {
+    return
+  }
+  if node.attacker {
+    node.proof, node.disproof = infinity, 0
+    for _, child := range node.children {
+      node.proof = min(node.proof, child.proof)
+      node.disproof = min(node.disproof+child.disproof, infinity)
+    }
+  } else {
+    node.proof, node.disproof = 0, infinity
+    for _, child := range node.children {
+      node.proof = min(node.proof+child.proof, infinity)
+      node.disproof = min(node.disproof, child.disproof)
+    }
+  }
+  // a refuted subtree is never needed again
+  if node.disproof == 0 {
+    node.children = nil
+  }
+}
+
+func update(node *pnNode) {
+  for ; node != nil; node = node.parent {
+    node.setNumbers()
+  }
+}
+
+func proofTree(node *pnNode) *Node {
+  // node is a proved defender node, the position after an attacker
+  // move
+  tree := &Node{Move: node.move}
+  if len(node.children) == 0 {
+    tree.Mate = true
+    return tree
+  }
+  for _, defence := range node.children {
+    reply := &Node{Move: defence.move}
+    for _, answer := range defence.children {
+      if answer.proof == 0 {
+        reply.Replies = []*Node{proofTree(answer)}
+        break
+      }
+    }
+    tree.Replies = append(tree.Replies, reply)
+  }
+  return tree
+}
@@ -0,0 +1,186 @@
+package solver
+
+import (
+  "fmt"
+  "io"
+  chess "chess/board"
+)
+
+// Solves "mate in n" problems: the side to move, the attacker, has to
+// mate within n of its own moves against any defence. Unlike the
+// engine's search this is exact, every defence is looked at and a
+// problem is either proved or refuted.
+
+type Options struct {
+  ChecksOnly bool // the attacker may only give check, for checks-only problems and as a faster filter
+  ProofNumber bool // use proof-number search instead of a depth-first search
+  MaxNodes int // proof-number search gives up after this many nodes per key move, 0 for DefaultMaxNodes
+}
+
+const DefaultMaxNodes = 1000000
+
+// Node is one move in the solution tree. An attacker's move lists
+// every defence in Replies and each defence lists the single attacker
+// move that keeps the mate going.
+type Node struct {
+  Move chess.Move
+  Mate bool // this move gives checkmate
+  Replies []*Node
+}
+
+type Result struct {
+  Keys []*Node // every first move that forces mate, empty if there is none
+  Nodes int // positions looked at
+  Unknown []chess.Move // proof-number search ran out of nodes on these
+}
+
+func (r Result) Solved() bool {
+  return len(r.Keys) > 0
+}
+
+func Solve(board *chess.Board, n int, opts Options) Result {
+  s := &solver{opts: opts, table: map[uint64]bounds{}}
+  if opts.ProofNumber {
+    return s.solveProofNumber(board, n)
+  }
+  var result Result
+  for _, move := range s.attackerMoves(board, n) {
+    child := play(board, move)
+    if s.forced(child, n) {
+      result.Keys = append(result.Keys, s.attackTree(board, move, child, n))
+    }
+  }
+  result.Nodes = s.nodes
+  return result
+}
+
+type solver struct {
+  opts Options
+  table map[uint64]bounds
+  nodes int
+}
+
+// what is known about a position with the attacker to move: it mates
+// in at most proved moves, and does not mate in refuted moves
+type bounds struct {
+  proved int // 0 when unknown
+  refuted int
+}
+
+func play(board *chess.Board, move chess.Move) *chess.Board {
+  child := board.Clone()
+  child.PlayMove(move)
+  return child
+}
+
+func (s *solver) attackerMoves(board *chess.Board, n int) []chess.Move {
+  // checks first, they are the likeliest keys. On the last move only
+  // checks can mate, so nothing else is tried.
+  var checks, quiet []chess.Move
+  for _, move := range board.GetAllLegalMoves(board.Turn) {
+    child := play(board, move)
+    if child.IsCheck(child.Turn) {
+      checks = append(checks, move)
+    } else if n > 1 && !s.opts.ChecksOnly {
+      quiet = append(quiet, move)
+    }
+  }
+  return append(checks, quiet...)
+}
+
+func (s *solver) mates(board *chess.Board, n int) bool {
+  // true if the attacker, to move on board, mates within n moves
+  if n <= 0 {
+    return false
+  }
+  s.nodes++
+  hash := board.GetZobristHash()
+  known := s.table[hash]
+  if known.proved != 0 && known.proved <= n {
+    return true
+  }
+  if known.refuted >= n {
+    return false
+  }
+  for _, move := range s.attackerMoves(board, n) {
+    if s.forced(play(board, move), n) {
+      known.proved = n
+      s.table[hash] = known
+      return true
+    }
+  }
+  known.refuted = n
+  s.table[hash] = known
+  return false
+}
+
+func (s *solver) forced(board *chess.Board, n int) bool {
+  // true if every defence on board, the position after the attacker's
+  // nth move from the end, still loses in time
+  s.nodes++
+  replies := board.GetAllLegalMoves(board.Turn)
+  if len(replies) == 0 {
+    return board.IsCheck(board.Turn)
+  }
+  if n <= 1 {
+    return false
+  }
+  for _, reply := range replies {
+    if !s.mates(play(board, reply), n-1) {
+      return false
+    }
+  }
+  return true
+}
+
+func (s *solver) attackTree(board *chess.Board, move chess.Move, child *chess.Board, n int) *Node {
+  // the solution after a proved attacker move, answering every
+  // defence with the quickest mate
+  node := &Node{Move: move}
+  replies := child.GetAllLegalMoves(child.Turn)
+  if len(replies) == 0 {
+    node.Mate = true
+    return node
+  }
+  for _, reply := range replies {
+    next := play(child, reply)
+    defence := &Node{Move: reply}
+    for k := 1; k < n && defence.Replies == nil; k++ {
+      for _, answer := range s.attackerMoves(next, k) {
+        after := play(next, answer)
+        if s.forced(after, k) {
+          defence.Replies = []*Node{s.attackTree(next, answer, after, k)}
+          break
+        }
+      }
+    }
+    node.Replies = append(node.Replies, defence)
+  }
+  return node
+}
+
+func WriteTree(w io.Writer, keys []*Node) {
+  // prints each key with the defences indented under it:
+  //
+  //   1. d1h5
+  //     1... g7g6 2. h5e5#
+  for _, key := range keys {
+    writeAttack(w, key, 1, "", "")
+  }
+}
+
+func writeAttack(w io.Writer, node *Node, number int, indent, lead string) {
+  mark := ""
+  if node.Mate {
+    mark = "#"
+  }
+  fmt.Fprintf(w, "%s%s%d. %s%s\n", indent, lead, number, node.Move, mark)
+  for _, defence := range node.Replies {
+    lead := fmt.Sprintf("%d... %s ", number, defence.Move)
+    if len(defence.Replies) == 0 {
+      fmt.Fprintf(w, "%s  %s\n", indent, lead)
+      continue
+    }
+    writeAttack(w, defence.Replies[0], number+1, indent+"  ", lead)
+  }
+}
@@ -0,0 +1,139 @@
+package solver
+
+import (
+  "slices"
+  "strings"
+  "testing"
+  chess "chess/board"
+)
+
+var problems = []struct {
+  name string
+  fen string
+  n int
+  keys []string // empty when there is no mate in n
+}{
+  {"back rank", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 1, []string{"a1a8"}},
+  {"Morphy", "kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", 2, []string{"a1a6"}},
+  {"rook to the edge", "3k4/8/4K3/8/8/8/8/7R w - - 0 1", 2, []string{"h1c1"}},
+  {"two keys", "k7/8/2K5/8/8/8/8/7R w - - 0 1", 2, []string{"c6b6", "c6c7"}},
+  {"king opposite", "4k3/8/8/4K3/8/8/8/7R w - - 0 1", 3, []string{"e5e6"}},
+  {"Legal's mate", "r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1", 3, []string{"f8c5"}},
+  {"kings apart", "k7/8/8/8/8/8/8/K6R w - - 0 1", 3, nil},
+}
+
+func solve(t *testing.T, fen string, n int, opts Options) (*chess.Board, Result) {
+  t.Helper()
+  board, err := chess.NewBoardFromFEN(fen)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return board, Solve(board, n, opts)
+}
+
+func keyMoves(r Result) []string {
+  var keys []string
+  for _, key := range r.Keys {
+    keys = append(keys, key.Move.String())
+  }
+  slices.Sort(keys)
+  return keys
+}
+
+func TestSolve(t *testing.T) {
+  for _, method := range []struct {
+    name string
+    opts Options
+  }{
+    {"depth first", Options{}},
+    {"proof number", Options{ProofNumber: true}},
+  } {
+    for _, p := range problems {
+      board, result := solve(t, p.fen, p.n, method.opts)
+      if keys := keyMoves(result); !slices.Equal(keys, p.keys) {
+        t.Errorf("%s, %s: keys %v, want %v", method.name, p.name, keys, p.keys)
+      }
+      if len(result.Unknown) != 0 {
+        t.Errorf("%s, %s: gave up on %v", method.name, p.name, result.Unknown)
+      }
+      for _, key := range result.Keys {
+        checkAttack(t, board, key, p.n)
+      }
+      // and there is no quicker mate
+      if len(p.keys) != 0 && p.n > 1 {
+        if _, quicker := solve(t, p.fen, p.n-1, method.opts); quicker.Solved() {
+          t.Errorf("%s, %s: solved in %d", method.name, p.name, p.n-1)
+        }
+      }
+    }
+  }
+}
+
+func checkAttack(t *testing.T, board *chess.Board, node *Node, n int) {
+  // node has to be a legal move that mates, or leads on to mate
+  // within n moves against every defence
+  t.Helper()
+  if n < 1 || !board.IsLegal(node.Move) {
+    t.Fatalf("%s: %s is illegal or too late", board.FEN(), node.Move)
+  }
+  after := play(board, node.Move)
+  if node.Mate {
+    if !after.IsCheckmate() {
+      t.Fatalf("%s: %s is marked as mate", board.FEN(), node.Move)
+    }
+    return
+  }
+  var defences []string
+  for _, move := range after.GetAllLegalMoves(after.Turn) {
+    defences = append(defences, move.String())
+  }
+  var answered []string
+  for _, defence := range node.Replies {
+    answered = append(answered, defence.Move.String())
+    if len(defence.Replies) != 1 {
+      t.Fatalf("%s: %d answers to %s", after.FEN(), len(defence.Replies), defence.Move)
+    }
+    checkAttack(t, play(after, defence.Move), defence.Replies[0], n-1)
+  }
+  slices.Sort(defences)
+  slices.Sort(answered)
+  if !slices.Equal(defences, answered) {
+    t.Fatalf("%s: answers %v, want every defence %v", after.FEN(), answered, defences)
+  }
+}
+
+func TestChecksOnly(t *testing.T) {
+  // Morphy's key is a quiet move
+  _, result := solve(t, "kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", 2, Options{ChecksOnly: true})
+  if result.Solved() {
+    t.Errorf("solved with %v", keyMoves(result))
+  }
+  _, result = solve(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 1, Options{ChecksOnly: true})
+  if keys := keyMoves(result); !slices.Equal(keys, []string{"a1a8"}) {
+    t.Errorf("keys %v, want [a1a8]", keys)
+  }
+}
+
+func TestMaxNodes(t *testing.T) {
+  // too few nodes to prove anything leaves the key unknown rather
+  // than refuted
+  _, result := solve(t, "4k3/8/8/4K3/8/8/8/7R w - - 0 1", 3, Options{ProofNumber: true, MaxNodes: 10})
+  if result.Solved() || !slices.ContainsFunc(result.Unknown, func(m chess.Move) bool { return m.String() == "e5e6" }) {
+    t.Errorf("keys %v, unknown %v", keyMoves(result), result.Unknown)
+  }
+}
+
+func TestWriteTree(t *testing.T) {
+  _, result := solve(t, "4k3/8/8/4K3/8/8/8/7R w - - 0 1", 3, Options{})
+  var out strings.Builder
+  WriteTree(&out, result.Keys)
+  want := `1. e5e6
+  1... e8d8 2. h1c1
+    2... d8e8 3. c1c8#
+  1... e8f8 2. h1g1
+    2... f8e8 3. g1g8#
+`
+  if out.String() != want {
+    t.Errorf("got\n%swant\n%s", out.String(), want)
+  }
+}
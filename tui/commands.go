@@ -0,0 +1,249 @@
+package tui
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  chess "chess/board"
+  "chess/engine"
+  pgn "chess/pgn"
+  variant "chess/variant"
+)
+
+// Commands typed on the input line instead of a move. Most only show
+// something and are handled here while GetMove waits for a move. The
+// ones that change the game, undo, draw and load, are handed to the
+// game loop as errors from GetMove, so none of them count as a move.
+
+type command struct {
+  usage string
+  help string
+  run func(ui *UI, board *chess.Board, arg string) (chess.Move, bool, error)
+}
+
+var commands map[string]command
+
+// the order help lists them in
+var commandNames = []string{"hint", "eval", "moves", "fen", "pgn", "save", "load", "flip", "undo", "draw", "resign", "help"}
+
+func init() {
+  // set up here as help refers back to the table
+  commands = map[string]command{
+    "hint": {"hint", "ask the engine for a move", (*UI).hint},
+    "eval": {"eval", "evaluate the position", (*UI).eval},
+    "moves": {"moves", "list the legal moves", (*UI).listMoves},
+    "fen": {"fen", "show the position as FEN", (*UI).showFEN},
+    "pgn": {"pgn", "show the moves as PGN", (*UI).showPGN},
+    "save": {"save <file>", "save as PGN, or as FEN for .fen", (*UI).save},
+    "load": {"load <file>", "carry on from a PGN or FEN", (*UI).load},
+    "flip": {"flip", "turn the board around", (*UI).flip},
+    "undo": {"undo", "take back your last move", (*UI).undo},
+    "draw": {"draw", "offer a draw", (*UI).offerDraw},
+    "resign": {"resign", "give up the game", (*UI).resign},
+    "help": {"help", "list the commands", (*UI).help},
+  }
+}
+
+func (ui *UI) typed(board *chess.Board, text string) (chess.Move, bool, error) {
+  // a move or a command typed on the input line
+  if text == "" {
+    return chess.Move{}, false, nil
+  }
+  name, arg, _ := strings.Cut(text, " ")
+  if cmd, ok := commands[strings.ToLower(name)]; ok {
+    return cmd.run(ui, board, strings.TrimSpace(arg))
+  }
+  move, err := board.ParseSAN(text)
+  if err != nil {
+    ui.status = err.Error() + ", type help for the commands"
+    return chess.Move{}, false, nil
+  }
+  return move, true, nil
+}
+
+func (ui *UI) analyse(board *chess.Board) engine.SearchResult {
+  // a search with the analysis settings, shown as thinking meanwhile
+  provider := ui.opts.Analysis
+  if provider.SearchDepth == 0 && provider.Limits.Depth == 0 && provider.Limits.Time == 0 && provider.Limits.Nodes == 0 {
+    provider.SearchDepth = 4
+  }
+  ui.status = "Thinking..."
+  ui.render()
+  result := provider.Search(board)
+  ui.status = ""
+  return result
+}
+
+func (ui *UI) hint(board *chess.Board, arg string) (chess.Move, bool, error) {
+  result := ui.analyse(board)
+  if result.Move == (chess.Move{}) {
+    ui.status = "No moves to suggest"
+    return chess.Move{}, false, nil
+  }
+  ui.status = fmt.Sprintf("Hint: %s (%s)", board.SAN(result.Move), formatScore(bestLine(result)))
+  ui.cursor = result.Move.End
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) eval(board *chess.Board, arg string) (chess.Move, bool, error) {
+  evaluator := ui.opts.Analysis.Evaluator
+  if evaluator == nil {
+    evaluator = engine.HeuristicEvaluator{}
+  }
+  static := evaluator.Evaluate(board)
+  result := ui.analyse(board)
+  ui.status = fmt.Sprintf("Eval %s at depth %d, static %+.2f, white's view", formatScore(bestLine(result)), result.Depth, float64(static)/100)
+  return chess.Move{}, false, nil
+}
+
+func bestLine(result engine.SearchResult) engine.PVLine {
+  if len(result.Lines) > 0 {
+    return result.Lines[0]
+  }
+  return engine.PVLine{Move: result.Move, Score: result.Score}
+}
+
+func (ui *UI) listMoves(board *chess.Board, arg string) (chess.Move, bool, error) {
+  moves := board.GetAllLegalMoves(board.Turn)
+  sans := make([]string, len(moves))
+  for i, move := range moves {
+    sans[i] = board.SAN(move)
+  }
+  ui.notes = []string{fmt.Sprintf("%d legal moves: %s", len(moves), strings.Join(sans, " "))}
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) showFEN(board *chess.Board, arg string) (chess.Move, bool, error) {
+  ui.notes = []string{variant.FEN(board)}
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) game() *pgn.Game {
+  // the game so far, from the first position the UI saw
+  moves := make([]chess.Move, len(ui.history))
+  for i, p := range ui.history {
+    moves[i] = p.move
+  }
+  game := pgn.NewGame(ui.start, moves)
+  game.SetTag("White", ui.opts.Names[chess.White])
+  game.SetTag("Black", ui.opts.Names[chess.Black])
+  return game
+}
+
+func (ui *UI) Game() *pgn.Game {
+  // the game as played so far, for saving once it is over
+  ui.mu.Lock()
+  defer ui.mu.Unlock()
+  return ui.game()
+}
+
+func (ui *UI) showPGN(board *chess.Board, arg string) (chess.Move, bool, error) {
+  // only the movetext, the tags would not leave room for it
+  text := ui.game().String()
+  if _, moves, ok := strings.Cut(text, "\n\n"); ok {
+    text = moves
+  }
+  ui.notes = []string{strings.Join(strings.Fields(text), " ")}
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) save(board *chess.Board, arg string) (chess.Move, bool, error) {
+  if arg == "" {
+    ui.status = "usage: save <file>"
+    return chess.Move{}, false, nil
+  }
+  text := ui.game().String()
+  if strings.EqualFold(filepath.Ext(arg), ".fen") {
+    text = variant.FEN(board) + "\n"
+  }
+  if err := os.WriteFile(arg, []byte(text), 0644); err != nil {
+    ui.status = err.Error()
+    return chess.Move{}, false, nil
+  }
+  ui.status = "Saved to " + arg
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) load(board *chess.Board, arg string) (chess.Move, bool, error) {
+  // Reads the last game in a PGN file, or a FEN in the rules of the
+  // game being played. The move list is rebuilt here and the game
+  // loop takes the positions from the error.
+  if arg == "" {
+    ui.status = "usage: load <file>"
+    return chess.Move{}, false, nil
+  }
+  data, err := os.ReadFile(arg)
+  if err != nil {
+    ui.status = err.Error()
+    return chess.Move{}, false, nil
+  }
+  boards, moves, err := readGame(string(data), board)
+  if err != nil {
+    ui.status = fmt.Sprintf("%s: %v", arg, err)
+    return chess.Move{}, false, nil
+  }
+  ui.reset(boards[0])
+  for i, move := range moves {
+    ui.record(boards[i], move)
+  }
+  ui.board = boards[len(boards)-1].Clone()
+  ui.check = ui.board.IsCheck(ui.board.Turn)
+  ui.status = "Loaded " + arg
+  return chess.Move{}, true, &chess.Load{Boards: boards}
+}
+
+func readGame(text string, current *chess.Board) ([]*chess.Board, []chess.Move, error) {
+  if games, err := pgn.Parse(text); err == nil && len(games) > 0 {
+    game := games[len(games)-1]
+    boards, err := game.Boards()
+    if err != nil {
+      return nil, nil, err
+    }
+    moves := make([]chess.Move, len(game.Moves))
+    for i, move := range game.Moves {
+      moves[i] = move.Move
+    }
+    return boards, moves, nil
+  }
+  board, err := variant.NewBoardFromFEN(current.Variant, strings.TrimSpace(text))
+  if err != nil {
+    return nil, nil, fmt.Errorf("neither PGN nor FEN: %w", err)
+  }
+  board.Chess960 = current.Chess960
+  return []*chess.Board{board}, nil, nil
+}
+
+func (ui *UI) flip(board *chess.Board, arg string) (chess.Move, bool, error) {
+  ui.flipped = !ui.flipped
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) undo(board *chess.Board, arg string) (chess.Move, bool, error) {
+  for _, p := range ui.history {
+    if p.color == board.Turn {
+      return chess.Move{}, true, chess.ErrUndo
+    }
+  }
+  ui.status = "Nothing to take back"
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) offerDraw(board *chess.Board, arg string) (chess.Move, bool, error) {
+  ui.offered = true
+  ui.status = "Draw offered"
+  return chess.Move{}, true, chess.ErrDrawOffer
+}
+
+func (ui *UI) resign(board *chess.Board, arg string) (chess.Move, bool, error) {
+  return chess.Move{}, true, chess.ErrResign
+}
+
+func (ui *UI) help(board *chess.Board, arg string) (chess.Move, bool, error) {
+  ui.notes = []string{"Type a move such as Nf3 or e2e4, or a command:"}
+  for _, name := range commandNames {
+    cmd := commands[name]
+    ui.notes = append(ui.notes, fmt.Sprintf("  %-12s %s", cmd.usage, cmd.help))
+  }
+  return chess.Move{}, false, nil
+}
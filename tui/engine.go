@@ -0,0 +1,132 @@
+package tui
+
+import (
+  "fmt"
+  "time"
+  chess "chess/board"
+  "chess/engine"
+)
+
+// Engine plays a side on the UI. It searches quietly instead of
+// printing like the engine's own GetMove, which would scribble over
+// the screen, and shows what it found on the UI instead.
+type Engine struct {
+  UI *UI
+  Provider engine.AlphaBetaInputProvider
+}
+
+func (e *Engine) GetMove(board *chess.Board) (chess.Move, error) {
+  ui := e.UI
+  ui.mu.Lock()
+  ui.show(board)
+  ui.status = ui.opts.Names[board.Turn] + " is thinking..."
+  ui.render()
+  budget := ui.moveTime(board.Turn)
+  ui.mu.Unlock()
+
+  // keeps the clocks ticking on screen while the search runs, the
+  // board can still be flipped but other keys are dropped
+  done := make(chan struct{})
+  defer close(done)
+  go func() {
+    ticker := time.NewTicker(200 * time.Millisecond)
+    defer ticker.Stop()
+    for {
+      select {
+      case <-done:
+        return
+      case k, ok := <-ui.term.keys:
+        if !ok {
+          return
+        }
+        if k.code == keyTab {
+          ui.mu.Lock()
+          ui.flipped = !ui.flipped
+          ui.render()
+          ui.mu.Unlock()
+        }
+      case <-ticker.C:
+        ui.mu.Lock()
+        ui.render()
+        ui.mu.Unlock()
+      }
+    }
+  }()
+
+  provider := e.Provider
+  if budget > 0 && (provider.Limits.Time == 0 || budget < provider.Limits.Time) {
+    provider.Limits.Time = budget
+  }
+  result := provider.Search(board)
+
+  ui.mu.Lock()
+  defer ui.mu.Unlock()
+  ui.status = ""
+  flagged := ui.flagged(board.Turn)
+  if result.Move == (chess.Move{}) {
+    return result.Move, chess.ErrResign
+  }
+  ui.engineInfo = fmt.Sprintf("%s  eval %s  depth %d", board.SAN(result.Move), formatScore(bestLine(result)), result.Depth)
+  if flagged {
+    return chess.Move{}, chess.ErrTimeout
+  }
+  return result.Move, nil
+}
+
+// the engine takes a draw when it thinks it is this much worse off,
+// judged from a search no deeper than drawDepth
+const (
+  drawMargin = 50
+  drawDepth = 4
+)
+
+func (e *Engine) AcceptDraw(board *chess.Board) bool {
+  // board has the side that offered to move, so the engine answers
+  // from the other side's point of view
+  ui := e.UI
+  ui.mu.Lock()
+  ui.status = ui.opts.Names[board.Turn.Other()] + " is considering the draw offer..."
+  ui.render()
+  budget := ui.moveTime(board.Turn.Other())
+  ui.mu.Unlock()
+
+  // a rough score is enough, so the search is kept short and within
+  // the time a move would get
+  provider := e.Provider
+  if budget > 0 && (provider.Limits.Time == 0 || budget < provider.Limits.Time) {
+    provider.Limits.Time = budget
+  }
+  depth := provider.SearchDepth
+  if provider.Limits.Depth > 0 {
+    depth = provider.Limits.Depth
+  }
+  if depth <= 0 || depth > drawDepth {
+    provider.Limits.Depth = drawDepth
+  }
+  score := provider.Search(board).Score
+  if board.Turn == chess.White {
+    score = -score
+  }
+  return score <= -drawMargin
+}
+
+func (ui *UI) moveTime(color chess.Color) time.Duration {
+  // a share of what is left on the clock, 0 without a time control
+  if ui.opts.Time == 0 {
+    return 0
+  }
+  left := ui.clock(color)
+  return max(left/30+ui.opts.Increment*3/4, 50*time.Millisecond)
+}
+
+func formatScore(line engine.PVLine) string {
+  // pawns from white's view, or #n for a mate in n moves
+  if engine.IsMate(line.Score) {
+    sign := ""
+    if line.Score < 0 {
+      sign = "-"
+    }
+    return fmt.Sprintf("#%s%d", sign, (engine.MatePlies(line.Score)+1)/2)
+  }
+  return fmt.Sprintf("%+.2f", float64(line.Score)/100)
+}
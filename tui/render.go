@@ -0,0 +1,278 @@
+package tui
+
+import (
+  "fmt"
+  "math/bits"
+  "os"
+  "strings"
+  "time"
+  chess "chess/board"
+)
+
+// Screen layout, rows and columns count from 1. The board takes the
+// left, players, clocks and captured material sit beside it and the
+// move list runs down the right. The status, input and key help lines
+// go below the board.
+const (
+  boardRow = 2
+  boardCol = 4
+  infoCol = 32
+  movesCol = 56
+  statusRow = 12
+  inputRow = 13
+  helpRow = 15
+  notesRow = 18
+)
+
+// 256 colour palette
+const (
+  lightSquare = 223
+  darkSquare = 173
+  lightLastMove = 186
+  darkLastMove = 142
+  checkSquare = 160
+  cursorSquare = 75
+  selectedSquare = 71
+  targetSquare = 108
+  whitePiece = 231
+  blackPiece = 16
+)
+
+var glyphs = [7]string{"", "♟", "♞", "♝", "♜", "♛", "♚"}
+
+// material values for the captured count
+var pieceValues = [7]int{0, 1, 3, 3, 5, 9, 0}
+
+func (ui *UI) render() {
+  // redraws the whole screen in one write, each row cleared first so
+  // nothing is left over from the last frame
+  if ui.board == nil {
+    return
+  }
+  rows, _ := ui.term.size()
+  var s strings.Builder
+  s.WriteString(reset)
+  line := func(row, col int, text string) {
+    s.WriteString(moveTo(row, col) + text + reset)
+  }
+  for row := 1; row <= rows; row++ {
+    s.WriteString(moveTo(row, 1) + clearLine)
+  }
+  title := "Chess"
+  if ui.board.Variant != nil {
+    title += " - " + ui.board.Variant.Name()
+  } else if ui.board.Chess960 {
+    title += " - chess960"
+  }
+  line(1, boardCol, "\x1b[1m"+title)
+
+  ui.renderBoard(line)
+  ui.renderInfo(line)
+  ui.renderMoves(line, rows)
+
+  line(statusRow, boardCol-2, ui.status)
+  if ui.awaiting {
+    line(inputRow, boardCol-2, "move: "+ui.input+"\x1b[7m \x1b[0m")
+  }
+  line(helpRow, boardCol-2, "\x1b[2marrows and enter move a piece, tab flips")
+  line(helpRow+1, boardCol-2, "\x1b[2mor type a move in SAN, help for commands")
+  for i, note := range wrapNotes(ui.notes, movesCol-boardCol) {
+    if notesRow+i > rows {
+      break
+    }
+    line(notesRow+i, boardCol-2, note)
+  }
+  os.Stdout.WriteString(s.String())
+}
+
+func (ui *UI) renderBoard(line func(row, col int, text string)) {
+  var last chess.Move
+  hasLast := len(ui.history) > 0
+  if hasLast {
+    last = ui.history[len(ui.history)-1].move
+  }
+  king := chess.Square(64)
+  if ui.check {
+    if kings := ui.board.PieceBB[ui.board.Turn][chess.Kings]; kings != 0 {
+      king = chess.Square(bits.TrailingZeros64(uint64(kings)))
+    }
+  }
+  targets := map[chess.Square]bool{}
+  for _, move := range ui.targets {
+    targets[move.End] = true
+  }
+
+  for row := 0; row < 8; row++ {
+    var s strings.Builder
+    for col := 0; col < 8; col++ {
+      sq := ui.squareAt(row, col)
+      light := (int(sq/8)+int(sq%8))%2 == 1
+      bg := darkSquare
+      if light {
+        bg = lightSquare
+      }
+      switch {
+      case ui.awaiting && sq == ui.cursor:
+        bg = cursorSquare
+      case ui.selected && sq == ui.from:
+        bg = selectedSquare
+      case targets[sq]:
+        bg = targetSquare
+      case sq == king:
+        bg = checkSquare
+      case hasLast && (sq == last.End || (last.Drop == chess.Empty && sq == last.Start)):
+        bg = darkLastMove
+        if light {
+          bg = lightLastMove
+        }
+      }
+      glyph, fg := " ", whitePiece
+      if p := ui.board.GetPieceAt(sq, chess.White); p != chess.Empty {
+        glyph = glyphs[p]
+      } else if p := ui.board.GetPieceAt(sq, chess.Black); p != chess.Empty {
+        glyph, fg = glyphs[p], blackPiece
+      }
+      s.WriteString(colors(fg, bg) + " " + glyph + " ")
+    }
+    rank := 8 - row
+    if ui.flipped {
+      rank = row + 1
+    }
+    line(boardRow+row, boardCol-2, fmt.Sprintf("%d ", rank)+s.String())
+  }
+  var files strings.Builder
+  for col := 0; col < 8; col++ {
+    file := 'a' + col
+    if ui.flipped {
+      file = 'h' - col
+    }
+    files.WriteString(fmt.Sprintf(" %c ", file))
+  }
+  line(boardRow+8, boardCol, files.String())
+}
+
+func (ui *UI) renderInfo(line func(row, col int, text string)) {
+  // the player at the top of the board is shown at the top
+  top, bottom := chess.Black, chess.White
+  if ui.flipped {
+    top, bottom = bottom, top
+  }
+  captured, material := ui.captured()
+  player := func(row int, color chess.Color) {
+    marker := "  "
+    if ui.board.Turn == color && !ui.turnStart.IsZero() {
+      marker = "\x1b[1m> "
+    }
+    line(row, infoCol, marker+fmt.Sprintf("%-12s %s", ui.opts.Names[color], formatClock(ui.clock(color))))
+    taken := captured[color]
+    if material[color] > material[color.Other()] {
+      taken += fmt.Sprintf(" +%d", material[color]-material[color.Other()])
+    }
+    line(row+1, infoCol+2, taken)
+    if hand := pocket(ui.board, color); hand != "" {
+      line(row+2, infoCol+2, "hand "+hand)
+    }
+  }
+  player(boardRow, top)
+  player(boardRow+5, bottom)
+  if ui.engineInfo != "" {
+    line(boardRow+8, infoCol, "\x1b[2m"+ui.engineInfo)
+  }
+}
+
+func (ui *UI) captured() ([2]string, [2]int) {
+  // the pieces each side has taken, most valuable first, and their
+  // total value
+  var counts [2][7]int
+  var material [2]int
+  for _, p := range ui.history {
+    if p.captured != chess.Empty {
+      counts[p.color][p.captured]++
+      material[p.color] += pieceValues[p.captured]
+    }
+  }
+  var text [2]string
+  for color := range counts {
+    for p := chess.Queens; p >= chess.Pawns; p-- {
+      text[color] += strings.Repeat(glyphs[p], counts[color][p])
+    }
+  }
+  return text, material
+}
+
+func pocket(board *chess.Board, color chess.Color) string {
+  // Crazyhouse pieces in hand
+  var s strings.Builder
+  for p := chess.Queens; p >= chess.Pawns; p-- {
+    if n := board.Pockets[color][p]; n > 0 {
+      s.WriteString(fmt.Sprintf("%s%d ", glyphs[p], n))
+    }
+  }
+  return strings.TrimSpace(s.String())
+}
+
+func wrapNotes(notes []string, width int) []string {
+  // breaks long lines at spaces, runes counted as one column each
+  var lines []string
+  for _, note := range notes {
+    if len([]rune(note)) <= width {
+      lines = append(lines, note)
+      continue
+    }
+    current := ""
+    for _, word := range strings.Split(note, " ") {
+      if current != "" && len([]rune(current))+1+len([]rune(word)) > width {
+        lines = append(lines, current)
+        current = "  " + word
+        continue
+      }
+      if current == "" {
+        current = word
+      } else {
+        current += " " + word
+      }
+    }
+    lines = append(lines, current)
+  }
+  return lines
+}
+
+func formatClock(d time.Duration) string {
+  if d < 0 {
+    d = 0
+  }
+  d = d.Truncate(time.Second)
+  if d >= time.Hour {
+    return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+  }
+  return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func (ui *UI) renderMoves(line func(row, col int, text string), rows int) {
+  // numbered pairs in SAN, scrolled to keep the latest move in view
+  var lines []string
+  ply := ui.startPly
+  for i := 0; i < len(ui.history); i++ {
+    number := ply/2 + 1
+    if ply%2 == 1 {
+      lines = append(lines, fmt.Sprintf("%3d. %-8s %s", number, "...", ui.history[i].san))
+      ply++
+      continue
+    }
+    black := ""
+    if i+1 < len(ui.history) {
+      black = ui.history[i+1].san
+    }
+    lines = append(lines, fmt.Sprintf("%3d. %-8s %s", number, ui.history[i].san, black))
+    i++
+    ply += 2
+  }
+  space := max(rows-boardRow, 1)
+  if len(lines) > space {
+    lines = lines[len(lines)-space:]
+  }
+  line(1, movesCol, "\x1b[1mMoves")
+  for i, text := range lines {
+    line(boardRow+i, movesCol, text)
+  }
+}
@@ -0,0 +1,147 @@
+package tui
+
+import (
+  "fmt"
+  "os"
+  "os/exec"
+  "strings"
+  "unicode/utf8"
+)
+
+// The terminal is driven with ANSI escape codes, and stty switches
+// off line buffering and echo so keys arrive as they are pressed.
+
+const (
+  altScreen = "\x1b[?1049h"
+  mainScreen = "\x1b[?1049l"
+  hideCursor = "\x1b[?25l"
+  showCursor = "\x1b[?25h"
+  clearScreen = "\x1b[2J"
+  clearLine = "\x1b[K"
+  reset = "\x1b[0m"
+)
+
+type keyCode int
+
+const (
+  keyRune keyCode = iota
+  keyUp
+  keyDown
+  keyLeft
+  keyRight
+  keyEnter
+  keyEscape
+  keyBackspace
+  keyTab
+)
+
+type key struct {
+  code keyCode
+  r rune // for keyRune
+}
+
+type terminal struct {
+  saved string // stty settings to go back to
+  keys chan key
+}
+
+func openTerminal() (*terminal, error) {
+  saved, err := stty("-g")
+  if err != nil {
+    return nil, fmt.Errorf("the terminal UI needs a terminal: %w", err)
+  }
+  if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+    return nil, err
+  }
+  t := &terminal{saved: strings.TrimSpace(saved), keys: make(chan key, 16)}
+  os.Stdout.WriteString(altScreen + hideCursor + clearScreen)
+  go t.readKeys()
+  return t, nil
+}
+
+func (t *terminal) restore() {
+  os.Stdout.WriteString(reset + showCursor + mainScreen)
+  stty(t.saved)
+}
+
+func stty(args ...string) (string, error) {
+  cmd := exec.Command("stty", args...)
+  cmd.Stdin = os.Stdin
+  out, err := cmd.Output()
+  return string(out), err
+}
+
+func (t *terminal) size() (rows, cols int) {
+  // falls back to 24x80 when stty can not tell
+  out, err := stty("size")
+  if err != nil {
+    return 24, 80
+  }
+  if _, err := fmt.Sscan(out, &rows, &cols); err != nil || rows == 0 || cols == 0 {
+    return 24, 80
+  }
+  return rows, cols
+}
+
+func (t *terminal) readKeys() {
+  // decodes stdin into keys until it is closed
+  defer close(t.keys)
+  buf := make([]byte, 64)
+  for {
+    n, err := os.Stdin.Read(buf)
+    if err != nil {
+      return
+    }
+    for data := buf[:n]; len(data) > 0; {
+      k, size := decodeKey(data)
+      data = data[size:]
+      t.keys <- k
+    }
+  }
+}
+
+func decodeKey(data []byte) (key, int) {
+  // the first key in data and the number of bytes it took
+  switch data[0] {
+  case 0x1b:
+    if len(data) >= 3 && (data[1] == '[' || data[1] == 'O') {
+      switch data[2] {
+      case 'A':
+        return key{code: keyUp}, 3
+      case 'B':
+        return key{code: keyDown}, 3
+      case 'C':
+        return key{code: keyRight}, 3
+      case 'D':
+        return key{code: keyLeft}, 3
+      }
+      return key{code: keyEscape}, len(data)
+    }
+    return key{code: keyEscape}, 1
+  case '\r', '\n':
+    return key{code: keyEnter}, 1
+  case 0x7f, 0x08:
+    return key{code: keyBackspace}, 1
+  case '\t':
+    return key{code: keyTab}, 1
+  }
+  r, size := utf8.DecodeRune(data)
+  return key{code: keyRune, r: r}, size
+}
+
+func moveTo(row, col int) string {
+  // rows and columns count from 1
+  return fmt.Sprintf("\x1b[%d;%dH", row, col)
+}
+
+func colors(fg, bg int) string {
+  // 256 colour foreground and background, -1 leaves one as it is
+  s := ""
+  if fg >= 0 {
+    s += fmt.Sprintf("\x1b[38;5;%dm", fg)
+  }
+  if bg >= 0 {
+    s += fmt.Sprintf("\x1b[48;5;%dm", bg)
+  }
+  return s
+}
@@ -1,83 +1,445 @@
 package tui
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"strings"
-	chess "chess/board"
+  "strings"
+  "sync"
+  "time"
+  chess "chess/board"
+  "chess/engine"
 )
 
-type InputProvider struct {}
+// A full screen terminal UI. One UI is both the OutputHandler for
+// every player, so it sees each position the game loop shows, and the
+// InputProvider of the people at the keyboard. Moves are made by
+// moving the cursor with the arrow keys and pressing enter on a piece
+// and then on its destination, or by typing them in SAN or long
+// algebraic notation. Typed commands are in commands.go.
 
-func playTUI() {
-	var board *chess.Board = chess.NewBoard()
-  provider1 := InputProvider{}
-  provider2 := InputProvider{}
-  handler1 := OutputHandler{}
-  handler2 := OutputHandler{}
-  config := chess.GameConfig{}
-  result, err := chess.CoreGameplayLoop(board, config, provider1, provider2, handler1, handler2)
+type Options struct {
+  Names [2]string // shown next to the clocks, by colour
+  Time time.Duration // on each clock, 0 counts time used instead
+  Increment time.Duration
+  Flip bool // black at the bottom
+  Analysis engine.AlphaBetaInputProvider // for hint and eval, depth 4 when unset
+}
+
+type played struct {
+  before *chess.Board
+  move chess.Move
+  san string
+  color chess.Color
+  captured chess.Piece
+}
+
+type UI struct {
+  mu sync.Mutex
+  term *terminal
+  opts Options
+
+  board *chess.Board // the position on screen
+  start *chess.Board // where history starts
+  startPly int // TotalMoves of start, for move numbers
+  history []played
+  flipped bool
+  check bool
+
+  // selection, only while a human is to move
+  awaiting bool
+  legal []chess.Move
+  cursor chess.Square
+  selected bool
+  from chess.Square
+  targets []chess.Move // legal moves of the selected piece
+  promoting []chess.Move // waiting for the piece to promote to
+  input string // typed move
+
+  status string
+  notes []string // output of commands, below the board
+  engineInfo string
+  offered bool // a draw offer is waiting for an answer
+
+  clocks [2]time.Duration // time left, or used without a time control
+  turnStart time.Time
+}
+
+func New(opts Options) (*UI, error) {
+  // takes over the terminal until Close
+  term, err := openTerminal()
   if err != nil {
-    println(err)
+    return nil, err
+  }
+  if opts.Names[chess.White] == "" {
+    opts.Names[chess.White] = "White"
+  }
+  if opts.Names[chess.Black] == "" {
+    opts.Names[chess.Black] = "Black"
+  }
+  ui := &UI{
+    term: term,
+    opts: opts,
+    flipped: opts.Flip,
+    cursor: chess.NotationToIndex("e2"),
+    clocks: [2]time.Duration{opts.Time, opts.Time},
+  }
+  if opts.Flip {
+    ui.cursor = chess.NotationToIndex("e7")
+  }
+  return ui, nil
+}
+
+func (ui *UI) Close() {
+  ui.term.restore()
+}
+
+// InputProvider lets the person at the keyboard play a side.
+type InputProvider struct {
+  ui *UI
+}
+
+// OutputHandler shows the game on the UI.
+type OutputHandler struct {
+  ui *UI
+}
+
+func (ui *UI) Input() *InputProvider {
+  return &InputProvider{ui}
+}
+
+func (ui *UI) Output() *OutputHandler {
+  return &OutputHandler{ui}
+}
+
+func (h *OutputHandler) DisplayBoard(board *chess.Board) {
+  h.ui.mu.Lock()
+  defer h.ui.mu.Unlock()
+  h.ui.show(board)
+  h.ui.render()
+}
+
+func (h *OutputHandler) DisplayCheck() {
+  h.ui.mu.Lock()
+  defer h.ui.mu.Unlock()
+  h.ui.check = true
+  h.ui.render()
+}
+
+func samePosition(a, b *chess.Board) bool {
+  return a.PieceBB == b.PieceBB && a.Turn == b.Turn && a.TotalMoves == b.TotalMoves && a.Pockets == b.Pockets
+}
+
+func (ui *UI) show(board *chess.Board) {
+  // takes a new position from the game loop, working out which move
+  // led to it for the move list and charging the mover's clock
+  if ui.board != nil && samePosition(ui.board, board) {
     return
   }
-  if result.Draw {
-    fmt.Printf("Draw by %s", result.Reason)
-  } else if result.Winner == 0{
-    fmt.Printf("White wins by %s", result.Reason)
+  now := time.Now()
+  if ui.board == nil {
+    ui.reset(board)
+  } else if i := ui.takenBack(board); i >= 0 {
+    ui.charge(ui.board.Turn, now)
+    ui.history = ui.history[:i]
+  } else if move, ok := findMove(ui.board, board); ok {
+    mover := ui.board.Turn
+    ui.record(ui.board, move)
+    ui.charge(mover, now)
+    ui.clocks[mover] += ui.increment()
   } else {
-    fmt.Printf("Black wins by %s", result.Reason)
-  }
-}
-
-var pieceMap = map[string]chess.Piece{
-	"Pawn": chess.Pawns, "Pawns": chess.Pawns, "pawn": chess.Pawns, "pawns": chess.Pawns,
-	"Knight": chess.Knights, "Knights": chess.Knights, "knight": chess.Knights, "knights": chess.Knights,
-	"Bishop": chess.Bishops, "Bishops": chess.Bishops, "bishop": chess.Bishops, "bishops": chess.Bishops,
-	"Rook": chess.Rooks, "Rooks": chess.Rooks, "rook": chess.Rooks, "rooks": chess.Rooks,
-	"Queen": chess.Queens, "Queens": chess.Queens, "queen": chess.Queens, "queens": chess.Queens,
-	"King": chess.Kings, "Kings": chess.Kings, "king": chess.Kings, "kings": chess.Kings,
-}
-
-func (t InputProvider) GetMove(board *chess.Board) (chess.Move, error) {
-	fmt.Println("Please input move.")
-  println(board.Turn)
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	var start chess.Square
-	var end chess.Square
-	var promotion chess.Piece
-	if err != nil {
-		fmt.Printf("Error reading input: %v\n", err)
-		return chess.Move{start, end, promotion}, nil
-	}
-	input = strings.TrimSpace(input)
-	move := strings.Split(input, " ")
-	if input == "resign" || input == "Resign" {
-		return chess.Move{start, end, promotion}, chess.ErrResign
-	}
-	if len(move) < 2 || len(move) > 3 {
-		fmt.Println("Please provide at two words if not promoting, and three words if promoting.")
-		return chess.Move{start, end, promotion}, nil
-	}
-	if len(move) == 3 {
-		promotion = pieceMap[move[2]]
-	} else {
-		promotion = chess.Empty
-	}
-	start = chess.NotationToIndex(move[0])
-	end = chess.NotationToIndex(move[1])
-	return chess.Move{start, end, promotion}, nil
-}
-
-type OutputHandler struct {}
-
-func (handler OutputHandler) DisplayBoard(board *chess.Board) {
-  println(board.PrintBoard())
-}
-
-func (handler OutputHandler) DisplayCheck() {
-  println("Check!")
+    // not one move on, a new game or a position set up by hand
+    ui.reset(board)
+  }
+  ui.board = board.Clone()
+  ui.turnStart = now
+  ui.check = board.IsCheck(board.Turn)
+  ui.selected = false
+  ui.targets = nil
+  ui.promoting = nil
+  ui.notes = nil
+}
+
+func (ui *UI) reset(board *chess.Board) {
+  ui.history = nil
+  ui.start = board.Clone()
+  ui.startPly = int(board.TotalMoves)
 }
 
+func (ui *UI) record(before *chess.Board, move chess.Move) {
+  // adds move, played from before, to the history
+  captured := chess.Empty
+  if before.IsCapture(move) {
+    captured = before.GetPieceAt(move.End, before.Turn.Other())
+    if captured == chess.Empty {
+      captured = chess.Pawns // en passant
+    }
+  }
+  ui.history = append(ui.history, played{before.Clone(), move, before.SAN(move), before.Turn, captured})
+}
+
+func (ui *UI) takenBack(board *chess.Board) int {
+  // the number of moves still played if board is an earlier position
+  // of the game, -1 if it is not
+  for i := len(ui.history) - 1; i >= 0; i-- {
+    if samePosition(ui.history[i].before, board) {
+      return i
+    }
+  }
+  return -1
+}
+
+func findMove(prev, next *chess.Board) (chess.Move, bool) {
+  for _, move := range prev.GetAllLegalMoves(prev.Turn) {
+    child := prev.Clone()
+    child.PlayMove(move)
+    if child.PieceBB == next.PieceBB && child.Turn == next.Turn && child.Pockets == next.Pockets {
+      return move, true
+    }
+  }
+  return chess.Move{}, false
+}
+
+func (ui *UI) increment() time.Duration {
+  if ui.opts.Time == 0 {
+    return 0
+  }
+  return ui.opts.Increment
+}
+
+func (ui *UI) charge(color chess.Color, now time.Time) {
+  // counts the time since the turn started against color's clock
+  if ui.turnStart.IsZero() {
+    return
+  }
+  if ui.opts.Time > 0 {
+    ui.clocks[color] -= now.Sub(ui.turnStart)
+  } else {
+    ui.clocks[color] += now.Sub(ui.turnStart)
+  }
+}
+
+func (ui *UI) clock(color chess.Color) time.Duration {
+  // the clock as it stands now, running for the side to move
+  t := ui.clocks[color]
+  if ui.board != nil && ui.board.Turn == color && !ui.turnStart.IsZero() {
+    if ui.opts.Time > 0 {
+      t -= time.Since(ui.turnStart)
+    } else {
+      t += time.Since(ui.turnStart)
+    }
+  }
+  return t
+}
+
+func (ui *UI) flagged(color chess.Color) bool {
+  return ui.opts.Time > 0 && ui.clock(color) <= 0
+}
+
+func (p *InputProvider) GetMove(board *chess.Board) (chess.Move, error) {
+  ui := p.ui
+  ui.mu.Lock()
+  ui.show(board)
+  if ui.offered {
+    ui.offered = false
+    ui.status = "Draw offer declined"
+  }
+  ui.awaiting = true
+  ui.legal = board.GetAllLegalMoves(board.Turn)
+  ui.render()
+  ui.mu.Unlock()
+  defer func() {
+    ui.mu.Lock()
+    ui.awaiting = false
+    ui.mu.Unlock()
+  }()
+
+  ticker := time.NewTicker(200 * time.Millisecond)
+  defer ticker.Stop()
+  for {
+    select {
+    case k, ok := <-ui.term.keys:
+      if !ok {
+        // stdin is gone, nobody is left to play
+        return chess.Move{}, chess.ErrResign
+      }
+      ui.mu.Lock()
+      move, done, err := ui.handleKey(board, k)
+      ui.render()
+      ui.mu.Unlock()
+      if done {
+        return move, err
+      }
+    case <-ticker.C:
+      ui.mu.Lock()
+      flagged := ui.flagged(board.Turn)
+      ui.render()
+      ui.mu.Unlock()
+      if flagged {
+        return chess.Move{}, chess.ErrTimeout
+      }
+    }
+  }
+}
+
+func (ui *UI) handleKey(board *chess.Board, k key) (chess.Move, bool, error) {
+  // returns the move once one is chosen
+  ui.status = ""
+  if len(ui.promoting) > 0 {
+    return ui.choosePromotion(k)
+  }
+  switch k.code {
+  case keyUp:
+    ui.moveCursor(-1, 0)
+  case keyDown:
+    ui.moveCursor(1, 0)
+  case keyLeft:
+    ui.moveCursor(0, -1)
+  case keyRight:
+    ui.moveCursor(0, 1)
+  case keyTab:
+    ui.flipped = !ui.flipped
+  case keyEscape:
+    ui.selected = false
+    ui.targets = nil
+    ui.input = ""
+    ui.notes = nil
+  case keyBackspace:
+    if n := len(ui.input); n > 0 {
+      ui.input = ui.input[:n-1]
+    }
+  case keyRune:
+    if k.r == ' ' && ui.input == "" {
+      return ui.pick(board)
+    }
+    ui.input += string(k.r)
+  case keyEnter:
+    if ui.input == "" {
+      return ui.pick(board)
+    }
+    text := strings.TrimSpace(ui.input)
+    ui.input = ""
+    return ui.typed(board, text)
+  }
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) moveCursor(rows, cols int) {
+  // moves the cursor on screen, whichever way up the board is
+  row, col := ui.screenPosition(ui.cursor)
+  row = min(max(row+rows, 0), 7)
+  col = min(max(col+cols, 0), 7)
+  ui.cursor = ui.squareAt(row, col)
+}
+
+func (ui *UI) screenPosition(sq chess.Square) (row, col int) {
+  rank, file := int(sq/8), int(sq%8)
+  if ui.flipped {
+    return rank, 7 - file
+  }
+  return 7 - rank, file
+}
+
+func (ui *UI) squareAt(row, col int) chess.Square {
+  if ui.flipped {
+    return chess.Square(row*8 + 7 - col)
+  }
+  return chess.Square((7-row)*8 + col)
+}
+
+func (ui *UI) pick(board *chess.Board) (chess.Move, bool, error) {
+  // enter on a square: selects a piece, or moves the selected one
+  if ui.selected {
+    var moves []chess.Move
+    for _, move := range ui.targets {
+      if move.End == ui.cursor {
+        moves = append(moves, move)
+      }
+    }
+    switch {
+    case len(moves) == 1:
+      return moves[0], true, nil
+    case len(moves) > 1:
+      ui.promoting = moves
+      ui.status = "Promote to (q)ueen, (r)ook, (b)ishop or k(n)ight"
+      return chess.Move{}, false, nil
+    }
+  }
+  ui.selected = false
+  ui.targets = nil
+  for _, move := range ui.legal {
+    if move.Drop == chess.Empty && move.Start == ui.cursor {
+      ui.targets = append(ui.targets, move)
+    }
+  }
+  if len(ui.targets) > 0 {
+    ui.selected = true
+    ui.from = ui.cursor
+  }
+  return chess.Move{}, false, nil
+}
+
+func (ui *UI) choosePromotion(k key) (chess.Move, bool, error) {
+  pieces := map[rune]chess.Piece{'q': chess.Queens, 'r': chess.Rooks, 'b': chess.Bishops, 'n': chess.Knights, 'k': chess.Kings}
+  if k.code == keyEnter {
+    k = key{code: keyRune, r: 'q'}
+  }
+  if k.code == keyRune {
+    for _, move := range ui.promoting {
+      if move.Promotion == pieces[k.r] {
+        ui.promoting = nil
+        return move, true, nil
+      }
+    }
+  }
+  if k.code == keyEscape {
+    ui.promoting = nil
+    return chess.Move{}, false, nil
+  }
+  ui.status = "Promote to (q)ueen, (r)ook, (b)ishop or k(n)ight"
+  return chess.Move{}, false, nil
+}
+
+func (p *InputProvider) AcceptDraw(board *chess.Board) bool {
+  // asks the person at the keyboard about the opponent's offer
+  ui := p.ui
+  ui.mu.Lock()
+  ui.status = ui.opts.Names[board.Turn] + " offers a draw. Accept? (y/n)"
+  ui.render()
+  ui.mu.Unlock()
+  for k := range ui.term.keys {
+    if k.code == keyEscape || (k.code == keyRune && (k.r == 'n' || k.r == 'N')) {
+      break
+    }
+    if k.code == keyRune && (k.r == 'y' || k.r == 'Y') {
+      return true
+    }
+  }
+  ui.mu.Lock()
+  ui.status = ""
+  ui.mu.Unlock()
+  return false
+}
+
+func (ui *UI) SetStatus(status string) {
+  ui.mu.Lock()
+  defer ui.mu.Unlock()
+  ui.status = status
+  ui.render()
+}
+
+func (ui *UI) ShowResult(result chess.GameResult) {
+  // shows how the game ended and waits for a key
+  ui.mu.Lock()
+  ui.awaiting = false
+  switch {
+  case result.Draw:
+    ui.status = "Draw by " + result.Reason
+  case result.Winner == chess.White:
+    ui.status = "White wins by " + result.Reason
+  default:
+    ui.status = "Black wins by " + result.Reason
+  }
+  ui.status += ". Press any key."
+  ui.turnStart = time.Time{}
+  ui.render()
+  ui.mu.Unlock()
+  <-ui.term.keys
+}
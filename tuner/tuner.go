@@ -0,0 +1,186 @@
+package tuner
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "runtime"
+  "strings"
+  "sync"
+  chess "chess/board"
+)
+
+// Texel tuning. Every position is labelled with the result of the
+// game it came from and the weights are fitted so that a sigmoid of
+// the evaluation predicts those results as well as possible.
+
+type Position struct {
+  Features *chess.EvalFeatures
+  Result float64 // 1 white won, 0.5 draw, 0 black won
+}
+
+type Options struct {
+  Iterations int // passes over every weight, 0 runs until nothing improves
+  Step int // how far a weight is moved per try
+  Log io.Writer // progress, nil for none
+}
+
+func LoadPositions(path string) ([]Position, error) {
+  // Reads one labelled position per line. The result can follow the
+  // FEN as 1-0, 0-1, 1/2-1/2 or as 1.0, 0.5, 0.0, optionally quoted,
+  // in brackets or after a semicolon. Lines starting with # and
+  // positions with no legal moves are skipped.
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open positions file: %w", err)
+  }
+  defer file.Close()
+
+  var positions []Position
+  scanner := bufio.NewScanner(file)
+  lineNumber := 0
+  for scanner.Scan() {
+    lineNumber++
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    fen, result, err := ParseLabelledLine(line)
+    if err != nil {
+      return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+    }
+    board, err := chess.NewBoardFromFEN(fen)
+    if err != nil {
+      return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+    }
+    if len(board.GetAllLegalMoves(board.Turn)) == 0 {
+      continue
+    }
+    positions = append(positions, Position{chess.NewEvalFeatures(board), result})
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, fmt.Errorf("failed to read positions file: %w", err)
+  }
+  return positions, nil
+}
+
+func ParseLabelledLine(line string) (string, float64, error) {
+  line = strings.ReplaceAll(line, ";", " ")
+  fields := strings.Fields(line)
+  if len(fields) < 3 {
+    return "", 0, fmt.Errorf("expected a FEN followed by a result")
+  }
+  label := strings.Trim(fields[len(fields)-1], "\"[]()")
+  var result float64
+  switch label {
+  case "1-0", "1.0", "1":
+    result = 1
+  case "0-1", "0.0", "0":
+    result = 0
+  case "1/2-1/2", "0.5", "1/2":
+    result = 0.5
+  default:
+    return "", 0, fmt.Errorf("unknown result %q", label)
+  }
+  // drop anything between the FEN and the result, like an opcode
+  fenFields := fields[:len(fields)-1]
+  if len(fenFields) > 6 {
+    fenFields = fenFields[:6]
+  }
+  for i := 4; i < len(fenFields); i++ {
+    if _, err := fmt.Sscanf(fenFields[i], "%d", new(int)); err != nil {
+      fenFields = fenFields[:i]
+      break
+    }
+  }
+  return strings.Join(fenFields, " "), result, nil
+}
+
+func sigmoid(score, k float64) float64 {
+  return 1 / (1 + math.Pow(10, -k*score/400))
+}
+
+func Loss(params *chess.EvalParams, positions []Position, k float64) float64 {
+  // mean squared error between the results and the predictions,
+  // split over every CPU
+  workers := runtime.NumCPU()
+  sums := make([]float64, workers)
+  var wg sync.WaitGroup
+  for w := 0; w < workers; w++ {
+    wg.Add(1)
+    go func(w int) {
+      defer wg.Done()
+      for i := w; i < len(positions); i += workers {
+        diff := positions[i].Result - sigmoid(float64(params.Score(positions[i].Features)), k)
+        sums[w] += diff * diff
+      }
+    }(w)
+  }
+  wg.Wait()
+  total := 0.0
+  for _, sum := range sums {
+    total += sum
+  }
+  return total / float64(max(len(positions), 1))
+}
+
+func FindK(params *chess.EvalParams, positions []Position) float64 {
+  // the scaling constant that best fits the current weights, found
+  // by narrowing a grid search
+  best := 1.0
+  bestLoss := Loss(params, positions, best)
+  step := 0.5
+  for i := 0; i < 6; i++ {
+    for _, k := range []float64{best - step, best + step} {
+      if k <= 0 {
+        continue
+      }
+      if loss := Loss(params, positions, k); loss < bestLoss {
+        best, bestLoss = k, loss
+      }
+    }
+    step /= 2
+  }
+  return best
+}
+
+func Tune(start *chess.EvalParams, positions []Position, opts Options) *chess.EvalParams {
+  // Local search: every weight in turn is nudged up and then down by
+  // Step and the change is kept if the loss drops. Passes repeat
+  // until one improves nothing or Iterations is reached.
+  params := *start
+  step := max(opts.Step, 1)
+  k := FindK(&params, positions)
+  bestLoss := Loss(&params, positions, k)
+  logf(opts.Log, "k %.4f  loss %.6f  positions %d\n", k, bestLoss, len(positions))
+
+  weights := params.Weights()
+  for pass := 1; opts.Iterations == 0 || pass <= opts.Iterations; pass++ {
+    improved := 0
+    for _, w := range weights {
+      original := *w
+      for _, delta := range []int{step, -step} {
+        *w = original + delta
+        if loss := Loss(&params, positions, k); loss < bestLoss {
+          bestLoss = loss
+          improved++
+          break
+        }
+        *w = original
+      }
+    }
+    logf(opts.Log, "pass %d  loss %.6f  changed %d\n", pass, bestLoss, improved)
+    if improved == 0 {
+      break
+    }
+  }
+  return &params
+}
+
+func logf(w io.Writer, format string, args ...any) {
+  if w != nil {
+    fmt.Fprintf(w, format, args...)
+  }
+}
@@ -0,0 +1,407 @@
+package uci
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+  nnue "chess/nnue"
+  variant "chess/variant"
+)
+
+const (
+  defaultDepth = 5
+  infiniteDepth = engine.MaxDepth
+)
+
+// Engine speaks the UCI protocol on top of AlphaBetaInputProvider.
+// Searches run on their own goroutine so stop can interrupt them.
+type Engine struct {
+  board *chess.Board
+  options engine.AlphaBetaInputProvider
+  searching sync.WaitGroup
+  searchMu sync.Mutex
+  stop *atomic.Bool // the running search's, nil before the first go
+  hold chan struct{} // bestmove waits until this is closed, nil when it need not
+  pondering bool // go ponder until ponderhit
+  infinite bool // go infinite, bestmove waits for stop
+  ponderTime time.Duration // the time limit, counted from ponderhit
+  timer *time.Timer // the time limit after ponderhit
+  out io.Writer
+  outMu sync.Mutex
+  chess960 bool // UCI_Chess960, castling moves are sent as king takes rook
+  rules chess.Variant // UCI_Variant, nil for normal chess
+}
+
+func NewEngine(out io.Writer) *Engine {
+  e := &Engine{
+    board: chess.NewBoard(),
+    out: out,
+  }
+  e.options.SearchDepth = defaultDepth
+  e.options.Threads = 1
+  e.options.MultiPV = 1
+  e.options.TT = engine.NewTranspositionTable(engine.DefaultHashMB)
+  return e
+}
+
+func Run(in io.Reader, out io.Writer) error {
+  e := NewEngine(out)
+  scanner := bufio.NewScanner(in)
+  for scanner.Scan() {
+    if !e.Handle(scanner.Text()) {
+      break
+    }
+  }
+  e.stopSearch()
+  return scanner.Err()
+}
+
+func (e *Engine) printf(format string, args ...any) {
+  e.outMu.Lock()
+  defer e.outMu.Unlock()
+  fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+func (e *Engine) Handle(line string) bool {
+  // handles one command, returns false on quit
+  fields := strings.Fields(line)
+  if len(fields) == 0 {
+    return true
+  }
+  switch fields[0] {
+  case "uci":
+    e.printf("id name chess")
+    e.printf("id author jadotte")
+    e.printf("option name Threads type spin default 1 min 1 max 256")
+    e.printf("option name Hash type spin default %d min 1 max 4096", engine.DefaultHashMB)
+    e.printf("option name MultiPV type spin default 1 min 1 max 256")
+    e.printf("option name Ponder type check default false")
+    e.printf("option name UCI_Chess960 type check default false")
+    e.printf("option name UCI_Variant type combo default chess var %s", strings.Join(variant.Names(), " var "))
+    e.printf("option name EvalFile type string default <empty>")
+    e.printf("option name NNUEFile type string default <empty>")
+    e.printf("uciok")
+  case "isready":
+    e.printf("readyok")
+  case "ucinewgame":
+    e.stopSearch()
+    e.board = variant.NewBoard(e.rules)
+    e.board.Chess960 = e.chess960
+    e.options.TT.Clear()
+  case "setoption":
+    e.stopSearch()
+    e.setOption(fields[1:])
+  case "position":
+    e.stopSearch()
+    if err := e.position(fields[1:]); err != nil {
+      e.printf("info string %v", err)
+    }
+  case "go":
+    e.stopSearch()
+    e.goSearch(fields[1:])
+  case "ponderhit":
+    e.ponderHit()
+  case "stop":
+    e.stopSearch()
+  case "quit":
+    return false
+  }
+  return true
+}
+
+func (e *Engine) setOption(args []string) {
+  // setoption name <name> value <value>
+  var name, value string
+  for i := 0; i+1 < len(args); i++ {
+    if args[i] == "name" {
+      name = args[i+1]
+    } else if args[i] == "value" {
+      value = args[i+1]
+    }
+  }
+  switch strings.ToLower(name) {
+  case "ponder":
+    // nothing to set up, the GUI decides when to send go ponder
+    return
+  case "uci_chess960":
+    e.chess960 = value == "true"
+    return
+  case "uci_variant":
+    v, err := variant.ByName(value)
+    if err != nil {
+      e.printf("info string %v", err)
+      return
+    }
+    e.rules = v
+    return
+  case "evalfile":
+    params, err := chess.LoadEvalParams(value)
+    if err != nil {
+      e.printf("info string %v", err)
+      return
+    }
+    chess.ActiveEvalParams = params
+    return
+  case "nnuefile":
+    // an empty value goes back to the hand written evaluation
+    if value == "" || value == "<empty>" {
+      e.options.Evaluator = nil
+      return
+    }
+    net, err := nnue.LoadNetwork(value)
+    if err != nil {
+      e.printf("info string %v", err)
+      return
+    }
+    e.options.Evaluator = nnue.NewEvaluator(net)
+    return
+  }
+  n, err := strconv.Atoi(value)
+  if err != nil || n < 1 {
+    e.printf("info string invalid value for %s", name)
+    return
+  }
+  switch strings.ToLower(name) {
+  case "threads":
+    e.options.Threads = n
+  case "multipv":
+    e.options.MultiPV = n
+  case "hash":
+    e.options.TT = engine.NewTranspositionTable(n)
+  default:
+    e.printf("info string unknown option %s", name)
+  }
+}
+
+func (e *Engine) position(args []string) error {
+  // position [startpos | fen <fen>] [moves <move> ...]
+  if len(args) == 0 {
+    return fmt.Errorf("position needs startpos or fen")
+  }
+  var board *chess.Board
+  rest := args[1:]
+  switch args[0] {
+  case "startpos":
+    board = variant.NewBoard(e.rules)
+  case "fen":
+    end := len(rest)
+    for i, arg := range rest {
+      if arg == "moves" {
+        end = i
+        break
+      }
+    }
+    b, err := variant.NewBoardFromFEN(e.rules, strings.Join(rest[:end], " "))
+    if err != nil {
+      return err
+    }
+    board = b
+    rest = rest[end:]
+  default:
+    return fmt.Errorf("unknown position type %s", args[0])
+  }
+  // a Chess960 FEN is recognised as such, but a GUI may also send
+  // one that looks like a normal position
+  board.Chess960 = board.Chess960 || e.chess960
+  if len(rest) > 0 && rest[0] == "moves" {
+    for _, str := range rest[1:] {
+      move, err := chess.ParseMove(str)
+      if err != nil {
+        return err
+      }
+      if !board.IsLegal(move) {
+        return fmt.Errorf("illegal move %s", str)
+      }
+      board.PlayMove(move)
+    }
+  }
+  e.board = board
+  return nil
+}
+
+func (e *Engine) goSearch(args []string) {
+  // go [ponder] [infinite] [depth <n>] [nodes <n>] [mate <n>]
+  //    [movetime <ms>] [wtime <ms>] [btime <ms>] [winc <ms>] [binc <ms>]
+  //    [movestogo <n>] [searchmoves <move> ...]
+  // While pondering the search has no time limit and bestmove is
+  // held back until the GUI sends ponderhit or stop. The time limit
+  // only starts on ponderhit. An infinite search holds bestmove back
+  // until stop.
+  options := e.options
+  var limits engine.SearchLimits
+  var clock, increment [2]time.Duration
+  movesToGo := 0
+  ponder, infinite := false, false
+  number := func(i int) int {
+    if i+1 < len(args) {
+      if n, err := strconv.Atoi(args[i+1]); err == nil {
+        return n
+      }
+    }
+    return 0
+  }
+  for i := 0; i < len(args); i++ {
+    switch args[i] {
+    case "ponder":
+      ponder = true
+      continue
+    case "infinite":
+      infinite = true
+      continue
+    case "searchmoves":
+      for i+1 < len(args) {
+        move, err := chess.ParseMove(args[i+1])
+        if err != nil {
+          break
+        }
+        limits.SearchMoves = append(limits.SearchMoves, move)
+        i++
+      }
+      continue
+    }
+    n := number(i)
+    switch args[i] {
+    case "depth":
+      limits.Depth = n
+    case "nodes":
+      limits.Nodes = uint64(max(n, 0))
+    case "mate":
+      limits.Mate = n
+    case "movetime":
+      limits.Time = time.Duration(n) * time.Millisecond
+    case "wtime":
+      clock[chess.White] = time.Duration(n) * time.Millisecond
+    case "btime":
+      clock[chess.Black] = time.Duration(n) * time.Millisecond
+    case "winc":
+      increment[chess.White] = time.Duration(n) * time.Millisecond
+    case "binc":
+      increment[chess.Black] = time.Duration(n) * time.Millisecond
+    case "movestogo":
+      movesToGo = n
+    default:
+      continue
+    }
+    i++
+  }
+  turn := e.board.Turn
+  if limits.Time == 0 && clock[turn] > 0 && !infinite {
+    // spread the clock over the moves left, assuming 30 when the GUI
+    // does not say, and keep a margin so the flag never falls
+    if movesToGo <= 0 {
+      movesToGo = 30
+    }
+    limits.Time = min(clock[turn]/time.Duration(movesToGo)+increment[turn]*3/4, clock[turn]/2)
+  }
+  if infinite {
+    options.SearchDepth = infiniteDepth
+  } else if limits.Depth == 0 && (limits.Nodes > 0 || limits.Time > 0) {
+    // no depth given, so only the other limits end the search
+    options.SearchDepth = 0
+  }
+  var ponderTime time.Duration
+  if ponder {
+    ponderTime, limits.Time = limits.Time, 0
+  }
+  options.Limits = limits
+  board := e.board
+  options.Info = func(result engine.SearchResult) {
+    e.printInfo(board, result)
+  }
+  stop := new(atomic.Bool)
+  options.Stop = stop
+  var hold chan struct{}
+  if ponder || infinite {
+    hold = make(chan struct{})
+  }
+  e.searchMu.Lock()
+  e.stop, e.hold = stop, hold
+  e.pondering, e.infinite, e.ponderTime = ponder, infinite, ponderTime
+  e.searchMu.Unlock()
+  e.searching.Add(1)
+  go func() {
+    defer e.searching.Done()
+    result := options.Search(board)
+    if hold != nil {
+      <-hold
+    }
+    if len(result.Lines) > 0 && len(result.Lines[0].PV) > 1 {
+      e.printf("bestmove %s ponder %s", result.Move, result.Lines[0].PV[1])
+    } else {
+      e.printf("bestmove %s", result.Move)
+    }
+  }()
+}
+
+func (e *Engine) ponderHit() {
+  // the ponder search carries on as a normal one, with its time limit
+  // counted from now
+  e.searchMu.Lock()
+  defer e.searchMu.Unlock()
+  if !e.pondering {
+    return
+  }
+  e.pondering = false
+  if e.ponderTime > 0 {
+    stop := e.stop
+    e.timer = time.AfterFunc(e.ponderTime, func() {
+      stop.Store(true)
+    })
+  }
+  if !e.infinite {
+    close(e.hold)
+    e.hold = nil
+  }
+}
+
+func (e *Engine) stopSearch() {
+  e.searchMu.Lock()
+  if e.stop != nil {
+    e.stop.Store(true)
+  }
+  if e.hold != nil {
+    close(e.hold)
+    e.hold = nil
+  }
+  if e.timer != nil {
+    e.timer.Stop()
+    e.timer = nil
+  }
+  e.pondering = false
+  e.searchMu.Unlock()
+  e.searching.Wait()
+}
+
+func (e *Engine) printInfo(board *chess.Board, result engine.SearchResult) {
+  for i, line := range result.Lines {
+    pv := make([]string, len(line.PV))
+    for j, move := range line.PV {
+      pv[j] = move.String()
+    }
+    e.printf("info depth %d multipv %d score %s pv %s", line.Depth, i+1, Score(board.Turn, line), strings.Join(pv, " "))
+  }
+}
+
+func Score(turn chess.Color, line engine.PVLine) string {
+  // UCI scores are from the side to move's point of view, mates in
+  // moves
+  score := line.Score
+  if turn == chess.Black {
+    score = -score
+  }
+  if engine.IsMate(score) {
+    moves := (engine.MatePlies(score) + 1) / 2
+    if score < 0 {
+      moves = -moves
+    }
+    return fmt.Sprintf("mate %d", moves)
+  }
+  return fmt.Sprintf("cp %d", score)
+}
@@ -0,0 +1,148 @@
+package uci
+
+import (
+  "strings"
+  "sync"
+  "testing"
+  "time"
+  chess "chess/board"
+  engine "chess/engine"
+)
+
+// output collects what the engine prints, line by line
+type output struct {
+  mu sync.Mutex
+  lines []string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+  o.mu.Lock()
+  defer o.mu.Unlock()
+  o.lines = append(o.lines, strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")...)
+  return len(p), nil
+}
+
+func (o *output) bestmove() (string, bool) {
+  o.mu.Lock()
+  defer o.mu.Unlock()
+  for _, line := range o.lines {
+    if strings.HasPrefix(line, "bestmove") {
+      return line, true
+    }
+  }
+  return "", false
+}
+
+func (o *output) reset() {
+  o.mu.Lock()
+  defer o.mu.Unlock()
+  o.lines = nil
+}
+
+func (o *output) waitBestmove(t *testing.T) string {
+  t.Helper()
+  deadline := time.Now().Add(10 * time.Second)
+  for time.Now().Before(deadline) {
+    if line, ok := o.bestmove(); ok {
+      return line
+    }
+    time.Sleep(time.Millisecond)
+  }
+  t.Fatal("no bestmove")
+  return ""
+}
+
+func newTestEngine(commands ...string) (*Engine, *output) {
+  out := &output{}
+  e := NewEngine(out)
+  for _, command := range commands {
+    e.Handle(command)
+  }
+  return e, out
+}
+
+// every move runs into the fifty move rule, so each iteration is one
+// ply and a search gets to MaxDepth at once
+const fiftyMoves = "position fen 7k/8/8/8/8/8/8/K6R w - - 99 80"
+
+func TestInfiniteWaitsForStop(t *testing.T) {
+  e, out := newTestEngine(fiftyMoves, "go infinite")
+  defer e.stopSearch()
+  time.Sleep(100 * time.Millisecond)
+  if line, ok := out.bestmove(); ok {
+    t.Fatalf("%q before stop", line)
+  }
+  e.Handle("stop")
+  out.waitBestmove(t)
+}
+
+func TestPonderWaitsForPonderhit(t *testing.T) {
+  e, out := newTestEngine(fiftyMoves, "go ponder depth 2")
+  defer e.stopSearch()
+  time.Sleep(100 * time.Millisecond)
+  if line, ok := out.bestmove(); ok {
+    t.Fatalf("%q before ponderhit", line)
+  }
+  e.Handle("ponderhit")
+  out.waitBestmove(t)
+}
+
+func TestPonderTimeStartsAtPonderhit(t *testing.T) {
+  // the move time is not used up while pondering, the search keeps
+  // going past it until ponderhit and only then gets its 200ms
+  e, out := newTestEngine("position startpos", "go ponder movetime 200")
+  defer e.stopSearch()
+  time.Sleep(400 * time.Millisecond)
+  if line, ok := out.bestmove(); ok {
+    t.Fatalf("%q before ponderhit", line)
+  }
+  e.searchMu.Lock()
+  stopped := e.stop.Load()
+  e.searchMu.Unlock()
+  if stopped {
+    t.Fatal("the ponder search stopped at the move time")
+  }
+  hit := time.Now()
+  e.Handle("ponderhit")
+  out.waitBestmove(t)
+  if took := time.Since(hit); took < 150*time.Millisecond {
+    t.Errorf("bestmove %v after ponderhit, want about 200ms", took)
+  }
+}
+
+func TestPonderhitAfterStop(t *testing.T) {
+  // a late ponderhit must not stop or release the next search
+  e, out := newTestEngine(fiftyMoves, "go ponder movetime 1", "stop")
+  out.waitBestmove(t)
+  out.reset()
+  e.Handle("go infinite")
+  defer e.stopSearch()
+  e.Handle("ponderhit")
+  time.Sleep(50 * time.Millisecond)
+  if line, ok := out.bestmove(); ok {
+    t.Fatalf("%q before stop", line)
+  }
+}
+
+func TestScore(t *testing.T) {
+  tests := []struct {
+    turn chess.Color
+    score int // from white's point of view
+    want string
+  }{
+    {chess.White, 35, "cp 35"},
+    {chess.Black, 35, "cp -35"},
+    {chess.White, engine.MateScore - 1, "mate 1"},
+    {chess.White, engine.MateScore - 3, "mate 2"},
+    {chess.Black, engine.MateScore - 2, "mate -1"},
+    {chess.Black, -engine.MateScore + 5, "mate 3"},
+    {chess.White, -engine.MateScore + 4, "mate -2"},
+  }
+  for _, tt := range tests {
+    // the line is deliberately short, the distance comes from the score
+    line := engine.PVLine{Score: tt.score, PV: []chess.Move{{Start: 12, End: 28}}}
+    if got := Score(tt.turn, line); got != tt.want {
+      t.Errorf("%d with %v to move: got %q, want %q", tt.score, tt.turn, got, tt.want)
+    }
+  }
+}
@@ -0,0 +1,62 @@
+package variant
+
+import (
+  chess "chess/board"
+)
+
+// Antichess, also called giveaway or losing chess. Captures are
+// compulsory, the king is an ordinary piece that can be taken and
+// promoted to, there is no check or castling, and a player with no
+// pieces or no moves left wins.
+type Antichess struct {
+  chess.StandardRules
+}
+
+func (Antichess) Name() string {
+  return "antichess"
+}
+
+func (Antichess) LegalMoves(b *chess.Board, color chess.Color) []chess.Move {
+  var moves, captures []chess.Move
+  add := func(move chess.Move) {
+    if b.IsCapture(move) {
+      captures = append(captures, move)
+    } else {
+      moves = append(moves, move)
+    }
+  }
+  for _, move := range b.PseudoLegalMoves(color) {
+    if _, castles := b.CastlingSide(move); castles {
+      continue
+    }
+    add(move)
+    if move.Promotion == chess.Queens {
+      add(chess.Move{Start: move.Start, End: move.End, Promotion: chess.Kings})
+    }
+  }
+  if len(captures) > 0 {
+    return captures
+  }
+  return moves
+}
+
+func (Antichess) InCheck(b *chess.Board, color chess.Color) bool {
+  return false
+}
+
+func (v Antichess) Outcome(b *chess.Board) (chess.GameResult, bool) {
+  if len(v.LegalMoves(b, b.Turn)) > 0 {
+    return chess.GameResult{}, false
+  }
+  reason := "No moves left"
+  if b.ColorBB[b.Turn] == 0 {
+    reason = "All pieces lost"
+  }
+  return chess.GameResult{Winner: b.Turn, Reason: reason}, true
+}
+
+func (Antichess) Evaluate(b *chess.Board, score int) int {
+  // the normal evaluation means little here, being down material is
+  // what wins
+  return -material(b)
+}
@@ -0,0 +1,84 @@
+package variant
+
+import (
+  chess "chess/board"
+)
+
+// Atomic chess. A capture explodes on the square it is made on,
+// removing the capturing piece, the captured one and every piece but
+// a pawn next to that square. Kings may not capture, a move may not
+// blow up its own king and blowing up the other king wins. Kings
+// that touch can not give check, since taking one would destroy both.
+type Atomic struct {
+  chess.StandardRules
+}
+
+func (Atomic) Name() string {
+  return "atomic"
+}
+
+func (v Atomic) LegalMoves(b *chess.Board, color chess.Color) []chess.Move {
+  if b.PieceBB[color][chess.Kings] == 0 {
+    return nil
+  }
+  var moves []chess.Move
+  for _, move := range b.PseudoLegalMoves(color) {
+    piece := b.GetPieceAt(move.Start, color)
+    if piece == chess.Kings && b.IsCapture(move) {
+      continue
+    }
+    sim := b.Clone()
+    sim.Turn = color
+    sim.MovePiece(piece, move)
+    if sim.PieceBB[color][chess.Kings] == 0 {
+      continue
+    }
+    if sim.PieceBB[color.Other()][chess.Kings] == 0 || !v.InCheck(sim, color) {
+      moves = append(moves, move)
+    }
+  }
+  return moves
+}
+
+func (Atomic) InCheck(b *chess.Board, color chess.Color) bool {
+  king, ok := kingSquare(b, color)
+  if !ok || b.PieceBB[color.Other()][chess.Kings] == 0 {
+    return false
+  }
+  if chess.GetKingMoves(king, 0)&b.PieceBB[color.Other()][chess.Kings] != 0 {
+    return false
+  }
+  return b.KingAttacked(color)
+}
+
+func (Atomic) AfterMove(b *chess.Board, move chess.Move, piece chess.Piece, captured chess.Piece) {
+  if captured == chess.Empty {
+    return
+  }
+  blast := chess.GetKingMoves(move.End, 0)
+  for c := chess.White; c <= chess.Black; c++ {
+    for p := chess.Pawns; p <= chess.Kings; p++ {
+      b.PieceBB[c][p].ZeroBit(move.End)
+      if p != chess.Pawns {
+        b.PieceBB[c][p] &^= blast
+      }
+    }
+    // castling goes with an exploded king or rook
+    if b.PieceBB[c][chess.Kings] == 0 {
+      b.RKRmoved[c][1] = true
+    }
+    for side, rook := range b.CastlingRooks[c] {
+      if !b.PieceBB[c][chess.Rooks].GetBit(rook) {
+        b.RKRmoved[c][side*2] = true
+      }
+    }
+  }
+  b.CombineBB()
+}
+
+func (Atomic) Outcome(b *chess.Board) (chess.GameResult, bool) {
+  if b.PieceBB[b.Turn][chess.Kings] == 0 {
+    return chess.GameResult{Winner: b.Turn.Other(), Reason: "Explosion"}, true
+  }
+  return chess.GameResult{}, false
+}
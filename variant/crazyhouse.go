@@ -0,0 +1,37 @@
+package variant
+
+import (
+  chess "chess/board"
+)
+
+// Crazyhouse. A captured piece goes to the capturer's pocket, a
+// promoted piece as the pawn it was, and instead of moving a player
+// may drop a piece from their pocket on any empty square. Pockets
+// and promoted pieces live on the board, see Board.Pockets.
+type Crazyhouse struct {
+  chess.StandardRules
+}
+
+func (Crazyhouse) Name() string {
+  return "crazyhouse"
+}
+
+func (Crazyhouse) LegalMoves(b *chess.Board, color chess.Color) []chess.Move {
+  return append(b.StandardLegalMoves(color), b.DropMoves(color)...)
+}
+
+func (Crazyhouse) AfterMove(b *chess.Board, move chess.Move, piece chess.Piece, captured chess.Piece) {
+  if captured != chess.Empty {
+    b.Pockets[b.Turn][captured]++
+  }
+}
+
+func (Crazyhouse) Evaluate(b *chess.Board, score int) int {
+  // pieces in hand count as material, a little more than on the
+  // board since they can go anywhere
+  for p := chess.Pawns; p <= chess.Queens; p++ {
+    score += int(b.Pockets[chess.White][p]) * pieceValues[p] * 11 / 10
+    score -= int(b.Pockets[chess.Black][p]) * pieceValues[p] * 11 / 10
+  }
+  return score
+}
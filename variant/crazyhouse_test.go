@@ -0,0 +1,120 @@
+package variant
+
+import (
+  "testing"
+  chess "chess/board"
+)
+
+func newCrazyhouse(t *testing.T, fen string) *chess.Board {
+  t.Helper()
+  board, err := NewBoardFromFEN(Crazyhouse{}, fen)
+  if err != nil {
+    t.Fatalf("%s: %v", fen, err)
+  }
+  return board
+}
+
+func TestCrazyhousePerft(t *testing.T) {
+  // published counts from the Fairy-Stockfish test suite
+  tests := []struct {
+    fen string
+    nodes []uint64
+  }{
+    {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1", []uint64{20, 400, 8902, 197281}},
+    {"2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", []uint64{301, 75353}},
+    {"2k5/8/8/8/8/8/8/4K3/QRBNPqrbnp w - - 0 1", []uint64{301, 75353}},
+    {"r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[] b KQkq - 0 1", []uint64{42, 1347, 58057}},
+  }
+  for _, tt := range tests {
+    board := newCrazyhouse(t, tt.fen)
+    for i, want := range tt.nodes {
+      if got := chess.Perft(board, i+1); got != want {
+        t.Errorf("%s depth %d: got %d nodes, want %d", tt.fen, i+1, got, want)
+      }
+    }
+  }
+}
+
+func TestCrazyhousePawnDrops(t *testing.T) {
+  board := newCrazyhouse(t, "4k3/8/8/8/8/8/8/4K3[Pp] w - - 0 1")
+  for _, color := range []chess.Color{chess.White, chess.Black} {
+    drops := board.DropMoves(color)
+    // every empty square of ranks 2 to 7
+    if len(drops) != 48 {
+      t.Errorf("%d pawn drops, want 48", len(drops))
+    }
+    for _, move := range drops {
+      if rank := move.End / 8; rank == 0 || rank == 7 {
+        t.Errorf("pawn dropped on %s", chess.IndexToNotation(move.End))
+      }
+    }
+  }
+}
+
+func TestCrazyhousePromotedCapture(t *testing.T) {
+  // b8=Q and Rxb8, black gets a pawn rather than a queen
+  board := newCrazyhouse(t, "r3k3/1P6/8/8/8/8/8/4K3[] w - - 0 1")
+  for _, text := range []string{"b7b8q", "a8b8"} {
+    move, err := chess.ParseMove(text)
+    if err != nil || !board.IsLegal(move) {
+      t.Fatalf("%s: %v", text, err)
+    }
+    board.PlayMove(move)
+  }
+  if board.Pockets[chess.Black][chess.Pawns] != 1 || board.Pockets[chess.Black][chess.Queens] != 0 {
+    t.Errorf("black pocket after taking a promoted queen: %v", board.Pockets[chess.Black])
+  }
+  if board.Promoted != 0 {
+    t.Errorf("promoted mark left on %x", uint64(board.Promoted))
+  }
+
+  // the same for a promoted piece read from a FEN
+  board = newCrazyhouse(t, "4k3/8/8/8/8/8/8/r2Q~K3[] b - - 0 1")
+  move, err := chess.ParseMove("a1d1")
+  if err != nil {
+    t.Fatal(err)
+  }
+  board.PlayMove(move)
+  if board.Pockets[chess.Black][chess.Pawns] != 1 || board.Pockets[chess.Black][chess.Queens] != 0 {
+    t.Errorf("black pocket after taking a queen marked ~: %v", board.Pockets[chess.Black])
+  }
+}
+
+func TestCrazyhouseFEN(t *testing.T) {
+  for _, fen := range []string{
+    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+    "2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1",
+    "r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[PPn] b KQkq - 4 9",
+    "2k5/8/8/8/8/8/8/3Q~K3[Bpp] w - - 0 30",
+  } {
+    if got := FEN(newCrazyhouse(t, fen)); got != fen {
+      t.Errorf("FEN() = %q, want %q", got, fen)
+    }
+  }
+  // a ninth rank pocket is written back in brackets
+  want := "2k5/8/8/8/8/8/8/4K3[Nq] w - - 0 1"
+  if got := FEN(newCrazyhouse(t, "2k5/8/8/8/8/8/8/4K3/qN w - - 0 1")); got != want {
+    t.Errorf("FEN() = %q, want %q", got, want)
+  }
+}
+
+func TestCrazyhouseSAN(t *testing.T) {
+  // drops are written N@f3 and @e4 for pawns, and read back
+  board := newCrazyhouse(t, "r1bqk2r/pppp1ppp/2n1p3/4P3/1b1Pn3/2NB1N2/PPP2PPP/R1BQK2R[PPn] b KQkq - 4 9")
+  for _, move := range board.GetAllLegalMoves(board.Turn) {
+    san := board.SAN(move)
+    if got, err := board.ParseSAN(san); err != nil || got != move {
+      t.Errorf("%s written %q, read back as %s (%v)", move, san, got, err)
+    }
+  }
+  board.PlayMove(chess.Move{Start: chess.NotationToIndex("e8"), End: chess.NotationToIndex("g8")})
+  for _, text := range []string{"@e3", "P@e3"} {
+    if move, err := board.ParseSAN(text); err != nil || move.String() != "P@e3" {
+      t.Errorf("%q read as %s (%v), want P@e3", text, move, err)
+    }
+  }
+  // white has no knight in hand
+  if move, err := board.ParseSAN("N@a3"); err == nil {
+    t.Errorf("N@a3 read as %s, want an error", move)
+  }
+}
@@ -0,0 +1,48 @@
+package variant
+
+import (
+  chess "chess/board"
+)
+
+// KingOfTheHill is won by checkmate or by bringing the king to one
+// of the four centre squares.
+type KingOfTheHill struct {
+  chess.StandardRules
+}
+
+var hill = (chess.FileD | chess.FileE) & (chess.Rank4 | chess.Rank5)
+
+func (KingOfTheHill) Name() string {
+  return "kingofthehill"
+}
+
+func (v KingOfTheHill) LegalMoves(b *chess.Board, color chess.Color) []chess.Move {
+  if _, over := v.Outcome(b); over {
+    return nil
+  }
+  return b.StandardLegalMoves(color)
+}
+
+func (KingOfTheHill) Outcome(b *chess.Board) (chess.GameResult, bool) {
+  mover := b.Turn.Other()
+  if b.PieceBB[mover][chess.Kings]&hill != 0 {
+    return chess.GameResult{Winner: mover, Reason: "King of the hill"}, true
+  }
+  return chess.GameResult{}, false
+}
+
+func (KingOfTheHill) Evaluate(b *chess.Board, score int) int {
+  // a king closer to the centre is closer to winning, which matters
+  // more the fewer pieces are left to stop it
+  return score + hillBonus(b, chess.White) - hillBonus(b, chess.Black)
+}
+
+func hillBonus(b *chess.Board, color chess.Color) int {
+  sq, ok := kingSquare(b, color)
+  if !ok {
+    return 0
+  }
+  file, rank := int(sq%8), int(sq/8)
+  distance := max(max(3-file, file-4), 0) + max(max(3-rank, rank-4), 0)
+  return (6 - distance) * 15
+}
@@ -0,0 +1,43 @@
+package variant
+
+import (
+  chess "chess/board"
+)
+
+// ThreeCheck is won by checkmate or by giving check three times. The
+// checks are counted in Board.Checks.
+type ThreeCheck struct {
+  chess.StandardRules
+}
+
+const checksToWin = 3
+
+func (ThreeCheck) Name() string {
+  return "3check"
+}
+
+func (v ThreeCheck) LegalMoves(b *chess.Board, color chess.Color) []chess.Move {
+  if _, over := v.Outcome(b); over {
+    return nil
+  }
+  return b.StandardLegalMoves(color)
+}
+
+func (ThreeCheck) AfterMove(b *chess.Board, move chess.Move, piece chess.Piece, captured chess.Piece) {
+  if b.KingAttacked(b.Turn.Other()) {
+    b.Checks[b.Turn]++
+  }
+}
+
+func (ThreeCheck) Outcome(b *chess.Board) (chess.GameResult, bool) {
+  mover := b.Turn.Other()
+  if b.Checks[mover] >= checksToWin {
+    return chess.GameResult{Winner: mover, Reason: "Three checks"}, true
+  }
+  return chess.GameResult{}, false
+}
+
+func (ThreeCheck) Evaluate(b *chess.Board, score int) int {
+  // each check given is worth more than a minor piece
+  return score + 150*(int(b.Checks[chess.White])-int(b.Checks[chess.Black]))
+}
@@ -0,0 +1,231 @@
+package variant
+
+import (
+  "fmt"
+  "math/bits"
+  "strings"
+  chess "chess/board"
+)
+
+// Chess variants played on chess.Board through the chess.Variant
+// interface. Each one embeds chess.StandardRules and overrides the
+// rules it changes.
+
+var variants = []chess.Variant{
+  chess.StandardRules{},
+  KingOfTheHill{},
+  ThreeCheck{},
+  Atomic{},
+  Antichess{},
+  Crazyhouse{},
+}
+
+var aliases = map[string]string{
+  "standard": "chess",
+  "koth": "kingofthehill",
+  "threecheck": "3check",
+  "giveaway": "antichess",
+  "zh": "crazyhouse",
+}
+
+func Names() []string {
+  names := make([]string, len(variants))
+  for i, v := range variants {
+    names[i] = v.Name()
+  }
+  return names
+}
+
+func ByName(name string) (chess.Variant, error) {
+  name = strings.ToLower(strings.ReplaceAll(name, " ", ""))
+  if alias, ok := aliases[name]; ok {
+    name = alias
+  }
+  for _, v := range variants {
+    if v.Name() == name {
+      return v, nil
+    }
+  }
+  return nil, fmt.Errorf("unknown variant %q, expected one of %s", name, strings.Join(Names(), ", "))
+}
+
+func NewBoard(v chess.Variant) *chess.Board {
+  // the start position of the variant
+  b := chess.NewBoard()
+  setVariant(b, v)
+  if _, ok := v.(Antichess); ok {
+    // there is no castling in antichess
+    b.RKRmoved = [2][3]bool{{true, true, true}, {true, true, true}}
+  }
+  return b
+}
+
+func NewBoardFromFEN(v chess.Variant, fen string) (*chess.Board, error) {
+  // Reads a FEN for the variant. Three-check positions may end with
+  // the checks given so far, as in "+1+0". Crazyhouse positions may
+  // have the pockets after the placement, in brackets or as a ninth
+  // rank, with promoted pieces marked by a ~.
+  fields := strings.Fields(fen)
+  var pockets [2][7]uint8
+  var promoted chess.Bitboard
+  if len(fields) > 0 {
+    placement, err := readPockets(fields[0], &pockets, &promoted)
+    if err != nil {
+      return nil, err
+    }
+    fields[0] = placement
+  }
+  var checks [2]uint8
+  if n := len(fields); n > 0 && strings.HasPrefix(fields[n-1], "+") {
+    if _, err := fmt.Sscanf(fields[n-1], "+%d+%d", &checks[chess.White], &checks[chess.Black]); err != nil {
+      return nil, fmt.Errorf("invalid check count %q", fields[n-1])
+    }
+    fields = fields[:n-1]
+  }
+  b, err := chess.NewBoardFromFEN(strings.Join(fields, " "))
+  if err != nil {
+    return nil, err
+  }
+  b.Checks = checks
+  b.Pockets = pockets
+  b.Promoted = promoted
+  setVariant(b, v)
+  return b, nil
+}
+
+func setVariant(b *chess.Board, v chess.Variant) {
+  // a nil variant is normal chess, and so is StandardRules
+  if _, ok := v.(chess.StandardRules); ok {
+    v = nil
+  }
+  b.Variant = v
+  // the repetition history was hashed before the variant was set
+  b.History = map[uint64]int{b.GetZobristHash(): 1}
+}
+
+func FEN(b *chess.Board) string {
+  // the board's FEN, with the checks given in Three-check and the
+  // pockets and promoted pieces in Crazyhouse
+  switch b.Variant.(type) {
+  case ThreeCheck:
+    return fmt.Sprintf("%s +%d+%d", b.FEN(), b.Checks[chess.White], b.Checks[chess.Black])
+  case Crazyhouse:
+    fields := strings.SplitN(b.FEN(), " ", 2)
+    return writePockets(b, fields[0]) + " " + fields[1]
+  }
+  return b.FEN()
+}
+
+func readPockets(placement string, pockets *[2][7]uint8, promoted *chess.Bitboard) (string, error) {
+  // takes the pockets and ~ marks out of a FEN placement field
+  var pocket string
+  if i := strings.IndexByte(placement, '['); i >= 0 {
+    if !strings.HasSuffix(placement, "]") {
+      return "", fmt.Errorf("invalid pocket in %q", placement)
+    }
+    placement, pocket = placement[:i], placement[i+1:len(placement)-1]
+  } else if ranks := strings.Split(placement, "/"); len(ranks) == 9 {
+    placement, pocket = strings.Join(ranks[:8], "/"), ranks[8]
+  }
+  for _, char := range pocket {
+    if char == '-' {
+      continue
+    }
+    piece, color, err := pieceFromChar(char)
+    if err != nil || piece == chess.Kings {
+      return "", fmt.Errorf("invalid pocket piece %q", char)
+    }
+    pockets[color][piece]++
+  }
+  if !strings.Contains(placement, "~") {
+    return placement, nil
+  }
+  var sb strings.Builder
+  sq := 56
+  for _, char := range placement {
+    switch {
+    case char == '~':
+      promoted.SetBit(chess.Square(sq - 1))
+      continue
+    case char == '/':
+      sq -= 16
+    case char >= '1' && char <= '8':
+      sq += int(char - '0')
+    default:
+      sq++
+    }
+    sb.WriteRune(char)
+  }
+  return sb.String(), nil
+}
+
+func writePockets(b *chess.Board, placement string) string {
+  // adds the ~ marks and the pocket in brackets to a placement field
+  var sb strings.Builder
+  sq := 56
+  for _, char := range placement {
+    sb.WriteRune(char)
+    switch {
+    case char == '/':
+      sq -= 16
+    case char >= '1' && char <= '8':
+      sq += int(char - '0')
+    default:
+      if b.Promoted.GetBit(chess.Square(sq)) {
+        sb.WriteByte('~')
+      }
+      sq++
+    }
+  }
+  sb.WriteByte('[')
+  for _, color := range []chess.Color{chess.White, chess.Black} {
+    for p := chess.Queens; p >= chess.Pawns; p-- {
+      for range b.Pockets[color][p] {
+        sb.WriteString(pieceChar(p, color))
+      }
+    }
+  }
+  sb.WriteByte(']')
+  return sb.String()
+}
+
+func pieceFromChar(char rune) (chess.Piece, chess.Color, error) {
+  color := chess.White
+  if char >= 'a' && char <= 'z' {
+    color = chess.Black
+    char -= 'a' - 'A'
+  }
+  i := strings.IndexRune("PNBRQK", char)
+  if i < 0 {
+    return chess.Empty, color, fmt.Errorf("invalid piece %q", char)
+  }
+  return chess.Piece(i + 1), color, nil
+}
+
+func pieceChar(p chess.Piece, color chess.Color) string {
+  char := chess.PieceToChar(p, chess.White)
+  if color == chess.White {
+    return strings.ToUpper(char)
+  }
+  return char
+}
+
+var pieceValues = [7]int{0, 100, 300, 300, 500, 900, 0}
+
+func material(b *chess.Board) int {
+  // white's material minus black's, kings not counted
+  score := 0
+  for p := chess.Pawns; p <= chess.Queens; p++ {
+    score += bits.OnesCount64(uint64(b.PieceBB[chess.White][p])) * pieceValues[p]
+    score -= bits.OnesCount64(uint64(b.PieceBB[chess.Black][p])) * pieceValues[p]
+  }
+  return score
+}
+
+func kingSquare(b *chess.Board, color chess.Color) (chess.Square, bool) {
+  king := b.PieceBB[color][chess.Kings]
+  if king == 0 {
+    return 0, false
+  }
+  return chess.Square(bits.TrailingZeros64(uint64(king))), true
+}
@@ -0,0 +1,44 @@
+package variant
+
+import (
+  "testing"
+  chess "chess/board"
+)
+
+// perftTests are published perft results, from the chessprogramming
+// wiki for chess and the Fairy-Stockfish and lichess test suites for
+// the variants. nodes[i] is the count at depth i+1.
+var perftTests = []struct {
+  variant string
+  fen string
+  nodes []uint64
+}{
+  {"chess", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []uint64{20, 400, 8902}},
+  {"chess", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []uint64{48, 2039, 97862}},
+  {"kingofthehill", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []uint64{20, 400, 8902}},
+  {"3check", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +0+0", []uint64{20, 400, 8902}},
+  {"atomic", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []uint64{20, 400, 8902, 197326}},
+  {"atomic", "rn2kb1r/1pp1p2p/p2q1pp1/3P4/2P3b1/4PN2/PP3PPP/R2QKB1R b KQkq - 0 1", []uint64{40, 1238, 45237}},
+  {"atomic", "rn1qkb1r/p5pp/2p5/3p4/N3P3/5P2/PPP4P/R1BQK3 w Qkq - 0 1", []uint64{28, 833, 23353}},
+  {"atomic", "r4b1r/2kb1N2/p2Bpnp1/8/2Pp3p/1P1PPP2/P5PP/R3K2R b KQ - 0 1", []uint64{4, 148}},
+  {"atomic", "1R4kr/4K3/8/8/8/8/8/8 b k - 0 1", []uint64{4, 77, 1021, 17915}},
+  {"antichess", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1", []uint64{20, 400, 8067, 153299}},
+}
+
+func TestPerft(t *testing.T) {
+  for _, tt := range perftTests {
+    rules, err := ByName(tt.variant)
+    if err != nil {
+      t.Fatal(err)
+    }
+    board, err := NewBoardFromFEN(rules, tt.fen)
+    if err != nil {
+      t.Fatalf("%s %s: %v", tt.variant, tt.fen, err)
+    }
+    for i, want := range tt.nodes {
+      if got := chess.Perft(board, i+1); got != want {
+        t.Errorf("%s %s depth %d: got %d nodes, want %d", tt.variant, tt.fen, i+1, got, want)
+      }
+    }
+  }
+}